@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"hammond/db"
+	"hammond/service"
+
+	"github.com/google/uuid"
+)
+
+// runCommand runs one of the maintenance commands instead of the server and
+// returns its exit code, like
+//
+//	hammond migrate-storage -to s3
+func runCommand(args []string) int {
+	switch args[0] {
+	case "migrate-storage":
+		return migrateStorageCommand(args[1:])
+	case "collect-garbage":
+		return collectGarbageCommand(args[1:])
+	case "export-archive":
+		return exportArchiveCommand(args[1:])
+	case "import-archive":
+		return importArchiveCommand(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %s, try migrate-storage, collect-garbage, export-archive or import-archive\n", args[0])
+	return 2
+}
+
+func migrateStorageCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	target := flags.String("to", "", "the storage to move the attachments to, local or s3")
+	keep := flags.Bool("keep", false, "keep the files in the storage they are moved from")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *target == "" {
+		flags.Usage()
+		return 2
+	}
+
+	migration, err := service.MigrateStorage(*target, *keep)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(migration, "", "  ")
+	fmt.Println(string(out))
+	if migration.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func collectGarbageCommand(args []string) int {
+	flags := flag.NewFlagSet("collect-garbage", flag.ContinueOnError)
+	cleanUp := flags.Bool("clean", false, "remove what is found rather than only report it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := service.CollectGarbageNow(*cleanUp)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func exportArchiveCommand(args []string) int {
+	flags := flag.NewFlagSet("export-archive", flag.ContinueOnError)
+	out := flags.String("out", "", "the file to write the archive to")
+	email := flags.String("user", "", "the email of the user to export, the whole instance when left out")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *out == "" {
+		flags.Usage()
+		return 2
+	}
+	scope, userId, err := archiveCommandUser(*email)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	err = service.WriteArchive(file, scope, userId)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func importArchiveCommand(args []string) int {
+	flags := flag.NewFlagSet("import-archive", flag.ContinueOnError)
+	in := flags.String("in", "", "the archive to import")
+	email := flags.String("user", "", "the email of the user to import the archive of a user into, leave out for the archive of an instance")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *in == "" {
+		flags.Usage()
+		return 2
+	}
+	scope, userId, err := archiveCommandUser(*email)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+	report, err := service.ImportArchive(file, scope, userId)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	return 0
+}
+
+// archiveCommandUser finds the user an archive command is about, the archive
+// is one of the whole instance when no email is given.
+func archiveCommandUser(email string) (db.ArchiveScope, uuid.UUID, error) {
+	if email == "" {
+		return db.INSTANCE_ARCHIVE, uuid.Nil, nil
+	}
+	user, err := db.FindOneUser(&db.User{Email: email})
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("there is no user with the email %s", email)
+	}
+	return db.USER_ARCHIVE, user.ID, nil
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/db"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAuditLogController(router *gin.RouterGroup) {
+	router.GET("/vehicles/:id/auditLog", getVehicleAuditLog)
+	router.GET("/vehicles/:id/fillups/:subId/auditLog", getFillupAuditLog)
+	router.GET("/vehicles/:id/expenses/:subId/auditLog", getExpenseAuditLog)
+	router.GET("/auditLog", ShouldBeAdmin(), getAuditLog)
+}
+
+func getVehicleAuditLog(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var model models.AuditLogQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&model); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleAuditLog", err))
+			return
+		}
+		logs, err := service.GetVehicleAuditLog(userId, id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleAuditLog", err))
+			return
+		}
+		c.JSON(http.StatusOK, logs)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getFillupAuditLog(c *gin.Context) {
+	getEntryAuditLog(c, db.AUDIT_FILLUP, "getFillupAuditLog")
+}
+
+func getExpenseAuditLog(c *gin.Context) {
+	getEntryAuditLog(c, db.AUDIT_EXPENSE, "getExpenseAuditLog")
+}
+
+func getEntryAuditLog(c *gin.Context, entityType db.AuditEntity, name string) {
+	var searchByIdQuery models.SubItemQuery
+	var model models.AuditLogQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&model); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		subId, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		logs, err := service.GetEntryAuditLog(userId, id, entityType, subId, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		c.JSON(http.StatusOK, logs)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getAuditLog(c *gin.Context) {
+	var model models.AuditLogQuery
+
+	if err := c.ShouldBind(&model); err == nil {
+		logs, err := service.GetAuditLog(model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getAuditLog", err))
+			return
+		}
+		c.JSON(http.StatusOK, logs)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
@@ -36,7 +36,7 @@ func ShouldBeAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		model := c.MustGet("userModel").(db.User)
 		if model.Role != db.ADMIN {
-			c.JSON(http.StatusUnauthorized, gin.H{})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{})
 		} else {
 			c.Next()
 		}
@@ -63,7 +63,12 @@ func userRegister(c *gin.Context) {
 		return
 	}
 
-	if err := service.CreateUser(&registerRequest, *registerRequest.Role); err != nil {
+	userId, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("register", err))
+		return
+	}
+	if err := service.CreateUser(&registerRequest, *registerRequest.Role, userId); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, common.NewError("database", err))
 		return
 	}
@@ -86,7 +91,7 @@ func initializeSystem(c *gin.Context) {
 
 	_ = service.UpdateSettings(registerRequest.Currency, *registerRequest.DistanceUnit)
 
-	if err := service.CreateUser(&registerRequest, db.ADMIN); err != nil {
+	if err := service.CreateUser(&registerRequest, db.ADMIN, uuid.Nil); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, common.NewError("initializeSystem", err))
 		return
 	}
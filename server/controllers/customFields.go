@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterCustomFieldController(router *gin.RouterGroup) {
+	router.GET("/customFields", getCustomFieldDefinitions)
+	router.POST("/customFields", ShouldBeAdmin(), createCustomFieldDefinition)
+	router.PUT("/customFields/:id", ShouldBeAdmin(), updateCustomFieldDefinition)
+	router.DELETE("/customFields/:id", ShouldBeAdmin(), deleteCustomFieldDefinition)
+}
+
+func getCustomFieldDefinitions(c *gin.Context) {
+	var query models.CustomFieldQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	definitions, err := service.GetCustomFieldDefinitions(query.EntityType)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getCustomFieldDefinitions", err))
+		return
+	}
+	c.JSON(http.StatusOK, definitions)
+}
+
+func createCustomFieldDefinition(c *gin.Context) {
+	var request models.CreateCustomFieldRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	definition, err := service.CreateCustomFieldDefinition(request)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("createCustomFieldDefinition", err))
+		return
+	}
+	c.JSON(http.StatusCreated, definition)
+}
+
+func updateCustomFieldDefinition(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.UpdateCustomFieldRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateCustomFieldDefinition", err))
+				return
+			}
+			err = service.UpdateCustomFieldDefinition(id, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateCustomFieldDefinition", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteCustomFieldDefinition(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteCustomFieldDefinition", err))
+			return
+		}
+		err = service.DeleteCustomFieldDefinition(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteCustomFieldDefinition", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func RegisterDocumentController(router *gin.RouterGroup) {
+	router.GET("/vehicles/:id/documents", getVehicleDocumentsByVehicleId)
+	router.POST("/vehicles/:id/documents", createVehicleDocument)
+	router.GET("/vehicles/:id/documents/:subId", getVehicleDocumentById)
+	router.PUT("/vehicles/:id/documents/:subId", updateVehicleDocument)
+	router.DELETE("/vehicles/:id/documents/:subId", deleteVehicleDocument)
+	router.GET("/me/documents/expiring", getMyExpiringDocuments)
+}
+
+func getVehicleDocumentsByVehicleId(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleDocumentsByVehicleId", err))
+			return
+		}
+		data, err := service.GetVehicleDocumentsByVehicleId(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleDocumentsByVehicleId", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func createVehicleDocument(c *gin.Context) {
+	var request models.CreateVehicleDocumentRequest
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createVehicleDocument", err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		attachmentId, err := saveOptionalDocumentFile(c)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createVehicleDocument", err))
+			return
+		}
+		document, err := service.CreateVehicleDocument(id, userId, request, attachmentId)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createVehicleDocument", err))
+			return
+		}
+		c.JSON(http.StatusCreated, document)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getVehicleDocumentById(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleDocumentById", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleDocumentById", err))
+			return
+		}
+		obj, err := service.GetVehicleDocumentById(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleDocumentById", err))
+			return
+		}
+		c.JSON(http.StatusOK, obj)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func updateVehicleDocument(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.UpdateVehicleDocumentRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateVehicleDocument", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateVehicleDocument", err))
+				return
+			}
+			attachmentId, err := saveOptionalDocumentFile(c)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateVehicleDocument", err))
+				return
+			}
+			err = service.UpdateVehicleDocument(id, subID, request, attachmentId)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateVehicleDocument", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteVehicleDocument(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteVehicleDocument", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteVehicleDocument", err))
+			return
+		}
+		err = service.DeleteVehicleDocument(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteVehicleDocument", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getMyExpiringDocuments(c *gin.Context) {
+	var query models.ExpiringDocumentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	documents, err := service.GetExpiringDocumentsForUser(id, query.Days)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyExpiringDocuments", err))
+		return
+	}
+	c.JSON(http.StatusOK, documents)
+}
+
+// saveOptionalDocumentFile stores the scan of a document when one is uploaded
+// along with it.
+func saveOptionalDocumentFile(c *gin.Context) (*uuid.UUID, error) {
+	if _, err := c.FormFile("file"); err != nil {
+		return nil, nil
+	}
+	attachment, err := saveUploadedFile(c, "file")
+	if err != nil {
+		return nil, err
+	}
+	return &attachment.ID, nil
+}
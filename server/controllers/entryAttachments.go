@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/db"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// entryAttachments are the service calls behind the attachments of either
+// fillups or expenses.
+type entryAttachments struct {
+	check  func(vehicleId, entryId uuid.UUID) error
+	create func(entryId uuid.UUID, attachments []*db.Attachment, title string) error
+	list   func(entryId uuid.UUID) (*[]db.Attachment, error)
+	delete func(entryId, attachmentId uuid.UUID) error
+}
+
+var fillupAttachments = entryAttachments{
+	check:  service.CheckVehicleFillup,
+	create: service.CreateFillupAttachments,
+	list:   service.GetFillupAttachments,
+	delete: service.DeleteFillupAttachment,
+}
+
+var expenseAttachments = entryAttachments{
+	check:  service.CheckVehicleExpense,
+	create: service.CreateExpenseAttachments,
+	list:   service.GetExpenseAttachments,
+	delete: service.DeleteExpenseAttachment,
+}
+
+func RegisterEntryAttachmentController(router *gin.RouterGroup) {
+	router.POST("/vehicles/:id/fillups/:subId/attachments", createFillupAttachments)
+	router.GET("/vehicles/:id/fillups/:subId/attachments", getFillupAttachments)
+	router.DELETE("/vehicles/:id/fillups/:subId/attachments/:attachmentId", deleteFillupAttachment)
+	router.POST("/vehicles/:id/expenses/:subId/attachments", createExpenseAttachments)
+	router.GET("/vehicles/:id/expenses/:subId/attachments", getExpenseAttachments)
+	router.DELETE("/vehicles/:id/expenses/:subId/attachments/:attachmentId", deleteExpenseAttachment)
+}
+
+func createFillupAttachments(c *gin.Context) {
+	createEntryAttachments(c, fillupAttachments, "createFillupAttachments")
+}
+
+func getFillupAttachments(c *gin.Context) {
+	getEntryAttachments(c, fillupAttachments, "getFillupAttachments")
+}
+
+func deleteFillupAttachment(c *gin.Context) {
+	deleteEntryAttachment(c, fillupAttachments, "deleteFillupAttachment")
+}
+
+func createExpenseAttachments(c *gin.Context) {
+	createEntryAttachments(c, expenseAttachments, "createExpenseAttachments")
+}
+
+func getExpenseAttachments(c *gin.Context) {
+	getEntryAttachments(c, expenseAttachments, "getExpenseAttachments")
+}
+
+func deleteExpenseAttachment(c *gin.Context) {
+	deleteEntryAttachment(c, expenseAttachments, "deleteExpenseAttachment")
+}
+
+// createEntryAttachments uploads the files sent as "files" and links them to
+// the entry in one go.
+func createEntryAttachments(c *gin.Context, entry entryAttachments, name string) {
+	var searchByIdQuery models.SubItemQuery
+	var dataModel models.CreateEntryAttachmentModel
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&dataModel); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		id, subId, err := toEntryIds(searchByIdQuery)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		if !checkVehicleAccess(c, id, name) {
+			return
+		}
+		if err := entry.check(id, subId); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		attachments, err := saveMultipleUploadedFile(c, "files")
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		if err := entry.create(subId, attachments, dataModel.Title); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		c.JSON(http.StatusCreated, attachments)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getEntryAttachments(c *gin.Context, entry entryAttachments, name string) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, subId, err := toEntryIds(searchByIdQuery)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		if !checkVehicleAccess(c, id, name) {
+			return
+		}
+		if err := entry.check(id, subId); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		attachments, err := entry.list(subId)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		c.JSON(http.StatusOK, attachments)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteEntryAttachment(c *gin.Context, entry entryAttachments, name string) {
+	var attachmentQuery models.EntryAttachmentQuery
+
+	if err := c.ShouldBindUri(&attachmentQuery); err == nil {
+		id, subId, err := toEntryIds(models.SubItemQuery{ID: attachmentQuery.ID, SubID: attachmentQuery.SubID})
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		attachmentId, err := common.ToUUID(attachmentQuery.AttachmentID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		if !checkVehicleAccess(c, id, name) {
+			return
+		}
+		if err := entry.check(id, subId); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		if err := entry.delete(subId, attachmentId); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError(name, err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func toEntryIds(query models.SubItemQuery) (uuid.UUID, uuid.UUID, error) {
+	id, err := common.ToUUID(query.ID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	subId, err := common.ToUUID(query.SubID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return id, subId, nil
+}
+
+// checkVehicleAccess stops users the vehicle isn't shared with. The response
+// has been written when it returns false.
+func checkVehicleAccess(c *gin.Context, vehicleId uuid.UUID, name string) bool {
+	userId, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return false
+	}
+	if err := service.CheckVehicleAccess(userId, vehicleId); err != nil {
+		c.JSON(http.StatusForbidden, common.NewError(name, err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterExpenseCategoryController(router *gin.RouterGroup) {
+	router.GET("/expenseCategories", getAllExpenseCategories)
+	router.POST("/expenseCategories", ShouldBeAdmin(), createExpenseCategory)
+	router.PUT("/expenseCategories/:id", ShouldBeAdmin(), updateExpenseCategory)
+	router.DELETE("/expenseCategories/:id", ShouldBeAdmin(), deleteExpenseCategory)
+	router.POST("/expenseCategories/:id/merge", ShouldBeAdmin(), mergeExpenseCategory)
+	router.POST("/expenseCategories/:id/aliases", ShouldBeAdmin(), createExpenseCategoryAlias)
+	router.DELETE("/expenseCategories/:id/aliases/:subId", ShouldBeAdmin(), deleteExpenseCategoryAlias)
+}
+
+func getAllExpenseCategories(c *gin.Context) {
+	categories, err := service.GetAllExpenseCategories()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getAllExpenseCategories", err))
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+func createExpenseCategory(c *gin.Context) {
+	var request models.CreateExpenseCategoryRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	category, err := service.CreateExpenseCategory(request)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("createExpenseCategory", err))
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
+
+func updateExpenseCategory(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.UpdateExpenseCategoryRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateExpenseCategory", err))
+				return
+			}
+			err = service.UpdateExpenseCategory(id, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateExpenseCategory", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteExpenseCategory(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpenseCategory", err))
+			return
+		}
+		err = service.DeleteExpenseCategory(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpenseCategory", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func mergeExpenseCategory(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.MergeExpenseCategoryRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mergeExpenseCategory", err))
+				return
+			}
+			err = service.MergeExpenseCategory(id, request.TargetID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mergeExpenseCategory", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func createExpenseCategoryAlias(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.ExpenseCategoryAliasRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("createExpenseCategoryAlias", err))
+				return
+			}
+			alias, err := service.CreateExpenseCategoryAlias(id, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("createExpenseCategoryAlias", err))
+				return
+			}
+			c.JSON(http.StatusCreated, alias)
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteExpenseCategoryAlias(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpenseCategoryAlias", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpenseCategoryAlias", err))
+			return
+		}
+		err = service.DeleteExpenseCategoryAlias(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpenseCategoryAlias", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
@@ -3,8 +3,14 @@ package controllers
 import (
 	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"hammond/common"
 	"hammond/db"
@@ -12,6 +18,7 @@ import (
 	"hammond/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func RegisterFilesController(router *gin.RouterGroup) {
@@ -21,9 +28,23 @@ func RegisterFilesController(router *gin.RouterGroup) {
 	router.GET("/me/quickEntries", getMyQuickEntries)
 	router.GET("/quickEntries/:id", getQuickEntryById)
 	router.POST("/quickEntries/:id/process", setQuickEntryAsProcessed)
+	router.GET("/quickEntries/:id/fillupDefaults", getQuickEntryFillupDefaults)
+	router.POST("/quickEntries/:id/convert", convertQuickEntry)
 	router.DELETE("/quickEntries/:id", deleteQuickEntryById)
 
 	router.GET("/attachments/:id/file", getAttachmentFile)
+	router.GET("/attachments/:id/thumbnail", getAttachmentThumbnail)
+	router.GET("/attachments/:id/signedUrl", getAttachmentSignedUrl)
+	router.POST("/attachments/settings", ShouldBeAdmin(), updateUploadSettings)
+	router.POST("/garbageCollection", ShouldBeAdmin(), collectGarbage)
+	router.POST("/garbageCollection/settings", ShouldBeAdmin(), updateGarbageCollectionSettings)
+}
+
+// RegisterAnonFilesController serves the signed links to attachments, which
+// carry their own authorization.
+func RegisterAnonFilesController(router *gin.RouterGroup) {
+	router.GET("/attachments/:id/signed/file", getSignedAttachmentFile)
+	router.GET("/attachments/:id/signed/thumbnail", getSignedAttachmentThumbnail)
 }
 
 func createQuickEntry(c *gin.Context) {
@@ -34,7 +55,7 @@ func createQuickEntry(c *gin.Context) {
 	}
 	attachment, err := saveUploadedFile(c, "file")
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, err)
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("createQuickEntry", err))
 		return
 	}
 	if err := c.ShouldBind(&request); err != nil {
@@ -47,7 +68,7 @@ func createQuickEntry(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, common.NewError("createQuickEntry", errors.New("userId is not a valid uuid")))
 		return
 	}
-	quickEntry, err := service.CreateQuickEntry(request, attachment.ID, id)
+	quickEntry, err := service.CreateQuickEntry(request, attachment, id)
 	if err != nil {
 		c.JSON(http.StatusUnprocessableEntity, common.NewError("createQuickEntry", err))
 		return
@@ -136,49 +157,292 @@ func setQuickEntryAsProcessed(c *gin.Context) {
 	}
 }
 
+func getQuickEntryFillupDefaults(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getQuickEntryFillupDefaults", err))
+			return
+		}
+		defaults, err := service.GetQuickEntryFillupDefaults(userId, id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getQuickEntryFillupDefaults", err))
+			return
+		}
+		c.JSON(http.StatusOK, defaults)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func convertQuickEntry(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.ConvertQuickEntryRequest
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("convertQuickEntry", err))
+			return
+		}
+		converted, err := service.ConvertQuickEntry(userId, id, request)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("convertQuickEntry", err))
+			return
+		}
+		c.JSON(http.StatusCreated, converted)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
 func uploadFile(c *gin.Context) {
 	attachment, err := saveMultipleUploadedFile(c, "file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		c.JSON(http.StatusBadRequest, common.NewError("uploadFile", err))
 	} else {
 		c.JSON(http.StatusOK, attachment)
 	}
 }
 
+func updateUploadSettings(c *gin.Context) {
+	var request models.UploadSettingsRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	if err := service.UpdateUploadSettings(request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("updateUploadSettings", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func collectGarbage(c *gin.Context) {
+	var request models.GarbageCollectionRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	report, err := service.CollectGarbageNow(request.CleanUp)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("collectGarbage", err))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func updateGarbageCollectionSettings(c *gin.Context) {
+	var request models.GarbageCollectionSettingsRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	if err := service.UpdateGarbageCollectionSettings(request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("updateGarbageCollectionSettings", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 func getAttachmentFile(c *gin.Context) {
-	var query models.SearchByIDQuery
+	attachment, ok := getAccessibleAttachment(c)
+	if !ok {
+		return
+	}
+	serveAttachmentFile(c, attachment)
+}
 
-	// Bind URI param
-	if err := c.ShouldBindUri(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid or missing ID",
-			"error":   err.Error(),
-		},
-		)
+func getAttachmentThumbnail(c *gin.Context) {
+	var thumbnailQuery models.AttachmentThumbnailQuery
+	if err := c.ShouldBindQuery(&thumbnailQuery); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	attachment, ok := getAccessibleAttachment(c)
+	if !ok {
 		return
 	}
+	serveAttachmentThumbnail(c, attachment, thumbnailQuery.Size)
+}
+
+// getAttachmentSignedUrl hands out links to the file and the thumbnail of an
+// attachment which work without logging in for a little while, for places
+// like img tags which cannot send a token.
+func getAttachmentSignedUrl(c *gin.Context) {
+	var thumbnailQuery models.AttachmentThumbnailQuery
+	if err := c.ShouldBindQuery(&thumbnailQuery); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	attachment, ok := getAccessibleAttachment(c)
+	if !ok {
+		return
+	}
+	size := thumbnailQuery.Size
+	if size == "" {
+		size = service.DefaultThumbnailSize
+	}
+
+	expiresAt := time.Now().Add(service.SignedUrlLifetime).Truncate(time.Second)
+	base := strings.TrimSuffix(c.Request.URL.Path, "/signedUrl")
+	fileQuery := url.Values{}
+	fileQuery.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	fileQuery.Set("signature", service.SignAttachmentUrl(attachment.ID, "", expiresAt))
+	thumbnailParams := url.Values{}
+	thumbnailParams.Set("size", size)
+	thumbnailParams.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	thumbnailParams.Set("signature", service.SignAttachmentUrl(attachment.ID, size, expiresAt))
+	c.JSON(http.StatusOK, models.AttachmentSignedUrlModel{
+		URL:          base + "/signed/file?" + fileQuery.Encode(),
+		ThumbnailURL: base + "/signed/thumbnail?" + thumbnailParams.Encode(),
+		ExpiresAt:    expiresAt,
+	})
+}
 
+func getSignedAttachmentFile(c *gin.Context) {
+	var signedQuery models.SignedAttachmentQuery
+	if err := c.ShouldBindQuery(&signedQuery); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	attachment, ok := getSignedAttachment(c, "", signedQuery)
+	if !ok {
+		return
+	}
+	serveAttachmentFile(c, attachment)
+}
+
+func getSignedAttachmentThumbnail(c *gin.Context) {
+	var signedQuery models.SignedAttachmentQuery
+	if err := c.ShouldBindQuery(&signedQuery); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	if signedQuery.Size == "" {
+		signedQuery.Size = service.DefaultThumbnailSize
+	}
+	attachment, ok := getSignedAttachment(c, signedQuery.Size, signedQuery)
+	if !ok {
+		return
+	}
+	serveAttachmentThumbnail(c, attachment, signedQuery.Size)
+}
+
+// getAccessibleAttachment loads the attachment of the request, as long as the
+// user may see it. Otherwise the response has been written already.
+func getAccessibleAttachment(c *gin.Context) (*db.Attachment, bool) {
+	var query models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return nil, false
+	}
 	id, err := common.ToUUID(query.ID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
-		return
+		return nil, false
+	}
+	userId, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return nil, false
 	}
 
-	// Fetch attachment
 	attachment, err := db.GetAttachmentById(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	if err := service.CheckAttachmentAccess(userId, attachment); err != nil {
+		if errors.Is(err, service.ErrAttachmentForbidden) {
+			c.JSON(http.StatusForbidden, common.NewError("attachment", err))
+			return nil, false
+		}
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("attachment", err))
+		return nil, false
+	}
+	return attachment, true
+}
+
+// getSignedAttachment loads the attachment of a signed link, as long as the
+// link is genuine and still valid.
+func getSignedAttachment(c *gin.Context, size string, signedQuery models.SignedAttachmentQuery) (*db.Attachment, bool) {
+	var query models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return nil, false
+	}
+	id, err := common.ToUUID(query.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return nil, false
+	}
+	if err := service.CheckAttachmentSignature(id, size, signedQuery.Expires, signedQuery.Signature); err != nil {
+		c.JSON(http.StatusForbidden, common.NewError("attachment", err))
+		return nil, false
+	}
+	attachment, err := db.GetAttachmentById(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	return attachment, true
+}
+
+func serveAttachmentFile(c *gin.Context, attachment *db.Attachment) {
+	file, err := service.OpenAttachment(attachment)
+	if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found in storage"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getAttachmentFile", err))
+		return
+	}
+	defer file.Close()
+
+	c.Header("Cache-Control", "private, no-cache")
+	http.ServeContent(c.Writer, c.Request, filepath.Base(attachment.Path), file.ModTime(), file)
+}
 
-	// Check if file exists
-	if _, err := os.Stat(attachment.Path); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found on disk"})
+// serveAttachmentThumbnail sends a small JPEG of an image or of the first page
+// of a PDF. The file of an attachment never changes, so neither does its
+// thumbnail and clients may keep it for good.
+func serveAttachmentThumbnail(c *gin.Context, attachment *db.Attachment, size string) {
+	if size == "" {
+		size = service.DefaultThumbnailSize
+	}
+	thumbnailPath, err := service.GetAttachmentThumbnail(attachment, size)
+	if err != nil {
+		if errors.Is(err, service.ErrNoThumbnail) || os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, common.NewError("getAttachmentThumbnail", err))
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getAttachmentThumbnail", err))
 		return
 	}
 
-	// Serve file
-	c.File(attachment.Path)
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.Header("ETag", `"`+attachment.ID.String()+"-"+size+`"`)
+	c.File(thumbnailPath)
 }
 
 func getFileBytes(c *gin.Context, fileVariable string) ([]byte, error) {
@@ -201,16 +465,12 @@ func saveUploadedFile(c *gin.Context, fileVariable string) (*db.Attachment, erro
 	if err != nil {
 		return nil, err
 	}
-	filePath := service.GetFilePath(file.Filename)
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		return nil, err
-	}
 
 	id, err := common.ToUUID(c.MustGet("userId"))
 	if err != nil {
 		return nil, errors.New("unable to parse user ID")
 	}
-	return service.CreateAttachment(filePath, file.Filename, file.Size, file.Header.Get("Content-Type"), id)
+	return createAttachment(file, id)
 }
 
 func saveMultipleUploadedFile(c *gin.Context, fileVariable string) ([]*db.Attachment, error) {
@@ -224,15 +484,11 @@ func saveMultipleUploadedFile(c *gin.Context, fileVariable string) ([]*db.Attach
 	files := form.File[fileVariable]
 	var toReturn []*db.Attachment
 	for _, file := range files {
-		filePath := service.GetFilePath(file.Filename)
-		if err := c.SaveUploadedFile(file, filePath); err != nil {
-			return nil, err
-		}
 		id, err := common.ToUUID(c.MustGet("userId"))
 		if err != nil {
 			return nil, errors.New("unable to parse user ID")
 		}
-		attachment, err := service.CreateAttachment(filePath, file.Filename, file.Size, file.Header.Get("Content-Type"), id)
+		attachment, err := createAttachment(file, id)
 		if err != nil {
 			return nil, err
 		}
@@ -241,3 +497,12 @@ func saveMultipleUploadedFile(c *gin.Context, fileVariable string) ([]*db.Attach
 	}
 	return toReturn, nil
 }
+
+func createAttachment(file *multipart.FileHeader, userId uuid.UUID) (*db.Attachment, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return service.CreateAttachment(src, file.Filename, file.Size, userId)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterFillingStationController(router *gin.RouterGroup) {
+	router.GET("/fillingStations", getAllFillingStations)
+	router.GET("/fillingStations/autocomplete", autocompleteFillingStations)
+	router.GET("/fillingStations/matches", matchFillingStations)
+	router.GET("/fillingStations/unlinkedNames", ShouldBeAdmin(), getUnlinkedStationNames)
+	router.POST("/fillingStations", ShouldBeAdmin(), createFillingStation)
+	router.PUT("/fillingStations/:id", ShouldBeAdmin(), updateFillingStation)
+	router.DELETE("/fillingStations/:id", ShouldBeAdmin(), deleteFillingStation)
+	router.POST("/fillingStations/:id/merge", ShouldBeAdmin(), mergeFillingStation)
+	router.POST("/fillingStations/:id/names", ShouldBeAdmin(), mergeStationNames)
+	router.DELETE("/fillingStations/:id/aliases/:subId", ShouldBeAdmin(), deleteFillingStationAlias)
+}
+
+func getAllFillingStations(c *gin.Context) {
+	stations, err := service.GetAllFillingStations()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getAllFillingStations", err))
+		return
+	}
+	c.JSON(http.StatusOK, stations)
+}
+
+func autocompleteFillingStations(c *gin.Context) {
+	var model models.FillingStationAutocompleteQuery
+	if err := c.ShouldBind(&model); err == nil {
+		id, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		suggestions, err := service.AutocompleteFillingStations(id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("autocompleteFillingStations", err))
+			return
+		}
+		c.JSON(http.StatusOK, suggestions)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func matchFillingStations(c *gin.Context) {
+	var model models.FillingStationMatchQuery
+	if err := c.ShouldBind(&model); err == nil {
+		matches, err := service.MatchFillingStations(model.Name)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("matchFillingStations", err))
+			return
+		}
+		c.JSON(http.StatusOK, matches)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getUnlinkedStationNames(c *gin.Context) {
+	names, err := service.GetUnlinkedStationNames()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getUnlinkedStationNames", err))
+		return
+	}
+	c.JSON(http.StatusOK, names)
+}
+
+func createFillingStation(c *gin.Context) {
+	var request models.CreateFillingStationRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	station, err := service.CreateFillingStation(request)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("createFillingStation", err))
+		return
+	}
+	c.JSON(http.StatusCreated, station)
+}
+
+func updateFillingStation(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.UpdateFillingStationRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateFillingStation", err))
+				return
+			}
+			err = service.UpdateFillingStation(id, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateFillingStation", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteFillingStation(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillingStation", err))
+			return
+		}
+		err = service.DeleteFillingStation(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillingStation", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func mergeFillingStation(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.MergeFillingStationRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mergeFillingStation", err))
+				return
+			}
+			err = service.MergeFillingStation(id, request.TargetID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mergeFillingStation", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func mergeStationNames(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.FillingStationNamesRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mergeStationNames", err))
+				return
+			}
+			station, err := service.MergeStationNames(id, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mergeStationNames", err))
+				return
+			}
+			c.JSON(http.StatusOK, station)
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteFillingStationAlias(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillingStationAlias", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillingStationAlias", err))
+			return
+		}
+		err = service.DeleteFillingStationAlias(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillingStationAlias", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"net/http"
+	"os"
 	"strconv"
 
 	"hammond/common"
+	"hammond/db"
 	"hammond/models"
 	"hammond/service"
 
@@ -16,6 +18,10 @@ func RegisteImportController(router *gin.RouterGroup) {
 	router.POST("/import/fuelly", fuellyImport)
 	router.POST("/import/drivvo", drivvoImport)
 	router.POST("/import/generic", genericImport)
+	router.POST("/import/hammond", func(c *gin.Context) { hammondImport(c, db.USER_ARCHIVE) })
+	router.POST("/import/hammond/instance", ShouldBeAdmin(), func(c *gin.Context) { hammondImport(c, db.INSTANCE_ARCHIVE) })
+	router.GET("/export/hammond", func(c *gin.Context) { hammondExport(c, db.USER_ARCHIVE) })
+	router.GET("/export/hammond/instance", ShouldBeAdmin(), func(c *gin.Context) { hammondExport(c, db.INSTANCE_ARCHIVE) })
 }
 
 func fuellyImport(c *gin.Context) {
@@ -94,3 +100,43 @@ func genericImport(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+func hammondImport(c *gin.Context, scope db.ArchiveScope) {
+	formFile, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	file, err := formFile.Open()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("hammondImport", err))
+		return
+	}
+	defer file.Close()
+	report, err := service.ImportArchive(file, scope, id)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("hammondImport", err))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func hammondExport(c *gin.Context, scope db.ArchiveScope) {
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	archivePath, name, err := service.ExportArchive(scope, id)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("hammondExport", err))
+		return
+	}
+	defer os.Remove(archivePath)
+	c.FileAttachment(archivePath, name)
+}
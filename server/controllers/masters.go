@@ -14,11 +14,20 @@ import (
 func RegisterAnonMasterConroller(router *gin.RouterGroup) {
 	router.GET("/masters", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"fuelUnits":     db.FuelUnitDetails,
-			"fuelTypes":     db.FuelTypeDetails,
-			"distanceUnits": db.DistanceUnitDetails,
-			"roles":         db.RoleDetails,
-			"currencies":    models.GetCurrencyMasterList(),
+			"fuelUnits":             db.FuelUnitDetails,
+			"fuelTypes":             db.FuelTypeDetails,
+			"distanceUnits":         db.DistanceUnitDetails,
+			"roles":                 db.RoleDetails,
+			"tyreSeasons":           db.TyreSeasonDetails,
+			"expenseLineTypes":      db.ExpenseLineTypeDetails,
+			"recurrenceFrequencies": db.RecurrenceFrequencyDetails,
+			"documentTypes":         db.DocumentTypeDetails,
+			"vehicleStatuses":       db.VehicleStatusDetails,
+			"customFieldTypes":      db.CustomFieldTypeDetails,
+			"customFieldEntities":   db.CustomFieldEntityDetails,
+			"auditActions":          db.AuditActionDetails,
+			"auditEntities":         db.AuditEntityDetails,
+			"currencies":            models.GetCurrencyMasterList(),
 		})
 	})
 }
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterNotificationController(router *gin.RouterGroup) {
+	router.GET("/me/notifications", getMyNotifications)
+	router.POST("/me/notifications/:id/read", markNotificationAsRead)
+}
+
+func getMyNotifications(c *gin.Context) {
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	notifications, err := service.GetNotificationsByUserId(id)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyNotifications", err))
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+func markNotificationAsRead(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("markNotificationAsRead", err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		err = service.MarkNotificationAsRead(id, userId)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("markNotificationAsRead", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterOdometerReplacementController(router *gin.RouterGroup) {
+	router.GET("/vehicles/:id/odometerReplacements", getOdometerReplacementsByVehicleId)
+	router.POST("/vehicles/:id/odometerReplacements", createOdometerReplacement)
+	router.GET("/vehicles/:id/odometerReplacements/:subId", getOdometerReplacementById)
+	router.PUT("/vehicles/:id/odometerReplacements/:subId", updateOdometerReplacement)
+	router.DELETE("/vehicles/:id/odometerReplacements/:subId", deleteOdometerReplacement)
+}
+
+func getOdometerReplacementsByVehicleId(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getOdometerReplacementsByVehicleId", err))
+			return
+		}
+		data, err := service.GetOdometerReplacementsByVehicleId(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getOdometerReplacementsByVehicleId", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func createOdometerReplacement(c *gin.Context) {
+	var request models.OdometerReplacementRequest
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createOdometerReplacement", err))
+			return
+		}
+		odometerReplacement, err := service.CreateOdometerReplacement(id, request)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createOdometerReplacement", err))
+			return
+		}
+		c.JSON(http.StatusCreated, odometerReplacement)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getOdometerReplacementById(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getOdometerReplacementById", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getOdometerReplacementById", err))
+			return
+		}
+		obj, err := service.GetOdometerReplacementById(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getOdometerReplacementById", err))
+			return
+		}
+		c.JSON(http.StatusOK, obj)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func updateOdometerReplacement(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.OdometerReplacementRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateOdometerReplacement", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateOdometerReplacement", err))
+				return
+			}
+			err = service.UpdateOdometerReplacement(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateOdometerReplacement", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteOdometerReplacement(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteOdometerReplacement", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteOdometerReplacement", err))
+			return
+		}
+		err = service.DeleteOdometerReplacement(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteOdometerReplacement", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
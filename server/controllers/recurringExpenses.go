@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRecurringExpenseController(router *gin.RouterGroup) {
+	router.GET("/vehicles/:id/recurringExpenses", getRecurringExpensesByVehicleId)
+	router.POST("/vehicles/:id/recurringExpenses", createRecurringExpense)
+	router.GET("/vehicles/:id/recurringExpenses/:subId", getRecurringExpenseById)
+	router.PUT("/vehicles/:id/recurringExpenses/:subId", updateRecurringExpense)
+	router.DELETE("/vehicles/:id/recurringExpenses/:subId", deleteRecurringExpense)
+	router.GET("/vehicles/:id/recurringExpenses/:subId/occurrences", getRecurringExpenseSchedule)
+	router.PUT("/vehicles/:id/recurringExpenses/:subId/occurrences", setRecurringExpenseOccurrence)
+}
+
+func getRecurringExpensesByVehicleId(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpensesByVehicleId", err))
+			return
+		}
+		data, err := service.GetRecurringExpensesByVehicleId(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpensesByVehicleId", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func createRecurringExpense(c *gin.Context) {
+	var request models.CreateRecurringExpenseRequest
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createRecurringExpense", err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		recurringExpense, err := service.CreateRecurringExpense(id, userId, request)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createRecurringExpense", err))
+			return
+		}
+		c.JSON(http.StatusCreated, recurringExpense)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getRecurringExpenseById(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpenseById", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpenseById", err))
+			return
+		}
+		obj, err := service.GetRecurringExpenseById(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpenseById", err))
+			return
+		}
+		c.JSON(http.StatusOK, obj)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func updateRecurringExpense(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.UpdateRecurringExpenseRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateRecurringExpense", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateRecurringExpense", err))
+				return
+			}
+			err = service.UpdateRecurringExpense(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateRecurringExpense", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteRecurringExpense(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteRecurringExpense", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteRecurringExpense", err))
+			return
+		}
+		err = service.DeleteRecurringExpense(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteRecurringExpense", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getRecurringExpenseSchedule(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var query models.RecurringExpenseScheduleQuery
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBindQuery(&query); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpenseSchedule", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpenseSchedule", err))
+				return
+			}
+			schedule, err := service.GetRecurringExpenseSchedule(id, subID, query.Count)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("getRecurringExpenseSchedule", err))
+				return
+			}
+			c.JSON(http.StatusOK, schedule)
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func setRecurringExpenseOccurrence(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.RecurringExpenseOccurrenceRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("setRecurringExpenseOccurrence", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("setRecurringExpenseOccurrence", err))
+				return
+			}
+			err = service.SetRecurringExpenseOccurrence(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("setRecurringExpenseOccurrence", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
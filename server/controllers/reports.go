@@ -12,6 +12,11 @@ import (
 
 func RegisterReportsController(router *gin.RouterGroup) {
 	router.GET("/vehicles/:id/mileage", getMileageForVehicle)
+	router.GET("/vehicles/:id/mileage/tags", getTagMileageForVehicle)
+	router.GET("/vehicles/:id/chargingCost", getChargingCostForVehicle)
+	router.GET("/me/chargingCost", getMyChargingCost)
+	router.GET("/vehicles/:id/fillupLocations", getFillupLocationsForVehicle)
+	router.GET("/me/fillupLocations", getMyFillupLocations)
 }
 
 func getMileageForVehicle(c *gin.Context) {
@@ -30,7 +35,7 @@ func getMileageForVehicle(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getMileageForVehicle", err))
 			return
 		}
-		fillups, err := service.GetMileageByVehicleId(id, model.Since, model.MileageOption)
+		fillups, err := service.GetMileageByVehicleId(id, model.Since, model.MileageOption, model.Tags)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getMileageForVehicle", err))
 			return
@@ -40,3 +45,119 @@ func getMileageForVehicle(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
 	}
 }
+
+func getTagMileageForVehicle(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		var model models.MileageQueryModel
+		err := c.BindQuery(&model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTagMileageForVehicle", err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTagMileageForVehicle", err))
+			return
+		}
+		data, err := service.GetTagMileageByVehicleId(id, model.Since, model.MileageOption)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTagMileageForVehicle", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getChargingCostForVehicle(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		var model models.ChargingCostQueryModel
+		err := c.BindQuery(&model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getChargingCostForVehicle", err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getChargingCostForVehicle", err))
+			return
+		}
+		data, err := service.GetChargingCostForVehicle(id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getChargingCostForVehicle", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getMyChargingCost(c *gin.Context) {
+	var model models.ChargingCostQueryModel
+	if err := c.ShouldBind(&model); err == nil {
+		id, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		data, err := service.GetChargingCostForUser(id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyChargingCost", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getFillupLocationsForVehicle(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		var model models.FillupLocationQueryModel
+		err := c.BindQuery(&model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupLocationsForVehicle", err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupLocationsForVehicle", err))
+			return
+		}
+		data, err := service.GetFillupLocationsForVehicle(id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupLocationsForVehicle", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getMyFillupLocations(c *gin.Context) {
+	var model models.FillupLocationQueryModel
+	if err := c.ShouldBind(&model); err == nil {
+		id, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		data, err := service.GetFillupLocationsForUser(id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyFillupLocations", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTagController(router *gin.RouterGroup) {
+	router.GET("/tags", getAllTags)
+}
+
+func getAllTags(c *gin.Context) {
+	tags, err := service.GetAllTags()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getAllTags", err))
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func RegisterTariffController(router *gin.RouterGroup) {
+	router.POST("/tariffs", createElectricityTariff)
+	router.GET("/me/tariffs", getMyElectricityTariffs)
+	router.GET("/tariffs/:id", getElectricityTariffById)
+	router.PUT("/tariffs/:id", updateElectricityTariff)
+	router.DELETE("/tariffs/:id", deleteElectricityTariff)
+}
+
+func createElectricityTariff(c *gin.Context) {
+	var request models.CreateElectricityTariffRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	tariff, err := service.CreateElectricityTariff(request, id)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("createElectricityTariff", err))
+		return
+	}
+	c.JSON(http.StatusCreated, tariff)
+}
+
+func getMyElectricityTariffs(c *gin.Context) {
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	tariffs, err := service.GetElectricityTariffsForUser(id)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyElectricityTariffs", err))
+		return
+	}
+	c.JSON(http.StatusOK, tariffs)
+}
+
+func getElectricityTariffById(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := getOwnTariffId(c, searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getElectricityTariffById", err))
+			return
+		}
+		tariff, err := service.GetElectricityTariffById(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getElectricityTariffById", err))
+			return
+		}
+		c.JSON(http.StatusOK, tariff)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func updateElectricityTariff(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.UpdateElectricityTariffRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := getOwnTariffId(c, searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateElectricityTariff", err))
+				return
+			}
+			err = service.UpdateElectricityTariff(id, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateElectricityTariff", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteElectricityTariff(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := getOwnTariffId(c, searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteElectricityTariff", err))
+			return
+		}
+		err = service.DeleteElectricityTariffById(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteElectricityTariff", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getOwnTariffId(c *gin.Context, value string) (uuid.UUID, error) {
+	id, err := common.ToUUID(value)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userId, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	canAccess, err := service.CanAccessElectricityTariff(id, userId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !canAccess {
+		return uuid.Nil, errors.New("you are not allowed to access this tariff")
+	}
+	return id, nil
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTrashController(router *gin.RouterGroup) {
+	router.GET("/me/trash", getMyTrash)
+	router.POST("/me/trash/vehicles/:id/restore", restoreVehicle)
+	router.POST("/me/trash/vehicles/:id/purge", purgeVehicle)
+	router.GET("/me/vehicleArchives/:name", getVehicleArchive)
+	router.POST("/me/trash/fillups/:id/restore", restoreFillup)
+	router.POST("/me/trash/expenses/:id/restore", restoreExpense)
+	router.POST("/trash/settings", ShouldBeAdmin(), updateTrashSettings)
+	router.POST("/trash/purge", ShouldBeAdmin(), purgeTrash)
+}
+
+func getMyTrash(c *gin.Context) {
+	id, err := common.ToUUID(c.MustGet("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	trash, err := service.GetUserTrash(id)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyTrash", err))
+		return
+	}
+	c.JSON(http.StatusOK, trash)
+}
+
+func restoreVehicle(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("restoreVehicle", err))
+			return
+		}
+		err = service.RestoreVehicle(userId, id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("restoreVehicle", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func purgeVehicle(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.PurgeVehicleRequest
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("purgeVehicle", err))
+			return
+		}
+		if _, err := service.GetTrashedVehicleById(id); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("purgeVehicle", err))
+			return
+		}
+		purge, err := service.PurgeVehicle(userId, id, request.Archive)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("purgeVehicle", err))
+			return
+		}
+		c.JSON(http.StatusOK, purge)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getVehicleArchive(c *gin.Context) {
+	var archiveQuery models.VehicleArchiveQuery
+
+	if err := c.ShouldBindUri(&archiveQuery); err == nil {
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		archivePath, err := service.GetVehicleArchivePath(userId, archiveQuery.Name)
+		if err != nil {
+			c.JSON(http.StatusNotFound, common.NewError("getVehicleArchive", err))
+			return
+		}
+		c.FileAttachment(archivePath, archiveQuery.Name)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func restoreFillup(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("restoreFillup", err))
+			return
+		}
+		err = service.RestoreFillup(userId, id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("restoreFillup", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func restoreExpense(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("restoreExpense", err))
+			return
+		}
+		err = service.RestoreExpense(userId, id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("restoreExpense", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func updateTrashSettings(c *gin.Context) {
+	var request models.TrashSettingsRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
+	if err := service.UpdateTrashSettings(request); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("updateTrashSettings", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func purgeTrash(c *gin.Context) {
+	purge, err := service.PurgeTrashNow()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("purgeTrash", err))
+		return
+	}
+	c.JSON(http.StatusOK, purge)
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"net/http"
+
+	"hammond/common"
+	"hammond/models"
+	"hammond/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTyreController(router *gin.RouterGroup) {
+	router.GET("/vehicles/:id/tyres", getTyreSetsByVehicleId)
+	router.POST("/vehicles/:id/tyres", createTyreSet)
+	router.GET("/vehicles/:id/tyres/:subId", getTyreSetById)
+	router.PUT("/vehicles/:id/tyres/:subId", updateTyreSet)
+	router.DELETE("/vehicles/:id/tyres/:subId", deleteTyreSet)
+	router.POST("/vehicles/:id/tyres/:subId/mount", mountTyreSet)
+	router.POST("/vehicles/:id/tyres/:subId/unmount", unmountTyreSet)
+	router.POST("/vehicles/:id/tyres/:subId/treadDepths", createTyreTreadDepth)
+}
+
+func getTyreSetsByVehicleId(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTyreSetsByVehicleId", err))
+			return
+		}
+		data, err := service.GetTyreSetsByVehicleId(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTyreSetsByVehicleId", err))
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func createTyreSet(c *gin.Context) {
+	var request models.CreateTyreSetRequest
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createTyreSet", err))
+			return
+		}
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		tyreSet, err := service.CreateTyreSet(id, userId, request)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createTyreSet", err))
+			return
+		}
+		c.JSON(http.StatusCreated, tyreSet)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getTyreSetById(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTyreSetById", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTyreSetById", err))
+			return
+		}
+		obj, err := service.GetTyreSetById(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getTyreSetById", err))
+			return
+		}
+		c.JSON(http.StatusOK, obj)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func updateTyreSet(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.UpdateTyreSetRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateTyreSet", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateTyreSet", err))
+				return
+			}
+			err = service.UpdateTyreSet(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateTyreSet", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func deleteTyreSet(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteTyreSet", err))
+			return
+		}
+		subID, err := common.ToUUID(searchByIdQuery.SubID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteTyreSet", err))
+			return
+		}
+		err = service.DeleteTyreSet(id, subID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteTyreSet", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func mountTyreSet(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.TyreMountingRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mountTyreSet", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mountTyreSet", err))
+				return
+			}
+			err = service.MountTyreSet(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("mountTyreSet", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func unmountTyreSet(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.TyreMountingRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("unmountTyreSet", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("unmountTyreSet", err))
+				return
+			}
+			err = service.UnmountTyreSet(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("unmountTyreSet", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func createTyreTreadDepth(c *gin.Context) {
+	var searchByIdQuery models.SubItemQuery
+	var request models.CreateTyreTreadDepthRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("createTyreTreadDepth", err))
+				return
+			}
+			subID, err := common.ToUUID(searchByIdQuery.SubID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("createTyreTreadDepth", err))
+				return
+			}
+			treadDepth, err := service.CreateTyreTreadDepth(id, subID, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("createTyreTreadDepth", err))
+				return
+			}
+			c.JSON(http.StatusCreated, treadDepth)
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
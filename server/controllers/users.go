@@ -35,7 +35,12 @@ func enableUser(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, err)
 			return
 		}
-		err = service.SetDisabledStatusForUser(id, false)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, err)
+			return
+		}
+		err = service.SetDisabledStatusForUser(id, false, userId)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, err)
 			return
@@ -55,7 +60,12 @@ func disableUser(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, err)
 			return
 		}
-		err = service.SetDisabledStatusForUser(id, true)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, err)
+			return
+		}
+		err = service.SetDisabledStatusForUser(id, true, userId)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, err)
 			return
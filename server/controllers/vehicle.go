@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"hammond/common"
+	"hammond/db"
 	"hammond/models"
 	"hammond/service"
 
@@ -18,13 +19,16 @@ func RegisterVehicleController(router *gin.RouterGroup) {
 	router.PUT("/vehicles/:id", updateVehicle)
 	router.DELETE("/vehicles/:id", deleteVehicle)
 	router.GET("/vehicles/:id/stats", getVehicleStats)
+	router.GET("/vehicles/:id/stats/tags", getVehicleTagStats)
 	router.GET("/vehicles/:id/users", getVehicleUsers)
 	router.POST("/vehicles/:id/users/:subId", shareVehicle)
 	router.DELETE("/vehicles/:id/users/:subId", unshareVehicle)
 	router.POST("/vehicles/:id/users/:subId/transfer", transferVehicle)
+	router.POST("/vehicles/:id/status", setVehicleStatus)
 
 	router.GET("/me/vehicles", getMyVehicles)
 	router.GET("/me/stats", getMystats)
+	router.GET("/me/stats/tags", getMyTagStats)
 
 	router.GET("/vehicles/:id/fillups", getFillupsByVehicleId)
 	router.GET("/vehicles/:id/fuelSubTypes", getFuelSubTypesByVehicleId)
@@ -38,6 +42,7 @@ func RegisterVehicleController(router *gin.RouterGroup) {
 	router.GET("/vehicles/:id/expenses/:subId", getExpenseById)
 	router.PUT("/vehicles/:id/expenses/:subId", updateExpense)
 	router.DELETE("/vehicles/:id/expenses/:subId", deleteExpense)
+	router.GET("/vehicles/:id/parts", getPartHistory)
 
 	router.POST("/vehicles/:id/attachments", createVehicleAttachment)
 	router.GET("/vehicles/:id/attachments", getVehicleAttachments)
@@ -76,7 +81,12 @@ func getVehicleById(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleById", err))
 			return
 		}
-		c.JSON(http.StatusOK, vehicle)
+		vehicles := []db.Vehicle{*vehicle}
+		if err := service.SetVehiclesCustomFields(vehicles); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleById", err))
+			return
+		}
+		c.JSON(http.StatusOK, &vehicles[0])
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 	}
@@ -92,7 +102,12 @@ func updateVehicle(c *gin.Context) {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleById", err))
 				return
 			}
-			err = service.UpdateVehicle(id, updateVehicleModel)
+			userId, err := common.ToUUID(c.MustGet("userId"))
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateVehicle", err))
+				return
+			}
+			err = service.UpdateVehicle(id, updateVehicleModel, userId)
 			if err != nil {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleById", err))
 				return
@@ -117,16 +132,30 @@ func getAllVehicles(c *gin.Context) {
 }
 
 func getMyVehicles(c *gin.Context) {
+	var query models.VehicleListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		return
+	}
 	id, err := common.ToUUID(c.MustGet("userId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{})
 	}
-	vehicles, err := service.GetUserVehicles(id)
+	vehicles, err := service.GetUserVehicles(id, query.IncludeInactive)
 	if err != nil {
 		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyVehicles", err))
 		return
 	}
-	c.JSON(200, vehicles)
+	if err := service.SetVehiclesCustomFields(*vehicles); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyVehicles", err))
+		return
+	}
+	filtered, err := service.FilterVehiclesByCustomFields(*vehicles, c.QueryMap("customFields"))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyVehicles", err))
+		return
+	}
+	c.JSON(200, filtered)
 
 }
 
@@ -135,6 +164,11 @@ func getFillupsByVehicleId(c *gin.Context) {
 	var searchByIdQuery models.SearchByIDQuery
 
 	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		var query models.TagFilterQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
 		id, err := common.ToUUID(searchByIdQuery.ID)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupsByVehicleId", err))
@@ -145,7 +179,13 @@ func getFillupsByVehicleId(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupsByVehicleId", err))
 			return
 		}
-		c.JSON(http.StatusOK, fillups)
+		filtered, err := service.FilterFillupsByCustomFields(service.FilterFillupsByTags(*fillups, query.Tags), c.QueryMap("customFields"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupsByVehicleId", err))
+			return
+		}
+		service.ConvertFillupsDistanceUnit(filtered, c.MustGet("userModel").(db.User).DistanceUnit)
+		c.JSON(http.StatusOK, filtered)
 	} else {
 		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
 	}
@@ -177,6 +217,11 @@ func getExpensesByVehicleId(c *gin.Context) {
 	var searchByIdQuery models.SearchByIDQuery
 
 	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		var query models.TagFilterQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
 		id, err := common.ToUUID(searchByIdQuery.ID)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getExpensesByVehicleId", err))
@@ -187,6 +232,38 @@ func getExpensesByVehicleId(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getExpensesByVehicleId", err))
 			return
 		}
+		filtered, err := service.FilterExpensesByCustomFields(service.FilterExpensesByTags(*data, query.Tags), c.QueryMap("customFields"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getExpensesByVehicleId", err))
+			return
+		}
+		service.ConvertExpensesDistanceUnit(filtered, c.MustGet("userModel").(db.User).DistanceUnit)
+		c.JSON(http.StatusOK, filtered)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getPartHistory(c *gin.Context) {
+
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		var query models.PartSearchQuery
+		if err := c.BindQuery(&query); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getPartHistory", err))
+			return
+		}
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getPartHistory", err))
+			return
+		}
+		data, err := service.GetPartHistory(id, query)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getPartHistory", err))
+			return
+		}
 		c.JSON(http.StatusOK, data)
 	} else {
 		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
@@ -202,7 +279,12 @@ func createFillup(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
 			return
 		}
-		fillup, err := service.CreateFillup(request)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createFillup", err))
+			return
+		}
+		fillup, err := service.CreateFillup(request, userId)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("createFillup", err))
 			return
@@ -222,7 +304,12 @@ func createExpense(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
 			return
 		}
-		expense, err := service.CreateExpense(request)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("createExpense", err))
+			return
+		}
+		expense, err := service.CreateExpense(request, userId)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("createExpense", err))
 			return
@@ -243,7 +330,8 @@ func updateExpense(c *gin.Context) {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateExpense", err))
 				return
 			}
-			err = service.UpdateExpense(id, updateExpenseModel)
+			user := c.MustGet("userModel").(db.User)
+			err = service.UpdateExpense(id, updateExpenseModel, &user)
 			if err != nil {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateExpense", err))
 				return
@@ -267,7 +355,8 @@ func updateFillup(c *gin.Context) {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateFillup", err))
 				return
 			}
-			err = service.UpdateFillup(id, updateFillupModel)
+			user := c.MustGet("userModel").(db.User)
+			err = service.UpdateFillup(id, updateFillupModel, &user)
 			if err != nil {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("updateFillup", err))
 				return
@@ -290,7 +379,12 @@ func deleteExpense(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpense", err))
 			return
 		}
-		err = service.DeleteExpenseById(id)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpense", err))
+			return
+		}
+		err = service.DeleteExpenseById(id, userId)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteExpense", err))
 			return
@@ -311,7 +405,12 @@ func deleteFillup(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillup", err))
 			return
 		}
-		err = service.DeleteFillupById(id)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillup", err))
+			return
+		}
+		err = service.DeleteFillupById(id, userId)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteFillup", err))
 			return
@@ -337,6 +436,7 @@ func getExpenseById(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getExpenseById", err))
 			return
 		}
+		service.ConvertExpenseDistanceUnit(obj, c.MustGet("userModel").(db.User).DistanceUnit)
 		c.JSON(http.StatusOK, obj)
 
 	} else {
@@ -358,6 +458,7 @@ func getFillupById(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getFillupById", err))
 			return
 		}
+		service.ConvertFillupDistanceUnit(obj, c.MustGet("userModel").(db.User).DistanceUnit)
 		c.JSON(http.StatusOK, obj)
 
 	} else {
@@ -380,6 +481,9 @@ func createVehicleAttachment(c *gin.Context) {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("createVehicleAttachment", err))
 				return
 			}
+			if !checkVehicleAccess(c, vehicle.ID, "createVehicleAttachment") {
+				return
+			}
 			attachment, err := saveUploadedFile(c, "file")
 			if err != nil {
 				c.JSON(http.StatusUnprocessableEntity, common.NewError("createVehicleAttachment", err))
@@ -413,6 +517,9 @@ func getVehicleAttachments(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleAttachments", err))
 			return
 		}
+		if !checkVehicleAccess(c, vehicle.ID, "getVehicleAttachments") {
+			return
+		}
 
 		attachments, err := service.GetVehicleAttachments(vehicle.ID)
 		if err != nil {
@@ -468,8 +575,13 @@ func getVehicleUsers(c *gin.Context) {
 
 func deleteVehicle(c *gin.Context) {
 	var searchByIdQuery models.SearchByIDQuery
+	var deleteQuery models.DeleteVehicleQuery
 
 	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBindQuery(&deleteQuery); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
 
 		id, err := common.ToUUID(c.MustGet("userId"))
 		if err != nil {
@@ -490,7 +602,16 @@ func deleteVehicle(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteVehicle", errors.New("you are not allowed to delete this vehicle")))
 			return
 		}
-		err = service.DeleteVehicle(searchID)
+		if deleteQuery.Permanent {
+			purge, err := service.PurgeVehicle(id, searchID, deleteQuery.Archive)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteVehicle", err))
+				return
+			}
+			c.JSON(http.StatusOK, purge)
+			return
+		}
+		err = service.DeleteVehicle(searchID, id)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("deleteVehicle", err))
 			return
@@ -517,7 +638,12 @@ func shareVehicle(c *gin.Context) {
 			return
 		}
 
-		err = service.ShareVehicle(id, subID)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("shareVehicle", err))
+			return
+		}
+		err = service.ShareVehicle(id, subID, userId)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("shareVehicle", err))
 			return
@@ -561,6 +687,35 @@ func transferVehicle(c *gin.Context) {
 	}
 }
 
+func setVehicleStatus(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+	var request models.VehicleStatusRequest
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		if err := c.ShouldBind(&request); err == nil {
+			id, err := common.ToUUID(searchByIdQuery.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("setVehicleStatus", err))
+				return
+			}
+			userId, err := common.ToUUID(c.MustGet("userId"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{})
+				return
+			}
+			err = service.SetVehicleStatus(id, userId, request)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, common.NewError("setVehicleStatus", err))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+		}
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
 func unshareVehicle(c *gin.Context) {
 	var searchByIdQuery models.SubItemQuery
 
@@ -575,7 +730,12 @@ func unshareVehicle(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("unShareVehicle", err))
 			return
 		}
-		err = service.UnshareVehicle(id, subID)
+		userId, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("unShareVehicle", err))
+			return
+		}
+		err = service.UnshareVehicle(id, subID, userId)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("unShareVehicle", err))
 			return
@@ -596,16 +756,58 @@ func getVehicleStats(c *gin.Context) {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleStats", err))
 			return
 		}
-		vehicle, err := service.GetVehicleById(id)
+		var query models.TagFilterQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+			return
+		}
+		stats, err := service.GetVehicleStats(id, query.Tags)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleStats", err))
 			return
 		}
 
-		model := models.VehicleStatsModel{}
+		c.JSON(http.StatusOK, stats)
 
-		c.JSON(http.StatusOK, model.SetStats(&vehicle.Fillups, &vehicle.Expenses))
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
+
+func getVehicleTagStats(c *gin.Context) {
+	var searchByIdQuery models.SearchByIDQuery
+
+	if err := c.ShouldBindUri(&searchByIdQuery); err == nil {
+		id, err := common.ToUUID(searchByIdQuery.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleTagStats", err))
+			return
+		}
+		stats, err := service.GetVehicleTagStats(id)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getVehicleTagStats", err))
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	} else {
+		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
+	}
+}
 
+func getMyTagStats(c *gin.Context) {
+	var model models.UserStatsQueryModel
+	if err := c.ShouldBind(&model); err == nil {
+		id, err := common.ToUUID(c.MustGet("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{})
+			return
+		}
+		stats, err := service.GetUserTagStats(id, model)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, common.NewError("getMyTagStats", err))
+			return
+		}
+		c.JSON(http.StatusOK, stats)
 	} else {
 		c.JSON(http.StatusUnprocessableEntity, common.NewValidatorError(err))
 	}
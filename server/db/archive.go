@@ -0,0 +1,255 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ArchiveFormat names the portable archives of Hammond. An archive is a
+// gzipped tarball of ArchiveManifestName followed by the files of the
+// attachments under attachments/. ArchiveVersion goes up whenever the manifest
+// changes in a way older versions can't read.
+const (
+	ArchiveFormat       = "hammond-archive"
+	ArchiveVersion      = 1
+	ArchiveManifestName = "manifest.json"
+)
+
+// ArchiveScope tells whether an archive holds the data of one user or of the
+// whole instance.
+type ArchiveScope string
+
+const (
+	USER_ARCHIVE     ArchiveScope = "user"
+	INSTANCE_ARCHIVE ArchiveScope = "instance"
+)
+
+// ArchiveManifest is everything in an archive but the files. Records keep the
+// ids they had where they were exported, which are only used to tie them
+// together; they get new ones when imported. Entries in the trash, quick
+// entries, notifications, the audit log, custom field definitions and the
+// settings are not archived.
+type ArchiveManifest struct {
+	Format             string              `json:"format"`
+	Version            int                 `json:"version"`
+	Scope              ArchiveScope        `json:"scope"`
+	CreatedAt          time.Time           `json:"createdAt"`
+	ExportedBy         uuid.UUID           `json:"exportedBy"`
+	Users              []ArchiveUser       `json:"users"`
+	ExpenseCategories  []ExpenseCategory   `json:"expenseCategories"`
+	FillingStations    []FillingStation    `json:"fillingStations"`
+	ElectricityTariffs []ElectricityTariff `json:"electricityTariffs"`
+	Attachments        []ArchiveAttachment `json:"attachments"`
+	Vehicles           []ArchiveVehicle    `json:"vehicles"`
+}
+
+// ArchiveUser is a user the records of an archive refer to. Users are matched
+// by their email when the archive of an instance is imported, the archive of a
+// user goes to the importing user whoever it names. The password hash is only
+// archived along with the whole instance.
+type ArchiveUser struct {
+	ID           uuid.UUID    `json:"id"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	Email        string       `json:"email"`
+	Name         string       `json:"name"`
+	Role         Role         `json:"role"`
+	Currency     string       `json:"currency"`
+	DistanceUnit DistanceUnit `json:"distanceUnit"`
+	DateFormat   string       `json:"dateFormat"`
+	IsDisabled   bool         `json:"isDisabled"`
+	PasswordHash string       `json:"passwordHash,omitempty"`
+}
+
+// ArchiveAttachment describes an archived file. File is its name in the
+// archive; files which were already missing from storage are not in it.
+type ArchiveAttachment struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	OriginalName string    `json:"originalName"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Hash         string    `json:"hash"`
+	UserID       uuid.UUID `json:"userId"`
+	File         string    `json:"file"`
+}
+
+// ArchiveShare is a user a vehicle is shared with, or its owner.
+type ArchiveShare struct {
+	UserID  uuid.UUID `json:"userId"`
+	IsOwner bool      `json:"isOwner"`
+}
+
+// ArchiveVehicle is a vehicle with everything recorded against it. Fillups
+// and expenses carry their tags, line items and custom fields.
+type ArchiveVehicle struct {
+	Vehicle                     Vehicle                      `json:"vehicle"`
+	Shares                      []ArchiveShare               `json:"shares"`
+	Fillups                     []Fillup                     `json:"fillups"`
+	Expenses                    []Expense                    `json:"expenses"`
+	RecurringExpenses           []RecurringExpense           `json:"recurringExpenses"`
+	RecurringExpenseOccurrences []RecurringExpenseOccurrence `json:"recurringExpenseOccurrences"`
+	OdometerReplacements        []OdometerReplacement        `json:"odometerReplacements"`
+	Alerts                      []VehicleAlert               `json:"alerts"`
+	AlertOccurrences            []AlertOccurance             `json:"alertOccurrences"`
+	Documents                   []VehicleDocument            `json:"documents"`
+	TyreSets                    []TyreSet                    `json:"tyreSets"`
+	Attachments                 []VehicleAttachment          `json:"attachments"`
+	FillupAttachments           []FillupAttachment           `json:"fillupAttachments"`
+	ExpenseAttachments          []ExpenseAttachment          `json:"expenseAttachments"`
+}
+
+// GetArchiveVehicleIds lists the vehicles to archive, those the user has
+// access to or every one when no user is given. Vehicles in the trash are left
+// out.
+func GetArchiveVehicleIds(userId *uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	query := DB.Model(&Vehicle{}).Order("created_at")
+	if userId != nil {
+		query = query.Where("id IN (?)", DB.Model(&UserVehicle{}).Select("vehicle_id").Where("user_id = ?", *userId))
+	}
+	result := query.Pluck("id", &ids)
+	return ids, result.Error
+}
+
+// GetArchiveVehicle loads a vehicle with everything recorded against it, but
+// its custom fields.
+func GetArchiveVehicle(vehicleId uuid.UUID) (*ArchiveVehicle, error) {
+	return getArchiveVehicle(DB, vehicleId)
+}
+
+// GetTrashedArchiveVehicle is GetArchiveVehicle for a vehicle which may be in
+// the trash, as it was before it went there. The fillups and expenses deleted
+// with it are taken out of the trash, those deleted on their own before stay
+// in it.
+func GetTrashedArchiveVehicle(vehicleId uuid.UUID) (*ArchiveVehicle, error) {
+	archive, err := getArchiveVehicle(DB.Unscoped().Session(&gorm.Session{}), vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	deletedAt := archive.Vehicle.DeletedAt
+	if !deletedAt.Valid {
+		return archive, nil
+	}
+	archive.Vehicle.DeletedAt = gorm.DeletedAt{}
+	for i := range archive.Fillups {
+		if archive.Fillups[i].DeletedAt.Valid && archive.Fillups[i].DeletedAt.Time.Equal(deletedAt.Time) {
+			archive.Fillups[i].DeletedAt = gorm.DeletedAt{}
+		}
+	}
+	for i := range archive.Expenses {
+		if archive.Expenses[i].DeletedAt.Valid && archive.Expenses[i].DeletedAt.Time.Equal(deletedAt.Time) {
+			archive.Expenses[i].DeletedAt = gorm.DeletedAt{}
+		}
+	}
+	return archive, nil
+}
+
+// getArchiveVehicle loads the vehicle, its fillups and its expenses through
+// entries, which tells whether those in the trash are included.
+func getArchiveVehicle(entries *gorm.DB, vehicleId uuid.UUID) (*ArchiveVehicle, error) {
+	var archive ArchiveVehicle
+	if err := entries.First(&archive.Vehicle, "id = ?", vehicleId).Error; err != nil {
+		return nil, err
+	}
+	var shares []UserVehicle
+	if err := DB.Where("vehicle_id = ?", vehicleId).Find(&shares).Error; err != nil {
+		return nil, err
+	}
+	archive.Shares = make([]ArchiveShare, len(shares))
+	for i, share := range shares {
+		archive.Shares[i] = ArchiveShare{UserID: share.UserID, IsOwner: share.IsOwner}
+	}
+	if err := entries.Preload("Tags").Where("vehicle_id = ?", vehicleId).Order("date").Find(&archive.Fillups).Error; err != nil {
+		return nil, err
+	}
+	if err := entries.Preload("Tags").Preload("LineItems").Where("vehicle_id = ?", vehicleId).Order("date").Find(&archive.Expenses).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("vehicle_id = ?", vehicleId).Find(&archive.RecurringExpenses).Error; err != nil {
+		return nil, err
+	}
+	recurringIds := DB.Model(&RecurringExpense{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	if err := DB.Where("recurring_expense_id IN (?)", recurringIds).Order("date").Find(&archive.RecurringExpenseOccurrences).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("vehicle_id = ?", vehicleId).Order("date").Find(&archive.OdometerReplacements).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("vehicle_id = ?", vehicleId).Find(&archive.Alerts).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("vehicle_id = ?", vehicleId).Find(&archive.AlertOccurrences).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("vehicle_id = ?", vehicleId).Find(&archive.Documents).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Preload("Mountings").Preload("TreadDepths").Where("vehicle_id = ?", vehicleId).Find(&archive.TyreSets).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("vehicle_id = ?", vehicleId).Find(&archive.Attachments).Error; err != nil {
+		return nil, err
+	}
+	fillupIds := entries.Model(&Fillup{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	if err := DB.Where("fillup_id IN (?)", fillupIds).Find(&archive.FillupAttachments).Error; err != nil {
+		return nil, err
+	}
+	expenseIds := entries.Model(&Expense{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	if err := DB.Where("expense_id IN (?)", expenseIds).Find(&archive.ExpenseAttachments).Error; err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func GetUsersByIds(ids []uuid.UUID) (*[]User, error) {
+	var users []User
+	result := DB.Where("id IN ?", ids).Order("created_at").Find(&users)
+	return &users, result.Error
+}
+
+func GetAttachmentsByIds(ids []uuid.UUID) (*[]Attachment, error) {
+	var attachments []Attachment
+	result := DB.Where("id IN ?", ids).Find(&attachments)
+	return &attachments, result.Error
+}
+
+// GetExpenseCategoriesByIds loads the given categories along with their
+// parents.
+func GetExpenseCategoriesByIds(ids []uuid.UUID) (*[]ExpenseCategory, error) {
+	var categories []ExpenseCategory
+	seen := make(map[uuid.UUID]bool)
+	for len(ids) > 0 {
+		var found []ExpenseCategory
+		if err := DB.Preload("Aliases").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		ids = nil
+		for _, category := range found {
+			if seen[category.ID] {
+				continue
+			}
+			seen[category.ID] = true
+			categories = append(categories, category)
+			if category.ParentID != nil {
+				ids = append(ids, *category.ParentID)
+			}
+		}
+	}
+	return &categories, nil
+}
+
+func GetFillingStationsByIds(ids []uuid.UUID) (*[]FillingStation, error) {
+	var stations []FillingStation
+	result := DB.Preload("Aliases").Where("id IN ?", ids).Order("name").Find(&stations)
+	return &stations, result.Error
+}
+
+// GetElectricityTariffsForArchive loads the tariffs of the given users along
+// with those the given ids name.
+func GetElectricityTariffsForArchive(userIds, ids []uuid.UUID) (*[]ElectricityTariff, error) {
+	var tariffs []ElectricityTariff
+	result := DB.Preload("Bands").Where("user_id IN ? OR id IN ?", userIds, ids).Order("effective_from").Find(&tariffs)
+	return &tariffs, result.Error
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter narrows the audit log down. Zero values are left out of the
+// search.
+type AuditLogFilter struct {
+	EntityType *AuditEntity
+	EntityID   *uuid.UUID
+	VehicleID  *uuid.UUID
+	ActorID    *uuid.UUID
+	Action     *AuditAction
+	Start      time.Time
+	End        time.Time
+	Limit      int
+	Offset     int
+}
+
+// CreateAuditLog stores an entry of the audit log in the transaction of the
+// change it records, so that one is never kept without the other.
+func CreateAuditLog(tx *gorm.DB, log *AuditLog) error {
+	return tx.Create(log).Error
+}
+
+// FindAuditLogs searches the audit log, most recent change first.
+func FindAuditLogs(filter AuditLogFilter) (*[]AuditLog, error) {
+	var logs []AuditLog
+	tx := DB.Model(&AuditLog{})
+	if filter.EntityType != nil {
+		tx = tx.Where("entity_type = ?", *filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		tx = tx.Where("entity_id = ?", *filter.EntityID)
+	}
+	if filter.VehicleID != nil {
+		tx = tx.Where("vehicle_id = ?", *filter.VehicleID)
+	}
+	if filter.ActorID != nil {
+		tx = tx.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != nil {
+		tx = tx.Where("action = ?", *filter.Action)
+	}
+	if !filter.Start.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.Start)
+	}
+	if !filter.End.IsZero() {
+		tx = tx.Where("created_at <= ?", filter.End)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		tx = tx.Offset(filter.Offset)
+	}
+	result := tx.Order("created_at desc").Find(&logs)
+	return &logs, result.Error
+}
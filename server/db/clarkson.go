@@ -142,7 +142,7 @@ func MigrateClarkson(connectionString string) (bool, error) {
 			DateFormat:   "MM/dd/yyyy",
 		}
 		_ = user.SetPassword("hammond")
-		err = CreateUser(&user)
+		err = CreateUser(DB, &user)
 		if err != nil {
 			return false, err
 		}
@@ -173,6 +173,7 @@ func MigrateClarkson(connectionString string) (bool, error) {
 			EngineSize:        float32(model.EngineSizeCC),
 			FuelUnit:          fuelUnitsMap[oldUserIdsMap[model.User].FuelUnit],
 			FuelType:          fuelTypeMap[model.FuelType],
+			DistanceUnit:      newUserIdsMap[model.User].DistanceUnit,
 		}
 
 		tx := DB.Create(&vehicle)
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func GetCustomFieldDefinitions(entityType *CustomFieldEntity) (*[]CustomFieldDefinition, error) {
+	var definitions []CustomFieldDefinition
+	tx := DB.Order("entity_type, sort_order, name")
+	if entityType != nil {
+		tx = tx.Where("entity_type = ?", *entityType)
+	}
+	result := tx.Find(&definitions)
+	return &definitions, result.Error
+}
+
+func GetCustomFieldDefinitionById(id uuid.UUID) (*CustomFieldDefinition, error) {
+	var definition CustomFieldDefinition
+	result := DB.First(&definition, "id=?", id)
+	return &definition, result.Error
+}
+
+func DeleteCustomFieldDefinitionById(id uuid.UUID) error {
+	result := DB.Where("custom_field_definition_id=?", id).Unscoped().Delete(&CustomFieldValue{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("id=?", id).Unscoped().Delete(&CustomFieldDefinition{})
+	return result.Error
+}
+
+func GetCustomFieldValues(tx *gorm.DB, entityType CustomFieldEntity, entityIds []uuid.UUID) (*[]CustomFieldValue, error) {
+	var values []CustomFieldValue
+	if len(entityIds) == 0 {
+		return &values, nil
+	}
+	result := tx.Where("entity_type = ? AND entity_id IN ?", entityType, entityIds).Find(&values)
+	return &values, result.Error
+}
+
+// DeleteCustomFieldValues removes the custom field values of the entities
+// returned by the sub query.
+func DeleteCustomFieldValues(tx *gorm.DB, entityType CustomFieldEntity, entityIds interface{}) error {
+	result := tx.Where("entity_type = ? AND entity_id IN (?)", entityType, entityIds).Unscoped().Delete(&CustomFieldValue{})
+	return result.Error
+}
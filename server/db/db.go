@@ -53,7 +53,16 @@ func Init() (*gorm.DB, error) {
 
 // Migrate Database
 func Migrate() {
-	err := DB.AutoMigrate(&Attachment{}, &QuickEntry{}, &User{}, &Vehicle{}, &UserVehicle{}, &VehicleAttachment{}, &Fillup{}, &Expense{}, &Setting{}, &JobLock{}, &Migration{})
+	// the join tables of entries have to be known before their tables are created
+	err := DB.SetupJoinTable(&Fillup{}, "Attachments", &FillupAttachment{})
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	err = DB.SetupJoinTable(&Expense{}, "Attachments", &ExpenseAttachment{})
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	err = DB.AutoMigrate(&Attachment{}, &QuickEntry{}, &User{}, &Vehicle{}, &UserVehicle{}, &VehicleAttachment{}, &FillupAttachment{}, &ExpenseAttachment{}, &VehicleDocument{}, &VehicleAlert{}, &AlertOccurance{}, &Notification{}, &Fillup{}, &Expense{}, &ExpenseLineItem{}, &ExpenseCategory{}, &ExpenseCategoryAlias{}, &FillingStation{}, &FillingStationAlias{}, &RecurringExpense{}, &RecurringExpenseOccurrence{}, &ElectricityTariff{}, &ElectricityTariffBand{}, &TyreSet{}, &TyreMounting{}, &TyreTreadDepth{}, &OdometerReplacement{}, &CustomFieldDefinition{}, &CustomFieldValue{}, &Tag{}, &AuditLog{}, &Setting{}, &JobLock{}, &Migration{})
 	if err != nil {
 		fmt.Println("1 " + err.Error())
 	}
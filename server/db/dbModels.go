@@ -61,34 +61,54 @@ func (u *User) CheckPassword(password string) error {
 
 type Vehicle struct {
 	Base
-	Nickname          string       `json:"nickname"`
-	Registration      string       `json:"registration"`
-	VIN               string       `json:"vin"`
-	Make              string       `json:"make"`
-	Model             string       `json:"model"`
-	YearOfManufacture int          `json:"yearOfManufacture"`
-	EngineSize        float32      `json:"engineSize"`
-	FuelUnit          FuelUnit     `json:"fuelUnit"`
-	FuelType          FuelType     `json:"fuelType"`
-	Users             []User       `gorm:"many2many:user_vehicles;" json:"users"`
-	Fillups           []Fillup     `json:"fillups"`
-	Expenses          []Expense    `json:"expenses"`
-	Attachments       []Attachment `gorm:"many2many:vehicle_attachments;" json:"attachments"`
-	IsOwner           bool         `gorm:"->" json:"isOwner"`
+	Nickname          string        `json:"nickname"`
+	Registration      string        `json:"registration"`
+	VIN               string        `json:"vin"`
+	Make              string        `json:"make"`
+	Model             string        `json:"model"`
+	YearOfManufacture int           `json:"yearOfManufacture"`
+	EngineSize        float32       `json:"engineSize"`
+	FuelUnit          FuelUnit      `json:"fuelUnit"`
+	FuelType          FuelType      `json:"fuelType"`
+	DistanceUnit      DistanceUnit  `json:"distanceUnit"`
+	Status            VehicleStatus `gorm:"default:0" json:"status"`
+	StatusDate        *time.Time    `json:"statusDate"`
+	StatusComments    string        `json:"statusComments"`
+	Users             []User        `gorm:"many2many:user_vehicles;" json:"users"`
+	Fillups           []Fillup      `json:"fillups"`
+	Expenses          []Expense     `json:"expenses"`
+	Attachments       []Attachment  `gorm:"many2many:vehicle_attachments;" json:"attachments"`
+	IsOwner           bool          `gorm:"->" json:"isOwner"`
+	CustomFields      CustomFields  `gorm:"-" json:"customFields"`
 }
 
 func (b *Vehicle) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Vehicle
-		FuelTypeDetail EnumDetail `json:"fuelTypeDetail"`
-		FuelUnitDetail EnumDetail `json:"fuelUnitDetail"`
+		FuelTypeDetail     EnumDetail `json:"fuelTypeDetail"`
+		FuelUnitDetail     EnumDetail `json:"fuelUnitDetail"`
+		StatusDetail       EnumDetail `json:"statusDetail"`
+		DistanceUnitDetail EnumDetail `json:"distanceUnitDetail"`
 	}{
-		Vehicle:        *b,
-		FuelTypeDetail: b.FuelTypeDetail(),
-		FuelUnitDetail: b.FuelUnitDetail(),
+		Vehicle:            *b,
+		FuelTypeDetail:     b.FuelTypeDetail(),
+		FuelUnitDetail:     b.FuelUnitDetail(),
+		StatusDetail:       b.StatusDetail(),
+		DistanceUnitDetail: DistanceUnitDetails[b.DistanceUnit],
 	})
 }
 
+func (v *Vehicle) StatusDetail() EnumDetail {
+	return VehicleStatusDetails[v.Status]
+}
+
+// IsActive tells whether the vehicle is still in use. Sold, archived and
+// scrapped vehicles keep their history but are left out of lists and stats
+// unless asked for.
+func (v *Vehicle) IsActive() bool {
+	return v.Status == ACTIVE_VEHICLE
+}
+
 func (v *Vehicle) FuelTypeDetail() EnumDetail {
 	return FuelTypeDetails[v.FuelType]
 }
@@ -107,24 +127,36 @@ type UserVehicle struct {
 
 type Fillup struct {
 	Base
-	VehicleID       uuid.UUID    `gorm:"type:uuid" json:"vehicleId"`
-	Vehicle         Vehicle      `json:"-"`
-	FuelUnit        FuelUnit     `json:"fuelUnit"`
-	FuelQuantity    float32      `json:"fuelQuantity"`
-	PerUnitPrice    float32      `json:"perUnitPrice"`
-	TotalAmount     float32      `json:"totalAmount"`
-	OdoReading      int          `json:"odoReading"`
-	IsTankFull      *bool        `json:"isTankFull"`
-	HasMissedFillup *bool        `json:"hasMissedFillup"`
-	Comments        string       `json:"comments"`
-	FillingStation  string       `json:"fillingStation"`
-	UserID          uuid.UUID    `gorm:"type:uuid" json:"userId"`
-	User            User         `json:"user"`
-	Date            time.Time    `json:"date"`
-	Currency        string       `json:"currency"`
-	DistanceUnit    DistanceUnit `json:"distanceUnit"`
-	Source          string       `json:"source"`
-	FuelSubType     string       `json:"fuelSubType"`
+	VehicleID           uuid.UUID    `gorm:"type:uuid" json:"vehicleId"`
+	Vehicle             Vehicle      `json:"-"`
+	FuelUnit            FuelUnit     `json:"fuelUnit"`
+	FuelQuantity        float32      `json:"fuelQuantity"`
+	PerUnitPrice        float32      `json:"perUnitPrice"`
+	TotalAmount         float32      `json:"totalAmount"`
+	OdoReading          int          `json:"odoReading"`
+	IsTankFull          *bool        `json:"isTankFull"`
+	HasMissedFillup     *bool        `json:"hasMissedFillup"`
+	Comments            string       `json:"comments"`
+	FillingStation      string       `json:"fillingStation"`
+	FillingStationID    *uuid.UUID   `gorm:"type:uuid" json:"fillingStationId"`
+	UserID              uuid.UUID    `gorm:"type:uuid" json:"userId"`
+	User                User         `json:"user"`
+	Date                time.Time    `json:"date"`
+	Currency            string       `json:"currency"`
+	DistanceUnit        DistanceUnit `json:"distanceUnit"`
+	Source              string       `json:"source"`
+	FuelSubType         string       `json:"fuelSubType"`
+	IsHomeCharging      *bool        `json:"isHomeCharging"`
+	ChargingStart       *time.Time   `json:"chargingStart"`
+	ChargingEnd         *time.Time   `json:"chargingEnd"`
+	ElectricityTariffID *uuid.UUID   `gorm:"type:uuid" json:"electricityTariffId"`
+	Latitude            *float64     `json:"latitude"`
+	Longitude           *float64     `json:"longitude"`
+	Address             string       `json:"address"`
+	TrueOdoReading      int          `gorm:"-" json:"trueOdoReading"`
+	CustomFields        CustomFields `gorm:"-" json:"customFields"`
+	Tags                []Tag        `gorm:"many2many:fillup_tags;" json:"tags"`
+	Attachments         []Attachment `gorm:"many2many:fillup_attachments;" json:"attachments"`
 }
 
 func (v *Fillup) FuelUnitDetail() EnumDetail {
@@ -135,33 +167,408 @@ func (b *Fillup) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Fillup
 		FuelUnitDetail EnumDetail `json:"fuelUnitDetail"`
+		HasAttachments bool       `json:"hasAttachments"`
 	}{
 		Fillup:         *b,
 		FuelUnitDetail: b.FuelUnitDetail(),
+		HasAttachments: len(b.Attachments) > 0,
 	})
 }
 
 type Expense struct {
 	Base
-	VehicleID    uuid.UUID    `gorm:"type:uuid" json:"vehicleId"`
-	Vehicle      Vehicle      `json:"-"`
-	Amount       float32      `json:"amount"`
-	OdoReading   int          `json:"odoReading"`
-	Comments     string       `json:"comments"`
-	ExpenseType  string       `json:"expenseType"`
-	UserID       uuid.UUID    `gorm:"type:uuid" json:"userId"`
-	User         User         `json:"user"`
-	Date         time.Time    `json:"date"`
-	Currency     string       `json:"currency"`
-	DistanceUnit DistanceUnit `json:"distanceUnit"`
-	Source       string       `json:"source"`
+	VehicleID          uuid.UUID         `gorm:"type:uuid" json:"vehicleId"`
+	Vehicle            Vehicle           `json:"-"`
+	Amount             float32           `json:"amount"`
+	OdoReading         int               `json:"odoReading"`
+	Comments           string            `json:"comments"`
+	ExpenseType        string            `json:"expenseType"`
+	UserID             uuid.UUID         `gorm:"type:uuid" json:"userId"`
+	User               User              `json:"user"`
+	Date               time.Time         `json:"date"`
+	Currency           string            `json:"currency"`
+	DistanceUnit       DistanceUnit      `json:"distanceUnit"`
+	Source             string            `json:"source"`
+	Vendor             string            `json:"vendor"`
+	LineItems          []ExpenseLineItem `json:"lineItems"`
+	ExpenseCategoryID  *uuid.UUID        `gorm:"type:uuid" json:"expenseCategoryId"`
+	RecurringExpenseID *uuid.UUID        `gorm:"type:uuid" json:"recurringExpenseId"`
+	Latitude           *float64          `json:"latitude"`
+	Longitude          *float64          `json:"longitude"`
+	Address            string            `json:"address"`
+	TrueOdoReading     int               `gorm:"-" json:"trueOdoReading"`
+	CustomFields       CustomFields      `gorm:"-" json:"customFields"`
+	Tags               []Tag             `gorm:"many2many:expense_tags;" json:"tags"`
+	Attachments        []Attachment      `gorm:"many2many:expense_attachments;" json:"attachments"`
+}
+
+func (b *Expense) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Expense
+		HasAttachments bool `json:"hasAttachments"`
+	}{
+		Expense:        *b,
+		HasAttachments: len(b.Attachments) > 0,
+	})
+}
+
+type ExpenseCategory struct {
+	Base
+	Name     string                 `json:"name"`
+	ParentID *uuid.UUID             `gorm:"type:uuid" json:"parentId"`
+	Icon     string                 `json:"icon"`
+	Colour   string                 `json:"colour"`
+	IsSystem bool                   `json:"isSystem"`
+	Aliases  []ExpenseCategoryAlias `json:"aliases"`
+}
+
+// ExpenseCategoryAlias maps another spelling of a category onto it. Aliases are
+// stored normalised, see NormaliseCategoryName.
+type ExpenseCategoryAlias struct {
+	Base
+	ExpenseCategoryID uuid.UUID `gorm:"type:uuid" json:"expenseCategoryId"`
+	Alias             string    `gorm:"index" json:"alias"`
+}
+
+// FillingStation is a place where fillups happen. Fillups keep the name of the
+// station as free text too, so that those which are not linked to one still
+// show where they happened.
+type FillingStation struct {
+	Base
+	Name      string                `json:"name"`
+	Brand     string                `json:"brand"`
+	Latitude  *float64              `json:"latitude"`
+	Longitude *float64              `json:"longitude"`
+	Address   string                `json:"address"`
+	Notes     string                `json:"notes"`
+	Aliases   []FillingStationAlias `json:"aliases"`
+}
+
+// FillingStationAlias maps another spelling of a station onto it. Aliases are
+// stored normalised, see NormaliseStationName.
+type FillingStationAlias struct {
+	Base
+	FillingStationID uuid.UUID `gorm:"type:uuid" json:"fillingStationId"`
+	Alias            string    `gorm:"index" json:"alias"`
+}
+
+type ExpenseLineItem struct {
+	Base
+	ExpenseID   uuid.UUID       `gorm:"type:uuid" json:"expenseId"`
+	LineType    ExpenseLineType `json:"lineType"`
+	Description string          `json:"description"`
+	PartNumber  string          `gorm:"index" json:"partNumber"`
+	Quantity    float32         `json:"quantity"`
+	UnitPrice   float32         `json:"unitPrice"`
+	Amount      float32         `json:"amount"`
+}
+
+func (b *ExpenseLineItem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ExpenseLineItem
+		LineTypeDetail EnumDetail `json:"lineTypeDetail"`
+	}{
+		ExpenseLineItem: *b,
+		LineTypeDetail:  b.LineTypeDetail(),
+	})
+}
+
+func (v *ExpenseLineItem) LineTypeDetail() EnumDetail {
+	return ExpenseLineTypeDetails[v.LineType]
+}
+
+type ElectricityTariff struct {
+	Base
+	UserID        uuid.UUID               `gorm:"type:uuid" json:"userId"`
+	User          User                    `json:"-"`
+	Name          string                  `json:"name"`
+	Currency      string                  `json:"currency"`
+	BasePrice     float32                 `json:"basePrice"`
+	EffectiveFrom time.Time               `json:"effectiveFrom"`
+	EffectiveTo   *time.Time              `json:"effectiveTo"`
+	Bands         []ElectricityTariffBand `json:"bands"`
+}
+
+// IsEffectiveAt reports whether the tariff applies at the given moment.
+func (t *ElectricityTariff) IsEffectiveAt(date time.Time) bool {
+	if date.Before(t.EffectiveFrom) {
+		return false
+	}
+	return t.EffectiveTo == nil || date.Before(*t.EffectiveTo)
+}
+
+// ElectricityTariffBand is a time-of-use window within a day. StartTime and
+// EndTime are "15:04" clock times; a band whose end is before its start wraps
+// past midnight.
+type ElectricityTariffBand struct {
+	Base
+	ElectricityTariffID uuid.UUID `gorm:"type:uuid" json:"electricityTariffId"`
+	Name                string    `json:"name"`
+	StartTime           string    `json:"startTime"`
+	EndTime             string    `json:"endTime"`
+	PerUnitPrice        float32   `json:"perUnitPrice"`
+}
+
+// RecurringExpense describes a fixed cost that falls due on a schedule. The
+// occurrences are numbered from the start date, so the n-th one is always
+// computed from StartDate rather than from the previous one.
+type RecurringExpense struct {
+	Base
+	VehicleID         uuid.UUID           `gorm:"type:uuid" json:"vehicleId"`
+	Vehicle           Vehicle             `json:"-"`
+	UserID            uuid.UUID           `gorm:"type:uuid" json:"userId"`
+	User              User                `json:"-"`
+	Title             string              `json:"title"`
+	Amount            float32             `json:"amount"`
+	Currency          string              `json:"currency"`
+	ExpenseType       string              `json:"expenseType"`
+	ExpenseCategoryID *uuid.UUID          `gorm:"type:uuid" json:"expenseCategoryId"`
+	Vendor            string              `json:"vendor"`
+	Comments          string              `json:"comments"`
+	Frequency         RecurrenceFrequency `json:"frequency"`
+	Interval          int                 `json:"interval"`
+	StartDate         time.Time           `json:"startDate"`
+	EndDate           *time.Time          `json:"endDate"`
+	IsActive          bool                `json:"isActive"`
+	GeneratedCount    int                 `json:"generatedCount"`
+	NextDate          *time.Time          `json:"nextDate"`
+}
+
+func (b *RecurringExpense) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		RecurringExpense
+		FrequencyDetail EnumDetail `json:"frequencyDetail"`
+	}{
+		RecurringExpense: *b,
+		FrequencyDetail:  b.FrequencyDetail(),
+	})
+}
+
+func (v *RecurringExpense) FrequencyDetail() EnumDetail {
+	return RecurrenceFrequencyDetails[v.Frequency]
+}
+
+// RecurringExpenseOccurrence records what happened to one due date of a
+// recurring expense. It is created either when the expense is generated or
+// ahead of time when the user skips or adjusts an upcoming instance.
+type RecurringExpenseOccurrence struct {
+	Base
+	RecurringExpenseID uuid.UUID  `gorm:"type:uuid" json:"recurringExpenseId"`
+	Date               time.Time  `json:"date"`
+	IsSkipped          bool       `json:"isSkipped"`
+	Amount             *float32   `json:"amount"`
+	Comments           string     `json:"comments"`
+	ExpenseID          *uuid.UUID `gorm:"type:uuid" json:"expenseId"`
+}
+
+type TyreSet struct {
+	Base
+	VehicleID      uuid.UUID        `gorm:"type:uuid" json:"vehicleId"`
+	Vehicle        Vehicle          `json:"-"`
+	Name           string           `json:"name"`
+	Brand          string           `json:"brand"`
+	Model          string           `json:"model"`
+	Size           string           `json:"size"`
+	Season         TyreSeason       `json:"season"`
+	PurchaseDate   *time.Time       `json:"purchaseDate"`
+	PurchaseCost   float32          `json:"purchaseCost"`
+	Currency       string           `json:"currency"`
+	Comments       string           `json:"comments"`
+	IsRetired      bool             `json:"isRetired"`
+	Mountings      []TyreMounting   `json:"mountings"`
+	TreadDepths    []TyreTreadDepth `json:"treadDepths"`
+	DistanceDriven int              `gorm:"-" json:"distanceDriven"`
+}
+
+func (b *TyreSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TyreSet
+		SeasonDetail EnumDetail `json:"seasonDetail"`
+		IsMounted    bool       `json:"isMounted"`
+	}{
+		TyreSet:      *b,
+		SeasonDetail: b.SeasonDetail(),
+		IsMounted:    b.CurrentMounting() != nil,
+	})
+}
+
+func (v *TyreSet) SeasonDetail() EnumDetail {
+	return TyreSeasonDetails[v.Season]
+}
+
+// CurrentMounting returns the mounting that has not been unmounted yet, if any.
+func (v *TyreSet) CurrentMounting() *TyreMounting {
+	for i := range v.Mountings {
+		if v.Mountings[i].UnmountDate == nil {
+			return &v.Mountings[i]
+		}
+	}
+	return nil
+}
+
+type TyreMounting struct {
+	Base
+	TyreSetID         uuid.UUID  `gorm:"type:uuid" json:"tyreSetId"`
+	MountDate         time.Time  `json:"mountDate"`
+	MountOdoReading   int        `json:"mountOdoReading"`
+	UnmountDate       *time.Time `json:"unmountDate"`
+	UnmountOdoReading *int       `json:"unmountOdoReading"`
+}
+
+type TyreTreadDepth struct {
+	Base
+	TyreSetID  uuid.UUID `gorm:"type:uuid" json:"tyreSetId"`
+	Date       time.Time `json:"date"`
+	OdoReading int       `json:"odoReading"`
+	FrontLeft  float32   `json:"frontLeft"`
+	FrontRight float32   `json:"frontRight"`
+	RearLeft   float32   `json:"rearLeft"`
+	RearRight  float32   `json:"rearRight"`
+}
+
+// OdometerReplacement records the odometer of a vehicle being replaced or
+// rolling over. Readings taken from its date onwards are offset by the last
+// reading of the old odometer less the first reading of the new one, so that
+// the vehicle keeps a continuous true distance.
+type OdometerReplacement struct {
+	Base
+	VehicleID      uuid.UUID `gorm:"type:uuid" json:"vehicleId"`
+	Vehicle        Vehicle   `json:"-"`
+	Date           time.Time `json:"date"`
+	LastOdoReading int       `json:"lastOdoReading"`
+	NewOdoReading  int       `json:"newOdoReading"`
+	Comments       string    `json:"comments"`
+}
+
+func (o *OdometerReplacement) Offset() int {
+	return o.LastOdoReading - o.NewOdoReading
+}
+
+func (b *OdometerReplacement) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		OdometerReplacement
+		Offset int `json:"offset"`
+	}{
+		OdometerReplacement: *b,
+		Offset:              b.Offset(),
+	})
+}
+
+// Tag labels fillups and expenses across vehicles. Names are stored
+// normalised, see NormaliseTagName.
+type Tag struct {
+	Base
+	Name string `gorm:"uniqueIndex" json:"name"`
+}
+
+// CustomFieldDefinition is an admin defined field recorded against every
+// vehicle, fillup or expense. Options lists the allowed values of an enum field.
+type CustomFieldDefinition struct {
+	Base
+	EntityType CustomFieldEntity `json:"entityType"`
+	Key        string            `json:"key"`
+	Name       string            `json:"name"`
+	FieldType  CustomFieldType   `json:"fieldType"`
+	Options    []string          `gorm:"serializer:json" json:"options"`
+	IsRequired bool              `json:"isRequired"`
+	SortOrder  int               `json:"sortOrder"`
+}
+
+func (b *CustomFieldDefinition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		CustomFieldDefinition
+		EntityTypeDetail EnumDetail `json:"entityTypeDetail"`
+		FieldTypeDetail  EnumDetail `json:"fieldTypeDetail"`
+	}{
+		CustomFieldDefinition: *b,
+		EntityTypeDetail:      CustomFieldEntityDetails[b.EntityType],
+		FieldTypeDetail:       CustomFieldTypeDetails[b.FieldType],
+	})
+}
+
+// CustomFields are the custom field values of an entity keyed by the key of
+// their definition.
+type CustomFields map[string]interface{}
+
+// CustomFieldValue holds the value of a custom field for one entity. Value is
+// the canonical text of the value, numbers and dates are also kept typed so
+// that they can be compared.
+type CustomFieldValue struct {
+	Base
+	CustomFieldDefinitionID uuid.UUID         `gorm:"type:uuid" json:"customFieldDefinitionId"`
+	EntityType              CustomFieldEntity `json:"entityType"`
+	EntityID                uuid.UUID         `gorm:"type:uuid;index" json:"entityId"`
+	Value                   string            `json:"value"`
+	NumberValue             *float64          `json:"numberValue"`
+	DateValue               *time.Time        `json:"dateValue"`
+}
+
+// AuditLog records a change made to an entity. Changes hold the fields that
+// differ, with before empty for creations and after empty for deletions.
+type AuditLog struct {
+	Base
+	ActorID    *uuid.UUID    `gorm:"type:uuid;index" json:"actorId"`
+	ActorName  string        `json:"actorName"`
+	Action     AuditAction   `json:"action"`
+	EntityType AuditEntity   `json:"entityType"`
+	EntityID   uuid.UUID     `gorm:"type:uuid;index" json:"entityId"`
+	VehicleID  *uuid.UUID    `gorm:"type:uuid;index" json:"vehicleId"`
+	Changes    []AuditChange `gorm:"serializer:json" json:"changes"`
+}
+
+func (b *AuditLog) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		AuditLog
+		ActionDetail     EnumDetail `json:"actionDetail"`
+		EntityTypeDetail EnumDetail `json:"entityTypeDetail"`
+	}{
+		AuditLog:         *b,
+		ActionDetail:     AuditActionDetails[b.Action],
+		EntityTypeDetail: AuditEntityDetails[b.EntityType],
+	})
+}
+
+type AuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
 }
 
 type Setting struct {
 	Base
 	Currency     string       `json:"currency" gorm:"default:INR"`
 	DistanceUnit DistanceUnit `json:"distanceUnit" gorm:"default:1"`
+	// TrashRetentionDays is how long deleted items stay in the trash. Zero
+	// means the default of DefaultTrashRetentionDays.
+	TrashRetentionDays int `json:"trashRetentionDays"`
+	// MaxUploadSize is the largest file in bytes which can be uploaded. Zero
+	// means the default of DefaultMaxUploadSize.
+	MaxUploadSize int64 `json:"maxUploadSize"`
+	// AllowedUploadTypes is a comma separated list of the MIME types which can
+	// be uploaded, like image/* or application/pdf. Empty means those in
+	// DefaultAllowedUploadTypes.
+	AllowedUploadTypes string `json:"allowedUploadTypes"`
+	// StripImageLocation removes the GPS position from uploaded photos before
+	// they are stored. Only JPEG photos are stripped; PNG, WebP and HEIC files
+	// are stored as they are uploaded.
+	StripImageLocation bool `json:"stripImageLocation"`
+	// QuickEntryRetentionDays is how long quick entries are kept once they
+	// have been processed. Zero means the default of
+	// DefaultQuickEntryRetentionDays.
+	QuickEntryRetentionDays int `json:"quickEntryRetentionDays"`
+	// GarbageCollectionCleanUp lets the daily garbage collection remove what
+	// it finds, otherwise it only logs it.
+	GarbageCollectionCleanUp bool `json:"garbageCollectionCleanUp"`
 }
+
+const DefaultTrashRetentionDays = 30
+
+const DefaultMaxUploadSize = 25 * 1024 * 1024
+
+const DefaultAllowedUploadTypes = "image/*,application/pdf,text/plain,text/csv"
+
+const DefaultQuickEntryRetentionDays = 90
+
 type Migration struct {
 	Base
 	Date time.Time
@@ -174,15 +581,30 @@ type JobLock struct {
 	Duration int
 }
 
+// Attachment is a file kept by one of the storage backends. Path is where the
+// backend finds it: a file path for local storage, a key in the bucket for S3.
+// Uploads with the same content share their file, found by its SHA-256 Hash.
 type Attachment struct {
 	Base
+	Storage      string    `gorm:"default:local" json:"storage"`
 	Path         string    `json:"path"`
+	Hash         string    `gorm:"index" json:"hash"`
 	OriginalName string    `json:"originalName"`
 	Size         int64     `json:"size"`
 	ContentType  string    `json:"contentType"`
 	Title        string    `gorm:"->" json:"title"`
 	UserID       uuid.UUID `gorm:"type:uuid" json:"userId"`
 	User         User      `json:"user"`
+	// Photo is read from an upload before its location may be stripped, it is
+	// not stored
+	Photo *PhotoMetadata `gorm:"-" json:"-"`
+}
+
+// PhotoMetadata is when and where a photo was taken, from its EXIF data.
+type PhotoMetadata struct {
+	CaptureDate *time.Time
+	Latitude    *float64
+	Longitude   *float64
 }
 
 type QuickEntry struct {
@@ -193,6 +615,10 @@ type QuickEntry struct {
 	UserID       uuid.UUID  `gorm:"type:uuid" json:"userId"`
 	User         User       `json:"user"`
 	Comments     string     `json:"comments"`
+	// read from the EXIF data of the photo, when it has any
+	CaptureDate *time.Time `json:"captureDate"`
+	Latitude    *float64   `json:"latitude"`
+	Longitude   *float64   `json:"longitude"`
 }
 
 type VehicleAttachment struct {
@@ -202,6 +628,57 @@ type VehicleAttachment struct {
 	Title        string    `json:"title"`
 }
 
+type FillupAttachment struct {
+	Base
+	AttachmentID uuid.UUID `gorm:"primaryKey;type:uuid" json:"attachmentId"`
+	FillupID     uuid.UUID `gorm:"primaryKey;type:uuid" json:"fillupId"`
+	Title        string    `json:"title"`
+}
+
+type ExpenseAttachment struct {
+	Base
+	AttachmentID uuid.UUID `gorm:"primaryKey;type:uuid" json:"attachmentId"`
+	ExpenseID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"expenseId"`
+	Title        string    `json:"title"`
+}
+
+// VehicleDocument is a typed document of a vehicle, like an insurance
+// certificate, optionally backed by an uploaded file. A document that expires
+// gets a one time alert ReminderDays before its expiry date.
+type VehicleDocument struct {
+	Base
+	VehicleID       uuid.UUID    `gorm:"type:uuid" json:"vehicleId"`
+	Vehicle         Vehicle      `json:"-"`
+	UserID          uuid.UUID    `gorm:"type:uuid" json:"userId"`
+	User            User         `json:"-"`
+	DocumentType    DocumentType `json:"documentType"`
+	Title           string       `json:"title"`
+	ReferenceNumber string       `json:"referenceNumber"`
+	IssueDate       *time.Time   `json:"issueDate"`
+	ExpiryDate      *time.Time   `json:"expiryDate"`
+	ReminderDays    int          `json:"reminderDays"`
+	Comments        string       `json:"comments"`
+	AttachmentID    *uuid.UUID   `gorm:"type:uuid" json:"attachmentId"`
+	Attachment      *Attachment  `json:"attachment"`
+	VehicleAlertID  *uuid.UUID   `gorm:"type:uuid" json:"vehicleAlertId"`
+}
+
+func (b *VehicleDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		VehicleDocument
+		DocumentTypeDetail EnumDetail `json:"documentTypeDetail"`
+		IsExpired          bool       `json:"isExpired"`
+	}{
+		VehicleDocument:    *b,
+		DocumentTypeDetail: b.DocumentTypeDetail(),
+		IsExpired:          b.ExpiryDate != nil && b.ExpiryDate.Before(time.Now()),
+	})
+}
+
+func (v *VehicleDocument) DocumentTypeDetail() EnumDetail {
+	return DocumentTypeDetails[v.DocumentType]
+}
+
 type VehicleAlert struct {
 	Base
 	VehicleID       uuid.UUID      `gorm:"type:uuid" json:"vehicleId"`
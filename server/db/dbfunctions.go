@@ -3,6 +3,7 @@ package db
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,14 +21,12 @@ func CanInitializeSystem() (bool, error) {
 	return true, nil
 }
 
-func CreateUser(user *User) error {
-	tx := DB.Create(&user)
-	return tx.Error
+func CreateUser(tx *gorm.DB, user *User) error {
+	return tx.Create(&user).Error
 }
 
-func UpdateUser(user *User) error {
-	tx := DB.Omit(clause.Associations).Save(&user)
-	return tx.Error
+func UpdateUser(tx *gorm.DB, user *User) error {
+	return tx.Omit(clause.Associations).Save(&user).Error
 }
 
 func FindOneUser(condition interface{}) (User, error) {
@@ -37,10 +36,9 @@ func FindOneUser(condition interface{}) (User, error) {
 	return model, err
 }
 
-func SetDisabledStatusForUser(userId uuid.UUID, isDisabled bool) error {
+func SetDisabledStatusForUser(tx *gorm.DB, userId uuid.UUID, isDisabled bool) error {
 	//Cannot do this for admin
-	tx := DB.Debug().Model(&User{}).Where("id= ? and role=?", userId, USER).Update("is_disabled", isDisabled)
-	return tx.Error
+	return tx.Debug().Model(&User{}).Where("id= ? and role=?", userId, USER).Update("is_disabled", isDisabled).Error
 }
 
 func GetAllUsers() (*[]User, error) {
@@ -86,47 +84,45 @@ func GetVehicleUsers(vehicleId uuid.UUID) (*[]UserVehicle, error) {
 	return &mapping, nil
 }
 
-func ShareVehicle(vehicleId, userId uuid.UUID) error {
+func ShareVehicle(tx *gorm.DB, vehicleId, userId uuid.UUID) error {
 	var mapping UserVehicle
 
-	tx := DB.Where("vehicle_id = ? AND user_id = ?", vehicleId, userId).First(&mapping)
+	result := tx.Where("vehicle_id = ? AND user_id = ?", vehicleId, userId).First(&mapping)
 
-	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		newMapping := UserVehicle{
 			UserID:    userId,
 			VehicleID: vehicleId,
 			IsOwner:   false,
 		}
-		tx = DB.Create(&newMapping)
-		return tx.Error
+		return tx.Create(&newMapping).Error
 	}
 	return nil
 }
 
-func TransferVehicle(vehicleId, ownerId, newUserID uuid.UUID) error {
+func TransferVehicle(tx *gorm.DB, vehicleId, ownerId, newUserID uuid.UUID) error {
 
-	tx := DB.Model(&UserVehicle{}).Where("vehicle_id = ? AND user_id = ?", vehicleId, ownerId).Update("is_owner", false)
-	if tx.Error != nil {
-		return tx.Error
+	result := tx.Model(&UserVehicle{}).Where("vehicle_id = ? AND user_id = ?", vehicleId, ownerId).Update("is_owner", false)
+	if result.Error != nil {
+		return result.Error
 	}
-	tx = DB.Model(&UserVehicle{}).Where("vehicle_id = ? AND user_id = ?", vehicleId, newUserID).Update("is_owner", true)
+	result = tx.Model(&UserVehicle{}).Where("vehicle_id = ? AND user_id = ?", vehicleId, newUserID).Update("is_owner", true)
 
-	return tx.Error
+	return result.Error
 }
 
-func UnshareVehicle(vehicleId, userId uuid.UUID) error {
+func UnshareVehicle(tx *gorm.DB, vehicleId, userId uuid.UUID) error {
 	var mapping UserVehicle
 
-	tx := DB.Where("vehicle_id = ? AND user_id = ?", vehicleId, userId).First(&mapping)
+	result := tx.Where("vehicle_id = ? AND user_id = ?", vehicleId, userId).First(&mapping)
 
-	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil
 	}
 	if mapping.IsOwner {
 		return fmt.Errorf("cannot unshare owner")
 	}
-	result := DB.Where("id=?", mapping.ID).Delete(&UserVehicle{})
-	return result.Error
+	return tx.Where("id=?", mapping.ID).Unscoped().Delete(&UserVehicle{}).Error
 }
 
 func GetUserVehicles(id uuid.UUID) (*[]Vehicle, error) {
@@ -151,21 +147,87 @@ func GetUserVehicles(id uuid.UUID) (*[]Vehicle, error) {
 	return &vehicles, nil
 }
 
+// ConvertVehicleDistanceUnit rewrites the odometer readings recorded against a
+// vehicle from one distance unit to another.
+func ConvertVehicleDistanceUnit(tx *gorm.DB, vehicleId uuid.UUID, from, to DistanceUnit) error {
+	if from == to {
+		return nil
+	}
+	factor := DistanceConversionFactor(from, to)
+	odoReading := gorm.Expr("ROUND(odo_reading * ?)", factor)
+	result := tx.Model(&Fillup{}).Where("vehicle_id = ?", vehicleId).Updates(map[string]interface{}{
+		"odo_reading":   odoReading,
+		"distance_unit": to,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Model(&Expense{}).Where("vehicle_id = ?", vehicleId).Updates(map[string]interface{}{
+		"odo_reading":   odoReading,
+		"distance_unit": to,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	tyreSets := tx.Model(&TyreSet{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	result = tx.Model(&TyreMounting{}).Where("tyre_set_id IN (?)", tyreSets).Updates(map[string]interface{}{
+		"mount_odo_reading":   gorm.Expr("ROUND(mount_odo_reading * ?)", factor),
+		"unmount_odo_reading": gorm.Expr("ROUND(unmount_odo_reading * ?)", factor),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Model(&TyreTreadDepth{}).Where("tyre_set_id IN (?)", tyreSets).Update("odo_reading", odoReading)
+	if result.Error != nil {
+		return result.Error
+	}
+	result = tx.Model(&OdometerReplacement{}).Where("vehicle_id = ?", vehicleId).Updates(map[string]interface{}{
+		"last_odo_reading": gorm.Expr("ROUND(last_odo_reading * ?)", factor),
+		"new_odo_reading":  gorm.Expr("ROUND(new_odo_reading * ?)", factor),
+	})
+	return result.Error
+}
+
 func GetUserById(id uuid.UUID) (*User, error) {
 	var data User
 	result := DB.Preload(clause.Associations).First(&data, "id=?", id)
 	return &data, result.Error
 }
 
+// LoadUser loads a user, without what belongs to them, through tx. See
+// LoadVehicle.
+func LoadUser(tx *gorm.DB, id uuid.UUID) (*User, error) {
+	var data User
+	result := tx.First(&data, "id=?", id)
+	return &data, result.Error
+}
+
 func GetVehicleById(id uuid.UUID) (*Vehicle, error) {
+	return LoadVehicle(DB, id)
+}
+
+// LoadVehicle loads a vehicle through tx, so that it is seen with the changes
+// made in the transaction.
+func LoadVehicle(tx *gorm.DB, id uuid.UUID) (*Vehicle, error) {
 	var vehicle Vehicle
-	result := DB.Preload(clause.Associations).First(&vehicle, "id=?", id)
+	result := tx.Preload(clause.Associations).First(&vehicle, "id=?", id)
 	return &vehicle, result.Error
 }
 
+func GetVehicleDistanceUnit(id uuid.UUID) (DistanceUnit, error) {
+	var vehicle Vehicle
+	result := DB.Select("distance_unit").First(&vehicle, "id=?", id)
+	return vehicle.DistanceUnit, result.Error
+}
+
 func GetFillupById(id uuid.UUID) (*Fillup, error) {
+	return LoadFillup(DB, id)
+}
+
+// LoadFillup loads a fillup through tx, see LoadVehicle.
+func LoadFillup(tx *gorm.DB, id uuid.UUID) (*Fillup, error) {
 	var obj Fillup
-	result := DB.Preload(clause.Associations).First(&obj, "id=?", id)
+	result := tx.Preload(clause.Associations).First(&obj, "id=?", id)
 	return &obj, result.Error
 }
 
@@ -197,14 +259,21 @@ func FindFillups(condition interface{}) (*[]Fillup, error) {
 func FindFillupsForDateRange(vehicleIds []uuid.UUID, start, end time.Time) (*[]Fillup, error) {
 
 	var model []Fillup
-	err := DB.Where("date <= ? AND date >= ? AND vehicle_id in ?", end, start, vehicleIds).Find(&model).Error
+	err := DB.Preload("Tags").Where("date <= ? AND date >= ? AND vehicle_id in ?", end, start, vehicleIds).Find(&model).Error
+	return &model, err
+}
+
+func FindLocatedFillupsForDateRange(vehicleIds []uuid.UUID, start, end time.Time) (*[]Fillup, error) {
+
+	var model []Fillup
+	err := DB.Where("date <= ? AND date >= ? AND vehicle_id in ? AND latitude IS NOT NULL AND longitude IS NOT NULL", end, start, vehicleIds).Order("date").Find(&model).Error
 	return &model, err
 }
 
 func FindExpensesForDateRange(vehicleIds []uuid.UUID, start, end time.Time) (*[]Expense, error) {
 
 	var model []Expense
-	err := DB.Where("date <= ? AND date >= ? AND vehicle_id in ?", end, start, vehicleIds).Find(&model).Error
+	err := DB.Preload("Tags").Where("date <= ? AND date >= ? AND vehicle_id in ?", end, start, vehicleIds).Find(&model).Error
 	return &model, err
 }
 
@@ -221,36 +290,224 @@ func GetLatestExpenseByVehicleId(id uuid.UUID) (*Expense, error) {
 }
 
 func GetExpenseById(id uuid.UUID) (*Expense, error) {
+	return LoadExpense(DB, id)
+}
+
+// LoadExpense loads an expense through tx, see LoadVehicle.
+func LoadExpense(tx *gorm.DB, id uuid.UUID) (*Expense, error) {
 	var obj Expense
-	result := DB.Preload(clause.Associations).First(&obj, "id=?", id)
+	result := tx.Preload(clause.Associations).First(&obj, "id=?", id)
 	return &obj, result.Error
 }
 
-func DeleteVehicleById(id uuid.UUID) error {
+// DeleteVehicleById moves a vehicle to the trash along with the fillups and
+// expenses still in it. They share the deletion time so that restoring the
+// vehicle brings back exactly those.
+func DeleteVehicleById(tx *gorm.DB, id uuid.UUID) error {
+	now := time.Now()
+	if err := tx.Model(&Fillup{}).Where("vehicle_id=?", id).Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Expense{}).Where("vehicle_id=?", id).Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Vehicle{}).Where("id=?", id).Update("deleted_at", now).Error
+}
 
-	result := DB.Where("id=?", id).Delete(&Vehicle{})
-	return result.Error
+// DeleteFillupById moves a fillup to the trash.
+func DeleteFillupById(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Where("id=?", id).Delete(&Fillup{}).Error
 }
 
-func DeleteFillupById(id uuid.UUID) error {
+// DeleteExpenseById moves an expense to the trash.
+func DeleteExpenseById(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Where("id=?", id).Delete(&Expense{}).Error
+}
 
-	result := DB.Where("id=?", id).Delete(&Fillup{})
-	return result.Error
+// PurgeFillups permanently removes fillups, trashed or not, along with their
+// custom field values, tags and links to attachments. ids is either a list of
+// ids or a sub query selecting them.
+func PurgeFillups(tx *gorm.DB, ids interface{}) error {
+	if err := DeleteCustomFieldValues(tx, FILLUP_ENTITY, ids); err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM fillup_tags WHERE fillup_id IN (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("fillup_id IN (?)", ids).Unscoped().Delete(&FillupAttachment{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", ids).Unscoped().Delete(&Fillup{}).Error
+}
+
+// PurgeExpenses permanently removes expenses, trashed or not, along with their
+// custom field values, tags, line items and links to attachments. ids is either
+// a list of ids or a sub query selecting them.
+func PurgeExpenses(tx *gorm.DB, ids interface{}) error {
+	if err := DeleteCustomFieldValues(tx, EXPENSE_ENTITY, ids); err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM expense_tags WHERE expense_id IN (?)", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("expense_id IN (?)", ids).Unscoped().Delete(&ExpenseAttachment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("expense_id IN (?)", ids).Unscoped().Delete(&ExpenseLineItem{}).Error; err != nil {
+		return err
+	}
+	// a removed recurring instance counts as skipped so that it is not generated again
+	err := tx.Model(&RecurringExpenseOccurrence{}).Where("expense_id IN (?)", ids).Updates(map[string]interface{}{
+		"expense_id": nil,
+		"is_skipped": true,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", ids).Unscoped().Delete(&Expense{}).Error
+}
+
+// FindExpenseLineItems searches the line items of a vehicle's expenses, most
+// recent expense first. partNumber is matched exactly and query is matched
+// against the part number and description, both case insensitively.
+func FindExpenseLineItems(vehicleId uuid.UUID, partNumber, query string) (*[]ExpenseLineItem, error) {
+	var lineItems []ExpenseLineItem
+	tx := DB.Joins("JOIN expenses ON expenses.id = expense_line_items.expense_id").
+		Where("expenses.vehicle_id = ? AND expenses.deleted_at IS NULL", vehicleId)
+	if partNumber != "" {
+		tx = tx.Where("lower(expense_line_items.part_number) = lower(?)", partNumber)
+	}
+	if query != "" {
+		like := "%" + strings.ToLower(query) + "%"
+		tx = tx.Where("lower(expense_line_items.part_number) LIKE ? OR lower(expense_line_items.description) LIKE ?", like, like)
+	}
+	result := tx.Order("expenses.date desc").Find(&lineItems)
+	return &lineItems, result.Error
+}
+
+func GetElectricityTariffsForUser(userId uuid.UUID) (*[]ElectricityTariff, error) {
+	var tariffs []ElectricityTariff
+	result := DB.Preload("Bands").Where("user_id = ?", userId).Order("effective_from desc").Find(&tariffs)
+	return &tariffs, result.Error
 }
 
-func DeleteExpenseById(id uuid.UUID) error {
-	result := DB.Where("id=?", id).Delete(&Expense{})
+func GetElectricityTariffsForUserInRange(userId uuid.UUID, start, end time.Time) (*[]ElectricityTariff, error) {
+	var tariffs []ElectricityTariff
+	result := DB.Preload("Bands").
+		Where("user_id = ? AND effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)", userId, end, start).
+		Order("effective_from desc").Find(&tariffs)
+	return &tariffs, result.Error
+}
+
+func GetElectricityTariffById(id uuid.UUID) (*ElectricityTariff, error) {
+	var tariff ElectricityTariff
+	result := DB.Preload("Bands").First(&tariff, "id=?", id)
+	return &tariff, result.Error
+}
+
+func DeleteElectricityTariffById(id uuid.UUID) error {
+	result := DB.Where("electricity_tariff_id=?", id).Unscoped().Delete(&ElectricityTariffBand{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("id=?", id).Unscoped().Delete(&ElectricityTariff{})
 	return result.Error
 }
 
-func DeleteFillupByVehicleId(id uuid.UUID) error {
+func GetRecurringExpensesByVehicleId(id uuid.UUID) (*[]RecurringExpense, error) {
+	var recurringExpenses []RecurringExpense
+	result := DB.Where("vehicle_id = ?", id).Order("title").Find(&recurringExpenses)
+	return &recurringExpenses, result.Error
+}
+
+func GetRecurringExpenseById(id uuid.UUID) (*RecurringExpense, error) {
+	var recurringExpense RecurringExpense
+	result := DB.First(&recurringExpense, "id=?", id)
+	return &recurringExpense, result.Error
+}
+
+// GetDueRecurringExpenses returns the active recurring expenses of active
+// vehicles that have an occurrence due on or before the given date.
+func GetDueRecurringExpenses(date time.Time) (*[]RecurringExpense, error) {
+	var recurringExpenses []RecurringExpense
+	result := DB.Joins("JOIN vehicles ON vehicles.id = recurring_expenses.vehicle_id").
+		Where("recurring_expenses.is_active = ? AND recurring_expenses.next_date IS NOT NULL AND recurring_expenses.next_date <= ?", true, date).
+		Where("vehicles.status = ? AND vehicles.deleted_at IS NULL", ACTIVE_VEHICLE).
+		Find(&recurringExpenses)
+	return &recurringExpenses, result.Error
+}
+
+func GetRecurringExpenseOccurrences(id uuid.UUID) (*[]RecurringExpenseOccurrence, error) {
+	var occurrences []RecurringExpenseOccurrence
+	result := DB.Where("recurring_expense_id = ?", id).Order("date").Find(&occurrences)
+	return &occurrences, result.Error
+}
 
-	result := DB.Where("vehicle_id=?", id).Delete(&Fillup{})
+// DeleteRecurringExpenseById removes the schedule. The expenses it already
+// created are kept as regular expenses.
+func DeleteRecurringExpenseById(id uuid.UUID) error {
+	result := DB.Model(&Expense{}).Where("recurring_expense_id=?", id).Update("recurring_expense_id", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("recurring_expense_id=?", id).Unscoped().Delete(&RecurringExpenseOccurrence{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("id=?", id).Unscoped().Delete(&RecurringExpense{})
 	return result.Error
 }
 
-func DeleteExpenseByVehicleId(id uuid.UUID) error {
-	result := DB.Where("vehicle_id=?", id).Delete(&Expense{})
+func GetOdometerReplacementsByVehicleId(id uuid.UUID) (*[]OdometerReplacement, error) {
+	var replacements []OdometerReplacement
+	result := DB.Where("vehicle_id = ?", id).Order("date").Find(&replacements)
+	return &replacements, result.Error
+}
+
+func GetOdometerReplacementById(id uuid.UUID) (*OdometerReplacement, error) {
+	var replacement OdometerReplacement
+	result := DB.First(&replacement, "id=?", id)
+	return &replacement, result.Error
+}
+
+func GetTyreSetsByVehicleId(id uuid.UUID) (*[]TyreSet, error) {
+	var tyreSets []TyreSet
+	result := DB.Preload("Mountings", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tyre_mountings.mount_date DESC")
+	}).Preload("TreadDepths", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tyre_tread_depths.date DESC")
+	}).Where("vehicle_id = ?", id).Order("created_at desc").Find(&tyreSets)
+	return &tyreSets, result.Error
+}
+
+func GetTyreSetById(id uuid.UUID) (*TyreSet, error) {
+	var tyreSet TyreSet
+	result := DB.Preload("Mountings", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tyre_mountings.mount_date DESC")
+	}).Preload("TreadDepths", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tyre_tread_depths.date DESC")
+	}).First(&tyreSet, "id=?", id)
+	return &tyreSet, result.Error
+}
+
+func GetMountedTyreMountingsByVehicleId(id uuid.UUID) (*[]TyreMounting, error) {
+	var mountings []TyreMounting
+	result := DB.Joins("JOIN tyre_sets ON tyre_sets.id = tyre_mountings.tyre_set_id").
+		Where("tyre_sets.vehicle_id = ? AND tyre_mountings.unmount_date IS NULL", id).
+		Find(&mountings)
+	return &mountings, result.Error
+}
+
+func DeleteTyreSetById(id uuid.UUID) error {
+	result := DB.Where("tyre_set_id=?", id).Unscoped().Delete(&TyreMounting{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("tyre_set_id=?", id).Unscoped().Delete(&TyreTreadDepth{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("id=?", id).Unscoped().Delete(&TyreSet{})
 	return result.Error
 }
 
@@ -279,7 +536,7 @@ func GetQuickEntryById(id uuid.UUID) (*QuickEntry, error) {
 }
 
 func DeleteQuickEntryById(id uuid.UUID) error {
-	result := DB.Where("id=?", id).Delete(&QuickEntry{})
+	result := DB.Where("id=?", id).Unscoped().Delete(&QuickEntry{})
 	return result.Error
 }
 
@@ -287,9 +544,17 @@ func UpdateQuickEntry(entry *QuickEntry) error {
 	return DB.Save(entry).Error
 }
 
-func SetQuickEntryAsProcessed(id uuid.UUID, processDate time.Time) error {
-	result := DB.Model(QuickEntry{}).Where("id=?", id).Update("process_date", processDate)
-	return result.Error
+// SetQuickEntryAsProcessed marks a quick entry as processed. It fails if the
+// entry was processed already, so that two requests can't both process it.
+func SetQuickEntryAsProcessed(tx *gorm.DB, id uuid.UUID, processDate time.Time) error {
+	result := tx.Model(QuickEntry{}).Where("id = ? AND process_date IS NULL", id).Update("process_date", processDate)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("this quick entry has been processed already")
+	}
+	return nil
 }
 
 func GetAttachmentById(id uuid.UUID) (*Attachment, error) {
@@ -298,6 +563,67 @@ func GetAttachmentById(id uuid.UUID) (*Attachment, error) {
 	return &entry, result.Error
 }
 
+// GetAttachmentVehicleIds lists the vehicles an attachment is linked to,
+// directly, as the file of a document or through one of their entries.
+func GetAttachmentVehicleIds(attachmentId uuid.UUID) ([]uuid.UUID, error) {
+	var vehicleIds []uuid.UUID
+	queries := []*gorm.DB{
+		DB.Model(&VehicleAttachment{}).Where("attachment_id = ?", attachmentId),
+		DB.Model(&VehicleDocument{}).Where("attachment_id = ?", attachmentId),
+		DB.Model(&Fillup{}).Where("id IN (?)", DB.Model(&FillupAttachment{}).Where("attachment_id = ?", attachmentId).Select("fillup_id")),
+		DB.Model(&Expense{}).Where("id IN (?)", DB.Model(&ExpenseAttachment{}).Where("attachment_id = ?", attachmentId).Select("expense_id")),
+	}
+	for _, query := range queries {
+		var ids []uuid.UUID
+		if err := query.Pluck("vehicle_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		vehicleIds = append(vehicleIds, ids...)
+	}
+	return vehicleIds, nil
+}
+
+func IsQuickEntryAttachmentOfUser(attachmentId, userId uuid.UUID) (bool, error) {
+	var count int64
+	result := DB.Model(&QuickEntry{}).Where("attachment_id = ? AND user_id = ?", attachmentId, userId).Count(&count)
+	return count > 0, result.Error
+}
+
+// GetAttachmentsNotInStorage lists the attachments kept by any other storage
+// backend than the given one.
+func GetAttachmentsNotInStorage(storage string) (*[]Attachment, error) {
+	var attachments []Attachment
+	result := DB.Unscoped().Where("storage <> ? OR storage IS NULL", storage).Order("created_at").Find(&attachments)
+	return &attachments, result.Error
+}
+
+// UpdateAttachmentStorage points every attachment sharing a file to where it
+// has been moved.
+func UpdateAttachmentStorage(fromStorage, fromPath, storage, path string) error {
+	return DB.Unscoped().Model(&Attachment{}).Where("storage = ? AND path = ?", fromStorage, fromPath).Updates(map[string]interface{}{
+		"storage": storage,
+		"path":    path,
+	}).Error
+}
+
+// FindAttachmentByHash finds an attachment with the given content whose file
+// is kept in the given storage, or nil if there is none.
+func FindAttachmentByHash(hash, storage string) (*Attachment, error) {
+	var attachments []Attachment
+	result := DB.Unscoped().Where("hash = ? AND storage = ?", hash, storage).Limit(1).Find(&attachments)
+	if result.Error != nil || len(attachments) == 0 {
+		return nil, result.Error
+	}
+	return &attachments[0], nil
+}
+
+// CountAttachmentsOfFile is how many attachments still refer to a file.
+func CountAttachmentsOfFile(storage, path string) (int64, error) {
+	var count int64
+	result := DB.Unscoped().Model(&Attachment{}).Where("storage = ? AND path = ?", storage, path).Count(&count)
+	return count, result.Error
+}
+
 func GetVehicleAttachments(vehicleId uuid.UUID) (*[]Attachment, error) {
 	var attachments []Attachment
 	vehicle, err := GetVehicleById(vehicleId)
@@ -311,6 +637,97 @@ func GetVehicleAttachments(vehicleId uuid.UUID) (*[]Attachment, error) {
 	return &attachments, nil
 }
 
+func GetFillupAttachments(fillupId uuid.UUID) (*[]Attachment, error) {
+	var attachments []Attachment
+	fillup, err := GetFillupById(fillupId)
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(fillup).Select("attachments.*,fillup_attachments.title").Preload("User").Association("Attachments").Find(&attachments)
+	if err != nil {
+		return nil, err
+	}
+	return &attachments, nil
+}
+
+func GetExpenseAttachments(expenseId uuid.UUID) (*[]Attachment, error) {
+	var attachments []Attachment
+	expense, err := GetExpenseById(expenseId)
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(expense).Select("attachments.*,expense_attachments.title").Preload("User").Association("Attachments").Find(&attachments)
+	if err != nil {
+		return nil, err
+	}
+	return &attachments, nil
+}
+
+// DeleteFillupAttachment unlinks a file from a fillup. The file itself is kept.
+func DeleteFillupAttachment(fillupId, attachmentId uuid.UUID) error {
+	result := DB.Where("fillup_id = ? AND attachment_id = ?", fillupId, attachmentId).Unscoped().Delete(&FillupAttachment{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// DeleteExpenseAttachment unlinks a file from an expense. The file itself is
+// kept.
+func DeleteExpenseAttachment(expenseId, attachmentId uuid.UUID) error {
+	result := DB.Where("expense_id = ? AND attachment_id = ?", expenseId, attachmentId).Unscoped().Delete(&ExpenseAttachment{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func GetVehicleDocumentsByVehicleId(id uuid.UUID) (*[]VehicleDocument, error) {
+	var documents []VehicleDocument
+	result := DB.Preload("Attachment").Where("vehicle_id = ?", id).Order("expiry_date desc").Find(&documents)
+	return &documents, result.Error
+}
+
+func GetVehicleDocumentById(id uuid.UUID) (*VehicleDocument, error) {
+	var document VehicleDocument
+	result := DB.Preload("Attachment").First(&document, "id=?", id)
+	return &document, result.Error
+}
+
+// GetExpiringVehicleDocuments returns the documents of the vehicles that expire
+// on or before the given date, including the ones that have expired already.
+func GetExpiringVehicleDocuments(vehicleIds []uuid.UUID, before time.Time) (*[]VehicleDocument, error) {
+	var documents []VehicleDocument
+	result := DB.Preload("Attachment").Where("vehicle_id in ? AND expiry_date IS NOT NULL AND expiry_date <= ?", vehicleIds, before).Order("expiry_date").Find(&documents)
+	return &documents, result.Error
+}
+
+func DeleteAlertById(id uuid.UUID) error {
+	result := DB.Where("vehicle_alert_id=?", id).Unscoped().Delete(&AlertOccurance{})
+	if result.Error != nil {
+		return result.Error
+	}
+	result = DB.Where("id=?", id).Unscoped().Delete(&VehicleAlert{})
+	return result.Error
+}
+
+func GetNotificationsByUserId(id uuid.UUID) (*[]Notification, error) {
+	var notifications []Notification
+	result := DB.Where("user_id = ?", id).Order("date desc").Find(&notifications)
+	return &notifications, result.Error
+}
+
+func MarkNotificationAsRead(id, userId uuid.UUID, date time.Time) error {
+	result := DB.Model(&Notification{}).Where("id = ? AND user_id = ?", id, userId).Update("read_date", date)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func GeAlertById(id uuid.UUID) (*VehicleAlert, error) {
 	var alert VehicleAlert
 	result := DB.Preload(clause.Associations).First(&alert, "id=?", id)
@@ -1,5 +1,7 @@
 package db
 
+import "math"
+
 type FuelUnit int
 
 const (
@@ -29,6 +31,29 @@ const (
 	KILOMETERS
 )
 
+const kilometersPerMile = 1.609344
+
+// DistanceConversionFactor is what a distance in one unit is multiplied by to
+// express it in another.
+func DistanceConversionFactor(from, to DistanceUnit) float64 {
+	if from == to {
+		return 1
+	}
+	if from == MILES {
+		return kilometersPerMile
+	}
+	return 1 / kilometersPerMile
+}
+
+// ConvertDistance converts a distance, like an odometer reading, from one unit
+// to another, rounding to the nearest whole unit.
+func ConvertDistance(value int, from, to DistanceUnit) int {
+	if from == to {
+		return value
+	}
+	return int(math.Round(float64(value) * DistanceConversionFactor(from, to)))
+}
+
 type Role int
 
 const (
@@ -51,6 +76,89 @@ const (
 	BOTH
 )
 
+type TyreSeason int
+
+const (
+	SUMMER TyreSeason = iota
+	WINTER
+	ALL_SEASON
+)
+
+type ExpenseLineType int
+
+const (
+	PART ExpenseLineType = iota
+	LABOUR
+	OTHER_LINE
+)
+
+type RecurrenceFrequency int
+
+const (
+	WEEKLY RecurrenceFrequency = iota
+	MONTHLY
+	YEARLY
+)
+
+type DocumentType int
+
+const (
+	INSURANCE_DOCUMENT DocumentType = iota
+	REGISTRATION_DOCUMENT
+	ROADWORTHINESS_DOCUMENT
+	WARRANTY_DOCUMENT
+	OTHER_DOCUMENT
+)
+
+type VehicleStatus int
+
+const (
+	ACTIVE_VEHICLE VehicleStatus = iota
+	SOLD_VEHICLE
+	ARCHIVED_VEHICLE
+	SCRAPPED_VEHICLE
+)
+
+type CustomFieldType int
+
+const (
+	TEXT_FIELD CustomFieldType = iota
+	NUMBER_FIELD
+	DATE_FIELD
+	ENUM_FIELD
+)
+
+type CustomFieldEntity int
+
+const (
+	VEHICLE_ENTITY CustomFieldEntity = iota
+	FILLUP_ENTITY
+	EXPENSE_ENTITY
+)
+
+type AuditAction int
+
+const (
+	CREATE_ACTION AuditAction = iota
+	UPDATE_ACTION
+	DELETE_ACTION
+	RESTORE_ACTION
+	SHARE_ACTION
+	UNSHARE_ACTION
+	TRANSFER_ACTION
+	ENABLE_ACTION
+	DISABLE_ACTION
+)
+
+type AuditEntity int
+
+const (
+	AUDIT_VEHICLE AuditEntity = iota
+	AUDIT_FILLUP
+	AUDIT_EXPENSE
+	AUDIT_USER
+)
+
 type EnumDetail struct {
 	Key string `json:"key"`
 }
@@ -107,3 +215,144 @@ var RoleDetails map[Role]EnumDetail = map[Role]EnumDetail{
 		Key: "USER",
 	},
 }
+
+var TyreSeasonDetails map[TyreSeason]EnumDetail = map[TyreSeason]EnumDetail{
+	SUMMER: {
+		Key: "summer",
+	},
+	WINTER: {
+		Key: "winter",
+	},
+	ALL_SEASON: {
+		Key: "allSeason",
+	},
+}
+
+var ExpenseLineTypeDetails map[ExpenseLineType]EnumDetail = map[ExpenseLineType]EnumDetail{
+	PART: {
+		Key: "part",
+	},
+	LABOUR: {
+		Key: "labour",
+	},
+	OTHER_LINE: {
+		Key: "other",
+	},
+}
+
+var RecurrenceFrequencyDetails map[RecurrenceFrequency]EnumDetail = map[RecurrenceFrequency]EnumDetail{
+	WEEKLY: {
+		Key: "weekly",
+	},
+	MONTHLY: {
+		Key: "monthly",
+	},
+	YEARLY: {
+		Key: "yearly",
+	},
+}
+
+var DocumentTypeDetails map[DocumentType]EnumDetail = map[DocumentType]EnumDetail{
+	INSURANCE_DOCUMENT: {
+		Key: "insurance",
+	},
+	REGISTRATION_DOCUMENT: {
+		Key: "registration",
+	},
+	ROADWORTHINESS_DOCUMENT: {
+		Key: "roadworthiness",
+	},
+	WARRANTY_DOCUMENT: {
+		Key: "warranty",
+	},
+	OTHER_DOCUMENT: {
+		Key: "other",
+	},
+}
+
+var VehicleStatusDetails map[VehicleStatus]EnumDetail = map[VehicleStatus]EnumDetail{
+	ACTIVE_VEHICLE: {
+		Key: "active",
+	},
+	SOLD_VEHICLE: {
+		Key: "sold",
+	},
+	ARCHIVED_VEHICLE: {
+		Key: "archived",
+	},
+	SCRAPPED_VEHICLE: {
+		Key: "scrapped",
+	},
+}
+
+var CustomFieldTypeDetails map[CustomFieldType]EnumDetail = map[CustomFieldType]EnumDetail{
+	TEXT_FIELD: {
+		Key: "text",
+	},
+	NUMBER_FIELD: {
+		Key: "number",
+	},
+	DATE_FIELD: {
+		Key: "date",
+	},
+	ENUM_FIELD: {
+		Key: "enum",
+	},
+}
+
+var CustomFieldEntityDetails map[CustomFieldEntity]EnumDetail = map[CustomFieldEntity]EnumDetail{
+	VEHICLE_ENTITY: {
+		Key: "vehicle",
+	},
+	FILLUP_ENTITY: {
+		Key: "fillup",
+	},
+	EXPENSE_ENTITY: {
+		Key: "expense",
+	},
+}
+
+var AuditActionDetails map[AuditAction]EnumDetail = map[AuditAction]EnumDetail{
+	CREATE_ACTION: {
+		Key: "create",
+	},
+	UPDATE_ACTION: {
+		Key: "update",
+	},
+	DELETE_ACTION: {
+		Key: "delete",
+	},
+	RESTORE_ACTION: {
+		Key: "restore",
+	},
+	SHARE_ACTION: {
+		Key: "share",
+	},
+	UNSHARE_ACTION: {
+		Key: "unshare",
+	},
+	TRANSFER_ACTION: {
+		Key: "transfer",
+	},
+	ENABLE_ACTION: {
+		Key: "enable",
+	},
+	DISABLE_ACTION: {
+		Key: "disable",
+	},
+}
+
+var AuditEntityDetails map[AuditEntity]EnumDetail = map[AuditEntity]EnumDetail{
+	AUDIT_VEHICLE: {
+		Key: "vehicle",
+	},
+	AUDIT_FILLUP: {
+		Key: "fillup",
+	},
+	AUDIT_EXPENSE: {
+		Key: "expense",
+	},
+	AUDIT_USER: {
+		Key: "user",
+	},
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type defaultExpenseCategory struct {
+	Name     string
+	Icon     string
+	Colour   string
+	Aliases  []string
+	Children []defaultExpenseCategory
+}
+
+var defaultExpenseCategories = []defaultExpenseCategory{
+	{
+		Name:    "Maintenance",
+		Icon:    "wrench",
+		Colour:  "#3273dc",
+		Aliases: []string{"service", "servicing", "scheduled maintenance"},
+		Children: []defaultExpenseCategory{
+			{Name: "Oil Change", Icon: "oil", Colour: "#3273dc", Aliases: []string{"oil", "oil service", "maintenance - oil"}},
+			{Name: "Tyres", Icon: "tire", Colour: "#3273dc", Aliases: []string{"tyre", "tires", "tire", "maintenance - tyres", "maintenance - tires"}},
+			{Name: "Brakes", Icon: "car-brake-alert", Colour: "#3273dc", Aliases: []string{"brake", "maintenance - brakes"}},
+		},
+	},
+	{Name: "Repair", Icon: "hammer", Colour: "#f14668", Aliases: []string{"repairs"}},
+	{Name: "Insurance", Icon: "shield-car", Colour: "#48c774", Aliases: []string{"insurance premium"}},
+	{Name: "Tax", Icon: "bank", Colour: "#ffdd57", Aliases: []string{"road tax", "vehicle tax", "taxes"}},
+	{Name: "Registration", Icon: "card-account-details", Colour: "#ffdd57", Aliases: []string{"licensing", "license"}},
+	{Name: "Finance", Icon: "cash", Colour: "#00d1b2", Aliases: []string{"loan", "loan payment", "lease", "financing"}},
+	{Name: "Parking", Icon: "parking", Colour: "#b86bff", Aliases: []string{}},
+	{Name: "Tolls", Icon: "boom-gate", Colour: "#b86bff", Aliases: []string{"toll"}},
+	{Name: "Cleaning", Icon: "car-wash", Colour: "#209cee", Aliases: []string{"car wash", "wash"}},
+	{Name: "Fines", Icon: "alert-octagon", Colour: "#f14668", Aliases: []string{"fine", "ticket", "parking ticket"}},
+	{Name: "Other", Icon: "dots-horizontal", Colour: "#7a7a7a", Aliases: []string{"misc", "miscellaneous"}},
+}
+
+// NormaliseCategoryName is the form in which category names and aliases are
+// compared, so that "Oil  change" and "oil change" are the same category.
+func NormaliseCategoryName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// SeedExpenseCategories creates the system categories. It runs once as a
+// migration so that admins are free to rename them afterwards.
+func SeedExpenseCategories() error {
+	return seedExpenseCategories(defaultExpenseCategories, nil)
+}
+
+func seedExpenseCategories(categories []defaultExpenseCategory, parentId *uuid.UUID) error {
+	for _, item := range categories {
+		var category ExpenseCategory
+		result := DB.Where("is_system = ? AND name = ?", true, item.Name).First(&category)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			category = ExpenseCategory{
+				Name:     item.Name,
+				ParentID: parentId,
+				Icon:     item.Icon,
+				Colour:   item.Colour,
+				IsSystem: true,
+			}
+			for _, alias := range item.Aliases {
+				category.Aliases = append(category.Aliases, ExpenseCategoryAlias{Alias: NormaliseCategoryName(alias)})
+			}
+			if err := DB.Create(&category).Error; err != nil {
+				return err
+			}
+		} else if result.Error != nil {
+			return result.Error
+		}
+		if err := seedExpenseCategories(item.Children, &category.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetAllExpenseCategories() (*[]ExpenseCategory, error) {
+	var categories []ExpenseCategory
+	result := DB.Preload("Aliases").Order("name").Find(&categories)
+	return &categories, result.Error
+}
+
+func GetExpenseCategoryById(id uuid.UUID) (*ExpenseCategory, error) {
+	var category ExpenseCategory
+	result := DB.Preload("Aliases").First(&category, "id=?", id)
+	return &category, result.Error
+}
+
+// FindExpenseCategoryByName looks a category up by its name first and then by
+// its aliases.
+func FindExpenseCategoryByName(name string) (*ExpenseCategory, error) {
+	normalised := NormaliseCategoryName(name)
+	var category ExpenseCategory
+	result := DB.Preload("Aliases").Where("lower(name) = ?", normalised).Order("is_system desc").First(&category)
+	if result.Error == nil || !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return &category, result.Error
+	}
+	var alias ExpenseCategoryAlias
+	result = DB.Where("alias = ?", normalised).First(&alias)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return GetExpenseCategoryById(alias.ExpenseCategoryID)
+}
+
+// AssignExpenseCategories links every uncategorised expense to the category
+// matching its free text expense type. Types which match no category are left
+// as they are.
+func AssignExpenseCategories() error {
+	var expenseTypes []string
+	tx := DB.Model(&Expense{}).Where("expense_category_id IS NULL AND expense_type <> ''").Distinct().Pluck("expense_type", &expenseTypes)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, expenseType := range expenseTypes {
+		category, err := FindExpenseCategoryByName(expenseType)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		tx = DB.Model(&Expense{}).Where("expense_category_id IS NULL AND expense_type = ?", expenseType).Updates(map[string]interface{}{
+			"expense_category_id": category.ID,
+			"expense_type":        category.Name,
+		})
+		if tx.Error != nil {
+			return tx.Error
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NormaliseStationName is the form in which station names and aliases are
+// compared, so that "Shell - Main St." and "shell main st" are the same
+// station.
+func NormaliseStationName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// StationNameUsage is a free text station name found on fillups which are not
+// linked to a station.
+type StationNameUsage struct {
+	FillingStation string
+	CountFillups   int
+}
+
+// StationUsage is the number of fillups linked to a station.
+type StationUsage struct {
+	FillingStationID uuid.UUID
+	CountFillups     int
+}
+
+func GetAllFillingStations() (*[]FillingStation, error) {
+	var stations []FillingStation
+	result := DB.Preload("Aliases").Order("name").Find(&stations)
+	return &stations, result.Error
+}
+
+func GetFillingStationById(id uuid.UUID) (*FillingStation, error) {
+	var station FillingStation
+	result := DB.Preload("Aliases").First(&station, "id=?", id)
+	return &station, result.Error
+}
+
+// FindFillingStationByName looks a station up by its name first and then by
+// its aliases.
+func FindFillingStationByName(name string) (*FillingStation, error) {
+	normalised := NormaliseStationName(name)
+	if normalised == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var stations []FillingStation
+	if err := DB.Select("id", "name").Find(&stations).Error; err != nil {
+		return nil, err
+	}
+	for _, station := range stations {
+		if NormaliseStationName(station.Name) == normalised {
+			return GetFillingStationById(station.ID)
+		}
+	}
+	var alias FillingStationAlias
+	result := DB.Where("alias = ?", normalised).First(&alias)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return GetFillingStationById(alias.FillingStationID)
+}
+
+func DeleteFillingStationById(id uuid.UUID) error {
+	tx := DB.Begin()
+	if err := tx.Model(&Fillup{}).Where("filling_station_id = ?", id).Update("filling_station_id", nil).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("filling_station_id = ?", id).Unscoped().Delete(&FillingStationAlias{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("id = ?", id).Unscoped().Delete(&FillingStation{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetUnlinkedStationNames lists the free text station names of the fillups
+// which are not linked to a station, most used first.
+func GetUnlinkedStationNames() ([]StationNameUsage, error) {
+	var usages []StationNameUsage
+	result := DB.Model(&Fillup{}).
+		Select("filling_station, count(*) as count_fillups").
+		Where("filling_station_id IS NULL AND filling_station <> ''").
+		Group("filling_station").
+		Order("count_fillups desc").
+		Scan(&usages)
+	return usages, result.Error
+}
+
+// GetStationUsage counts the fillups of the vehicles linked to each station.
+func GetStationUsage(vehicleIds []uuid.UUID) ([]StationUsage, error) {
+	var usages []StationUsage
+	if len(vehicleIds) == 0 {
+		return usages, nil
+	}
+	result := DB.Model(&Fillup{}).
+		Select("filling_station_id, count(*) as count_fillups").
+		Where("filling_station_id IS NOT NULL AND vehicle_id in ?", vehicleIds).
+		Group("filling_station_id").
+		Scan(&usages)
+	return usages, result.Error
+}
+
+// LinkFillupsToStation links the unlinked fillups carrying one of the free text
+// names to the station and renames them after it.
+func LinkFillupsToStation(tx *gorm.DB, station *FillingStation, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[NormaliseStationName(name)] = true
+	}
+	var existing []string
+	if err := tx.Model(&Fillup{}).Where("filling_station_id IS NULL AND filling_station <> ''").Distinct().Pluck("filling_station", &existing).Error; err != nil {
+		return err
+	}
+	var matching []string
+	for _, name := range existing {
+		if wanted[NormaliseStationName(name)] {
+			matching = append(matching, name)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+	return tx.Model(&Fillup{}).Where("filling_station_id IS NULL AND filling_station in ?", matching).Updates(map[string]interface{}{
+		"filling_station_id": station.ID,
+		"filling_station":    station.Name,
+	}).Error
+}
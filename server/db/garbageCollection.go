@@ -0,0 +1,83 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetAllAttachments lists every attachment, for checking them against the
+// files in storage.
+func GetAllAttachments() (*[]Attachment, error) {
+	var attachments []Attachment
+	result := DB.Unscoped().Find(&attachments)
+	return &attachments, result.Error
+}
+
+// GetQuickEntriesProcessedBefore lists the quick entries which were turned
+// into fillups or expenses, or marked as processed, before the given date.
+func GetQuickEntriesProcessedBefore(before time.Time) (*[]QuickEntry, error) {
+	var quickEntries []QuickEntry
+	result := DB.Unscoped().Where("process_date IS NOT NULL AND process_date < ?", before).Find(&quickEntries)
+	return &quickEntries, result.Error
+}
+
+// GetUnlinkedQuickEntries lists the quick entries whose photo attachment is
+// gone. A photo whose file is missing doesn't count, as the file may only be
+// out of reach for now.
+func GetUnlinkedQuickEntries() (*[]QuickEntry, error) {
+	var quickEntries []QuickEntry
+	result := DB.Unscoped().Where("attachment_id NOT IN (?)", DB.Unscoped().Model(&Attachment{}).Select("id")).Find(&quickEntries)
+	return &quickEntries, result.Error
+}
+
+// GetUnreferencedAttachments lists the attachments created before the given
+// date which no vehicle, entry, document or quick entry uses. Quick entries
+// which are about to be removed are not counted as users.
+func GetUnreferencedAttachments(createdBefore time.Time, goneQuickEntryIds []uuid.UUID) (*[]Attachment, error) {
+	quickEntries := DB.Unscoped().Model(&QuickEntry{}).Select("attachment_id")
+	if len(goneQuickEntryIds) > 0 {
+		quickEntries = quickEntries.Where("id NOT IN ?", goneQuickEntryIds)
+	}
+	var attachments []Attachment
+	result := DB.Unscoped().
+		Where("created_at < ?", createdBefore).
+		Where("id NOT IN (?)", DB.Unscoped().Model(&VehicleAttachment{}).Select("attachment_id")).
+		Where("id NOT IN (?)", DB.Unscoped().Model(&FillupAttachment{}).Select("attachment_id")).
+		Where("id NOT IN (?)", DB.Unscoped().Model(&ExpenseAttachment{}).Select("attachment_id")).
+		Where("id NOT IN (?)", DB.Unscoped().Model(&VehicleDocument{}).Select("attachment_id").Where("attachment_id IS NOT NULL")).
+		Where("id NOT IN (?)", quickEntries).
+		Find(&attachments)
+	return &attachments, result.Error
+}
+
+// DeleteQuickEntries permanently removes quick entries. Their photos are left
+// for the garbage collection to pick up.
+func DeleteQuickEntries(tx *gorm.DB, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ?", ids).Unscoped().Delete(&QuickEntry{}).Error
+}
+
+// DeleteAttachments permanently removes attachments along with their links to
+// vehicles and entries. Documents keep their details without the file.
+func DeleteAttachments(tx *gorm.DB, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("attachment_id IN ?", ids).Unscoped().Delete(&VehicleAttachment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("attachment_id IN ?", ids).Unscoped().Delete(&FillupAttachment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("attachment_id IN ?", ids).Unscoped().Delete(&ExpenseAttachment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&VehicleDocument{}).Where("attachment_id IN ?", ids).Update("attachment_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Unscoped().Delete(&Attachment{}).Error
+}
@@ -9,8 +9,9 @@ import (
 )
 
 type localMigration struct {
-	Name  string
-	Query string
+	Name     string
+	Query    string
+	Function func() error
 }
 
 var migrations = []localMigration{
@@ -22,11 +23,28 @@ var migrations = []localMigration{
 		Name:  "2021_02_07_00_09_LowerCaseEmails",
 		Query: "update users set email=lower(email)",
 	},
+	{
+		Name:     "2026_10_19_09_00_SeedExpenseCategories",
+		Function: SeedExpenseCategories,
+	},
+	{
+		Name:     "2026_10_19_09_01_AssignExpenseCategories",
+		Function: AssignExpenseCategories,
+	},
+	{
+		Name:     "2026_10_19_10_00_SetVehicleDistanceUnits",
+		Function: SetVehicleDistanceUnits,
+	},
 }
 
 func RunMigrations() {
 	for _, mig := range migrations {
-		err := ExecuteAndSaveMigration(mig.Name, mig.Query)
+		var err error
+		if mig.Function != nil {
+			err = ExecuteAndSaveMigrationFunction(mig.Name, mig.Function)
+		} else {
+			err = ExecuteAndSaveMigration(mig.Name, mig.Query)
+		}
 		if err != nil {
 			fmt.Printf("migration failed for '%s' due to error: %s\n", mig.Name, err)
 			return
@@ -51,3 +69,60 @@ func ExecuteAndSaveMigration(name string, query string) error {
 
 	return nil
 }
+
+func ExecuteAndSaveMigrationFunction(name string, function func() error) error {
+	var migration Migration
+	result := DB.Where("name=?", name).First(&migration)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		fmt.Println("running migration " + name)
+		err := function()
+		if err == nil {
+			DB.Save(&Migration{
+				Date: time.Now(),
+				Name: name,
+			})
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SetVehicleDistanceUnits gives every vehicle the distance unit of its owner.
+// Readings were typed off the odometer of the vehicle, so they are already in
+// its unit whatever their entries say and only get relabelled.
+func SetVehicleDistanceUnits() error {
+	var vehicles []Vehicle
+	if err := DB.Select("id").Find(&vehicles).Error; err != nil {
+		return err
+	}
+	for _, vehicle := range vehicles {
+		ownerId, err := GetVehicleOwner(vehicle.ID)
+		if err != nil {
+			if err := DB.Model(&Vehicle{}).Where("id = ?", vehicle.ID).Update("distance_unit", KILOMETERS).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		owner, err := GetUserById(ownerId)
+		if err != nil {
+			return err
+		}
+		tx := DB.Begin()
+		if err := tx.Model(&Vehicle{}).Where("id = ?", vehicle.ID).Update("distance_unit", owner.DistanceUnit).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, model := range []interface{}{&Fillup{}, &Expense{}} {
+			err := tx.Model(model).Where("vehicle_id = ? AND distance_unit <> ?", vehicle.ID, owner.DistanceUnit).Update("distance_unit", owner.DistanceUnit).Error
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
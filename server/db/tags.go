@@ -0,0 +1,49 @@
+package db
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// NormaliseTagName is the form in which tags are stored, so that "Road trip"
+// and "road-trip" are the same tag.
+func NormaliseTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+// TagUsage is a tag along with the number of entries carrying it.
+type TagUsage struct {
+	Tag
+	CountFillups  int `json:"countFillups"`
+	CountExpenses int `json:"countExpenses"`
+}
+
+func GetAllTags() (*[]TagUsage, error) {
+	var tags []TagUsage
+	result := DB.Model(&Tag{}).
+		Select("tags.*, (SELECT COUNT(*) FROM fillup_tags WHERE fillup_tags.tag_id = tags.id) AS count_fillups, (SELECT COUNT(*) FROM expense_tags WHERE expense_tags.tag_id = tags.id) AS count_expenses").
+		Order("name").
+		Find(&tags)
+	return &tags, result.Error
+}
+
+// FindOrCreateTags returns the tags with the given names, creating those that
+// do not exist yet. Blank and repeated names are skipped.
+func FindOrCreateTags(tx *gorm.DB, names []string) ([]Tag, error) {
+	tags := make([]Tag, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = NormaliseTagName(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		var tag Tag
+		if err := tx.Where(Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
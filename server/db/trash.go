@@ -0,0 +1,141 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetTrashedVehiclesByOwner lists the vehicles in the trash which the user
+// owns, most recently deleted first.
+func GetTrashedVehiclesByOwner(userId uuid.UUID) (*[]Vehicle, error) {
+	var vehicles []Vehicle
+	result := DB.Unscoped().
+		Model(&Vehicle{}).
+		Joins("JOIN user_vehicles ON user_vehicles.vehicle_id = vehicles.id").
+		Where("user_vehicles.user_id = ? AND user_vehicles.is_owner = ? AND vehicles.deleted_at IS NOT NULL", userId, true).
+		Select("vehicles.*, user_vehicles.is_owner").
+		Order("vehicles.deleted_at desc").
+		Find(&vehicles)
+	return &vehicles, result.Error
+}
+
+// GetAnyVehicleById loads a vehicle whether it is in the trash or not.
+func GetAnyVehicleById(id uuid.UUID) (*Vehicle, error) {
+	var vehicle Vehicle
+	result := DB.Unscoped().First(&vehicle, "id=?", id)
+	return &vehicle, result.Error
+}
+
+// GetTrashedVehicleById loads a vehicle which is in the trash.
+func GetTrashedVehicleById(id uuid.UUID) (*Vehicle, error) {
+	var vehicle Vehicle
+	result := DB.Unscoped().Where("deleted_at IS NOT NULL").First(&vehicle, "id=?", id)
+	return &vehicle, result.Error
+}
+
+// GetTrashedFillups lists the fillups of the vehicles which were deleted on
+// their own, most recently deleted first.
+func GetTrashedFillups(vehicleIds []uuid.UUID) (*[]Fillup, error) {
+	var fillups []Fillup
+	result := DB.Unscoped().Where("vehicle_id in ? AND deleted_at IS NOT NULL", vehicleIds).Order("deleted_at desc").Find(&fillups)
+	return &fillups, result.Error
+}
+
+// GetTrashedExpenses lists the expenses of the vehicles which were deleted on
+// their own, most recently deleted first.
+func GetTrashedExpenses(vehicleIds []uuid.UUID) (*[]Expense, error) {
+	var expenses []Expense
+	result := DB.Unscoped().Where("vehicle_id in ? AND deleted_at IS NOT NULL", vehicleIds).Order("deleted_at desc").Find(&expenses)
+	return &expenses, result.Error
+}
+
+func GetTrashedFillupById(id uuid.UUID) (*Fillup, error) {
+	var fillup Fillup
+	result := DB.Unscoped().Where("deleted_at IS NOT NULL").First(&fillup, "id=?", id)
+	return &fillup, result.Error
+}
+
+func GetTrashedExpenseById(id uuid.UUID) (*Expense, error) {
+	var expense Expense
+	result := DB.Unscoped().Where("deleted_at IS NOT NULL").First(&expense, "id=?", id)
+	return &expense, result.Error
+}
+
+// CountTrashedWithVehicle counts the fillups and expenses which went to the
+// trash along with the vehicle.
+func CountTrashedWithVehicle(vehicle *Vehicle) (fillups int64, expenses int64, err error) {
+	err = DB.Unscoped().Model(&Fillup{}).Where("vehicle_id = ? AND deleted_at = ?", vehicle.ID, vehicle.DeletedAt.Time).Count(&fillups).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	err = DB.Unscoped().Model(&Expense{}).Where("vehicle_id = ? AND deleted_at = ?", vehicle.ID, vehicle.DeletedAt.Time).Count(&expenses).Error
+	return fillups, expenses, err
+}
+
+// RestoreVehicle takes a vehicle out of the trash along with the fillups and
+// expenses which went there with it. Those deleted before it stay in the
+// trash.
+func RestoreVehicle(tx *gorm.DB, vehicle *Vehicle) error {
+	deletedAt := vehicle.DeletedAt.Time
+	if err := tx.Unscoped().Model(&Fillup{}).Where("vehicle_id = ? AND deleted_at = ?", vehicle.ID, deletedAt).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&Expense{}).Where("vehicle_id = ? AND deleted_at = ?", vehicle.ID, deletedAt).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&Vehicle{}).Where("id = ?", vehicle.ID).Update("deleted_at", nil).Error
+}
+
+func RestoreFillupById(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Unscoped().Model(&Fillup{}).Where("id = ?", id).Update("deleted_at", nil).Error
+}
+
+func RestoreExpenseById(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Unscoped().Model(&Expense{}).Where("id = ?", id).Update("deleted_at", nil).Error
+}
+
+// GetVehicleIdsTrashedBefore lists the vehicles which went to the trash before
+// the given time.
+func GetVehicleIdsTrashedBefore(before time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	result := DB.Unscoped().Model(&Vehicle{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Pluck("id", &ids)
+	return ids, result.Error
+}
+
+// PurgeFillupsTrashedBefore permanently removes the fillups which went to the
+// trash before the given time.
+func PurgeFillupsTrashedBefore(before time.Time) (int64, error) {
+	var ids []uuid.UUID
+	if err := DB.Unscoped().Model(&Fillup{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tx := DB.Begin()
+	if err := PurgeFillups(tx, ids); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return int64(len(ids)), tx.Commit().Error
+}
+
+// PurgeExpensesTrashedBefore permanently removes the expenses which went to the
+// trash before the given time.
+func PurgeExpensesTrashedBefore(before time.Time) (int64, error) {
+	var ids []uuid.UUID
+	if err := DB.Unscoped().Model(&Expense{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tx := DB.Begin()
+	if err := PurgeExpenses(tx, ids); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return int64(len(ids)), tx.Commit().Error
+}
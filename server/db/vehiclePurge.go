@@ -0,0 +1,163 @@
+package db
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// VehiclePurge counts what was removed along with a vehicle. Files holds the
+// attachments which nothing refers to any more; their files are to be removed
+// from storage once the transaction is committed.
+type VehiclePurge struct {
+	Fillups              int64        `json:"fillups"`
+	Expenses             int64        `json:"expenses"`
+	RecurringExpenses    int64        `json:"recurringExpenses"`
+	Occurrences          int64        `json:"occurrences"`
+	OdometerReplacements int64        `json:"odometerReplacements"`
+	Documents            int64        `json:"documents"`
+	Alerts               int64        `json:"alerts"`
+	AlertOccurrences     int64        `json:"alertOccurrences"`
+	Notifications        int64        `json:"notifications"`
+	TyreSets             int64        `json:"tyreSets"`
+	Shares               int64        `json:"shares"`
+	AttachmentLinks      int64        `json:"attachmentLinks"`
+	Attachments          int64        `json:"attachments"`
+	Files                []Attachment `json:"-"`
+}
+
+// PurgeVehicle permanently removes a vehicle, trashed or not, with everything
+// recorded against it. Attachments are only removed when nothing else, like
+// another vehicle, entry, document or quick entry, still uses them.
+func PurgeVehicle(tx *gorm.DB, vehicleId uuid.UUID) (*VehiclePurge, error) {
+	var purge VehiclePurge
+
+	var attachmentIds []uuid.UUID
+	if err := tx.Model(&VehicleAttachment{}).Where("vehicle_id = ?", vehicleId).Pluck("attachment_id", &attachmentIds).Error; err != nil {
+		return nil, err
+	}
+	var documentAttachmentIds []uuid.UUID
+	if err := tx.Unscoped().Model(&VehicleDocument{}).Where("vehicle_id = ? AND attachment_id IS NOT NULL", vehicleId).Pluck("attachment_id", &documentAttachmentIds).Error; err != nil {
+		return nil, err
+	}
+	attachmentIds = append(attachmentIds, documentAttachmentIds...)
+
+	fillupIds := tx.Unscoped().Model(&Fillup{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	expenseIds := tx.Unscoped().Model(&Expense{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	var fillupAttachmentIds, expenseAttachmentIds []uuid.UUID
+	if err := tx.Model(&FillupAttachment{}).Where("fillup_id IN (?)", fillupIds).Pluck("attachment_id", &fillupAttachmentIds).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&ExpenseAttachment{}).Where("expense_id IN (?)", expenseIds).Pluck("attachment_id", &expenseAttachmentIds).Error; err != nil {
+		return nil, err
+	}
+	attachmentIds = append(attachmentIds, fillupAttachmentIds...)
+	attachmentIds = append(attachmentIds, expenseAttachmentIds...)
+
+	if err := tx.Unscoped().Model(&Fillup{}).Where("vehicle_id = ?", vehicleId).Count(&purge.Fillups).Error; err != nil {
+		return nil, err
+	}
+	if err := PurgeFillups(tx, fillupIds); err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Model(&Expense{}).Where("vehicle_id = ?", vehicleId).Count(&purge.Expenses).Error; err != nil {
+		return nil, err
+	}
+	if err := PurgeExpenses(tx, expenseIds); err != nil {
+		return nil, err
+	}
+
+	recurringIds := tx.Unscoped().Model(&RecurringExpense{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	result := tx.Where("recurring_expense_id IN (?)", recurringIds).Unscoped().Delete(&RecurringExpenseOccurrence{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.Occurrences = result.RowsAffected
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&RecurringExpense{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.RecurringExpenses = result.RowsAffected
+
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&OdometerReplacement{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.OdometerReplacements = result.RowsAffected
+
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&VehicleDocument{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.Documents = result.RowsAffected
+
+	// this takes the reminders of the documents along
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&AlertOccurance{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.AlertOccurrences = result.RowsAffected
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&VehicleAlert{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.Alerts = result.RowsAffected
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&Notification{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.Notifications = result.RowsAffected
+
+	tyreSetIds := tx.Unscoped().Model(&TyreSet{}).Select("id").Where("vehicle_id = ?", vehicleId)
+	if err := tx.Where("tyre_set_id IN (?)", tyreSetIds).Unscoped().Delete(&TyreMounting{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("tyre_set_id IN (?)", tyreSetIds).Unscoped().Delete(&TyreTreadDepth{}).Error; err != nil {
+		return nil, err
+	}
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&TyreSet{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.TyreSets = result.RowsAffected
+
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&UserVehicle{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.Shares = result.RowsAffected
+	result = tx.Where("vehicle_id = ?", vehicleId).Unscoped().Delete(&VehicleAttachment{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	purge.AttachmentLinks = result.RowsAffected
+
+	if len(attachmentIds) > 0 {
+		var orphans []Attachment
+		err := tx.Unscoped().
+			Where("id IN ?", attachmentIds).
+			Where("id NOT IN (?)", tx.Unscoped().Model(&VehicleAttachment{}).Select("attachment_id")).
+			Where("id NOT IN (?)", tx.Unscoped().Model(&FillupAttachment{}).Select("attachment_id")).
+			Where("id NOT IN (?)", tx.Unscoped().Model(&ExpenseAttachment{}).Select("attachment_id")).
+			Where("id NOT IN (?)", tx.Unscoped().Model(&VehicleDocument{}).Select("attachment_id").Where("attachment_id IS NOT NULL")).
+			Where("id NOT IN (?)", tx.Unscoped().Model(&QuickEntry{}).Select("attachment_id")).
+			Find(&orphans).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, attachment := range orphans {
+			if err := tx.Where("id = ?", attachment.ID).Unscoped().Delete(&Attachment{}).Error; err != nil {
+				return nil, err
+			}
+			purge.Attachments++
+			purge.Files = append(purge.Files, attachment)
+		}
+	}
+
+	if err := DeleteCustomFieldValues(tx, VEHICLE_ENTITY, []uuid.UUID{vehicleId}); err != nil {
+		return nil, err
+	}
+	if err := tx.Where("id = ?", vehicleId).Unscoped().Delete(&Vehicle{}).Error; err != nil {
+		return nil, err
+	}
+	return &purge, nil
+}
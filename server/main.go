@@ -25,6 +25,10 @@ func main() {
 
 	db.Migrate()
 
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	r := gin.Default()
 	r.Use(setupSettings())
 	r.Use(gin.Recovery())
@@ -35,11 +39,8 @@ func main() {
 	})
 	router := r.Group("/api")
 
-	dataPath := os.Getenv("DATA")
-
-	router.Static("/assets/", dataPath)
-
 	controllers.RegisterAnonController(router)
+	controllers.RegisterAnonFilesController(router)
 	controllers.RegisterAnonMasterConroller(router)
 	controllers.RegisterSetupController(router)
 
@@ -51,6 +52,19 @@ func main() {
 	controllers.RegisterFilesController(router)
 	controllers.RegisteImportController(router)
 	controllers.RegisterReportsController(router)
+	controllers.RegisterTariffController(router)
+	controllers.RegisterTyreController(router)
+	controllers.RegisterOdometerReplacementController(router)
+	controllers.RegisterExpenseCategoryController(router)
+	controllers.RegisterFillingStationController(router)
+	controllers.RegisterTrashController(router)
+	controllers.RegisterCustomFieldController(router)
+	controllers.RegisterTagController(router)
+	controllers.RegisterRecurringExpenseController(router)
+	controllers.RegisterDocumentController(router)
+	controllers.RegisterNotificationController(router)
+	controllers.RegisterAuditLogController(router)
+	controllers.RegisterEntryAttachmentController(router)
 
 	go assetEnv()
 	go intiCron()
@@ -74,10 +88,28 @@ func setupSettings() gin.HandlerFunc {
 
 func intiCron() {
 
+	db.UnlockMissedJobs()
+
 	err := gocron.Every(2).Days().Do(service.CreateBackup)
 	if err != nil {
 		fmt.Println("failed to setup cron job", err)
 	}
+	err = gocron.Every(1).Hour().From(gocron.NextTick()).Do(service.CreateDueRecurringExpenses)
+	if err != nil {
+		fmt.Println("failed to setup cron job", err)
+	}
+	err = gocron.Every(1).Hour().From(gocron.NextTick()).Do(service.ProcessDueAlerts)
+	if err != nil {
+		fmt.Println("failed to setup cron job", err)
+	}
+	err = gocron.Every(1).Day().From(gocron.NextTick()).Do(service.PurgeTrash)
+	if err != nil {
+		fmt.Println("failed to setup cron job", err)
+	}
+	err = gocron.Every(1).Day().From(gocron.NextTick()).Do(service.CollectGarbage)
+	if err != nil {
+		fmt.Println("failed to setup cron job", err)
+	}
 
 	<-gocron.Start()
 }
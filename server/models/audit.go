@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"hammond/db"
+)
+
+type AuditLogQuery struct {
+	EntityType *db.AuditEntity `json:"entityType" query:"entityType" form:"entityType"`
+	EntityID   string          `json:"entityId" query:"entityId" form:"entityId"`
+	VehicleID  string          `json:"vehicleId" query:"vehicleId" form:"vehicleId"`
+	ActorID    string          `json:"actorId" query:"actorId" form:"actorId"`
+	Action     *db.AuditAction `json:"action" query:"action" form:"action"`
+	Start      time.Time       `json:"start" query:"start" form:"start"`
+	End        time.Time       `json:"end" query:"end" form:"end"`
+	Limit      int             `json:"limit" query:"limit" form:"limit"`
+	Offset     int             `json:"offset" query:"offset" form:"offset"`
+}
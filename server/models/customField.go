@@ -0,0 +1,26 @@
+package models
+
+import "hammond/db"
+
+type CreateCustomFieldRequest struct {
+	EntityType *db.CustomFieldEntity `form:"entityType" json:"entityType" binding:"required"`
+	Key        string                `form:"key" json:"key" binding:"required"`
+	Name       string                `form:"name" json:"name" binding:"required"`
+	FieldType  *db.CustomFieldType   `form:"fieldType" json:"fieldType" binding:"required"`
+	Options    []string              `form:"options" json:"options"`
+	IsRequired bool                  `form:"isRequired" json:"isRequired"`
+	SortOrder  int                   `form:"sortOrder" json:"sortOrder"`
+}
+
+// UpdateCustomFieldRequest leaves out the entity, key and type of a field as
+// the values already recorded depend on them.
+type UpdateCustomFieldRequest struct {
+	Name       string   `form:"name" json:"name" binding:"required"`
+	Options    []string `form:"options" json:"options"`
+	IsRequired bool     `form:"isRequired" json:"isRequired"`
+	SortOrder  int      `form:"sortOrder" json:"sortOrder"`
+}
+
+type CustomFieldQuery struct {
+	EntityType *db.CustomFieldEntity `json:"entityType" query:"entityType" form:"entityType"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"hammond/db"
+)
+
+type CreateVehicleDocumentRequest struct {
+	DocumentType    *db.DocumentType `form:"documentType" json:"documentType" binding:"required"`
+	Title           string           `form:"title" json:"title" binding:"required"`
+	ReferenceNumber string           `form:"referenceNumber" json:"referenceNumber"`
+	IssueDate       *time.Time       `form:"issueDate" json:"issueDate" time_format:"2006-01-02"`
+	ExpiryDate      *time.Time       `form:"expiryDate" json:"expiryDate" time_format:"2006-01-02"`
+	ReminderDays    *int             `form:"reminderDays" json:"reminderDays"`
+	Comments        string           `form:"comments" json:"comments"`
+}
+
+type UpdateVehicleDocumentRequest struct {
+	CreateVehicleDocumentRequest
+}
+
+type ExpiringDocumentsQuery struct {
+	Days int `json:"days" query:"days" form:"days"`
+}
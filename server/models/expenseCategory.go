@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+type CreateExpenseCategoryRequest struct {
+	Name     string     `form:"name" json:"name" binding:"required"`
+	ParentID *uuid.UUID `form:"parentId" json:"parentId"`
+	Icon     string     `form:"icon" json:"icon"`
+	Colour   string     `form:"colour" json:"colour"`
+}
+
+type UpdateExpenseCategoryRequest struct {
+	CreateExpenseCategoryRequest
+}
+
+type MergeExpenseCategoryRequest struct {
+	TargetID uuid.UUID `form:"targetId" json:"targetId" binding:"required"`
+}
+
+type ExpenseCategoryAliasRequest struct {
+	Alias string `form:"alias" json:"alias" binding:"required"`
+}
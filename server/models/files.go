@@ -1,5 +1,110 @@
 package models
 
+import (
+	"time"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+type AttachmentThumbnailQuery struct {
+	Size string `form:"size" json:"size" query:"size" binding:"omitempty,oneof=small medium large"`
+}
+
+// SignedAttachmentQuery carries the signature of a link to an attachment.
+// Size is only used by links to thumbnails.
+type SignedAttachmentQuery struct {
+	Size      string `form:"size" json:"size" query:"size" binding:"omitempty,oneof=small medium large"`
+	Expires   int64  `form:"expires" json:"expires" query:"expires" binding:"required"`
+	Signature string `form:"signature" json:"signature" query:"signature" binding:"required"`
+}
+
+type AttachmentSignedUrlModel struct {
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// UploadSettingsRequest sets the limits of uploads. A MaxUploadSize of zero
+// and no AllowedTypes go back to the defaults.
+type UploadSettingsRequest struct {
+	MaxUploadSize      int64    `form:"maxUploadSize" json:"maxUploadSize" binding:"min=0"`
+	AllowedTypes       []string `form:"allowedTypes" json:"allowedTypes"`
+	StripImageLocation *bool    `form:"stripImageLocation" json:"stripImageLocation"`
+}
+
 type CreateQuickEntryModel struct {
 	Comments string `json:"comments" form:"comments"`
 }
+
+// ConvertQuickEntryRequest carries either the fillup or the expense a quick
+// entry is turned into. Title names the attached photo and defaults to the
+// comments of the quick entry.
+type ConvertQuickEntryRequest struct {
+	Fillup  *CreateFillupRequest  `json:"fillup"`
+	Expense *CreateExpenseRequest `json:"expense"`
+	Title   string                `json:"title"`
+}
+
+type ConvertQuickEntryModel struct {
+	QuickEntryID uuid.UUID   `json:"quickEntryId"`
+	Fillup       *db.Fillup  `json:"fillup,omitempty"`
+	Expense      *db.Expense `json:"expense,omitempty"`
+}
+
+// QuickEntryFillupDefaultsModel is what is known about a fillup from the photo
+// of its quick entry. FillingStation is the nearest station, if any is close.
+type QuickEntryFillupDefaultsModel struct {
+	Date           *time.Time                     `json:"date"`
+	Latitude       *float64                       `json:"latitude"`
+	Longitude      *float64                       `json:"longitude"`
+	Comments       string                         `json:"comments"`
+	FillingStation *FillingStationSuggestionModel `json:"fillingStation"`
+}
+
+// StorageMigrationModel counts the attachments moved into Storage. Missing ones
+// had no file left to move.
+type StorageMigrationModel struct {
+	Storage string   `json:"storage"`
+	Moved   int      `json:"moved"`
+	Missing int      `json:"missing"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors"`
+}
+
+// GarbageCollectionRequest runs the garbage collection. Unless CleanUp is set
+// it only reports what it would remove.
+type GarbageCollectionRequest struct {
+	CleanUp bool `form:"cleanUp" json:"cleanUp"`
+}
+
+// GarbageCollectionSettingsRequest sets how long processed quick entries are
+// kept and whether the daily garbage collection removes what it finds.
+type GarbageCollectionSettingsRequest struct {
+	QuickEntryRetentionDays int   `form:"quickEntryRetentionDays" json:"quickEntryRetentionDays" binding:"required,min=1"`
+	CleanUp                 *bool `form:"cleanUp" json:"cleanUp"`
+}
+
+// GarbageCollectionModel reports what the garbage collection found, and
+// removed if CleanUp is set. MissingFiles are attachments whose file is gone
+// from storage, which are never removed. OrphanedFiles are files no attachment
+// refers to.
+type GarbageCollectionModel struct {
+	CleanUp              bool                `json:"cleanUp"`
+	OrphanedFiles        []OrphanedFileModel `json:"orphanedFiles"`
+	MissingFiles         []db.Attachment     `json:"missingFiles"`
+	OrphanedAttachments  []db.Attachment     `json:"orphanedAttachments"`
+	UnlinkedQuickEntries []uuid.UUID         `json:"unlinkedQuickEntries"`
+	ExpiredQuickEntries  []uuid.UUID         `json:"expiredQuickEntries"`
+	StaleThumbnails      int                 `json:"staleThumbnails"`
+	FreedBytes           int64               `json:"freedBytes"`
+	Errors               []string            `json:"errors"`
+}
+
+type OrphanedFileModel struct {
+	Storage string    `json:"storage"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+type CreateFillingStationRequest struct {
+	Name      string   `form:"name" json:"name" binding:"required"`
+	Brand     string   `form:"brand" json:"brand"`
+	Latitude  *float64 `form:"latitude" json:"latitude"`
+	Longitude *float64 `form:"longitude" json:"longitude"`
+	Address   string   `form:"address" json:"address"`
+	Notes     string   `form:"notes" json:"notes"`
+}
+
+type UpdateFillingStationRequest struct {
+	CreateFillingStationRequest
+}
+
+type MergeFillingStationRequest struct {
+	TargetID uuid.UUID `form:"targetId" json:"targetId" binding:"required"`
+}
+
+// FillingStationNamesRequest carries free text station names, as found on
+// fillups, which are to be folded into a station.
+type FillingStationNamesRequest struct {
+	Names []string `form:"names" json:"names" binding:"required,min=1"`
+}
+
+type FillingStationMatchQuery struct {
+	Name string `form:"name" json:"name" query:"name" binding:"required"`
+}
+
+type FillingStationAutocompleteQuery struct {
+	Query     string   `form:"query" json:"query" query:"query"`
+	Latitude  *float64 `form:"latitude" json:"latitude" query:"latitude"`
+	Longitude *float64 `form:"longitude" json:"longitude" query:"longitude"`
+	Limit     int      `form:"limit" json:"limit" query:"limit"`
+}
+
+// FillingStationMatchModel is a station resembling a name, with a score from 0
+// to 1 of how close the best of its names is.
+type FillingStationMatchModel struct {
+	Station db.FillingStation `json:"station"`
+	Score   float64           `json:"score"`
+}
+
+// UnlinkedStationNameModel is a free text station name which no station claims
+// yet, along with the stations and the other free text names resembling it.
+type UnlinkedStationNameModel struct {
+	Name         string                     `json:"name"`
+	CountFillups int                        `json:"countFillups"`
+	Matches      []FillingStationMatchModel `json:"matches"`
+	Similar      []string                   `json:"similar"`
+}
+
+type FillingStationSuggestionModel struct {
+	Station      db.FillingStation `json:"station"`
+	CountFillups int               `json:"countFillups"`
+	Distance     *float32          `json:"distance"`
+	DistanceUnit db.DistanceUnit   `json:"distanceUnit"`
+	Score        float64           `json:"score"`
+}
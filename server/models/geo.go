@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+type FillupLocationQueryModel struct {
+	Start           time.Time `json:"start" query:"start" form:"start"`
+	End             time.Time `json:"end" query:"end" form:"end"`
+	IncludeInactive bool      `json:"includeInactive" query:"includeInactive" form:"includeInactive"`
+}
+
+// GeoJSONFeatureCollection is a GeoJSON (RFC 7946) collection of the fillups
+// which have a location, ready to be drawn on a map.
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                   `json:"type"`
+	Geometry   GeoJSONPoint             `json:"geometry"`
+	Properties FillupLocationProperties `json:"properties"`
+}
+
+// GeoJSONPoint holds its coordinates as longitude, latitude as the spec
+// requires.
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type FillupLocationProperties struct {
+	FillupID         uuid.UUID   `json:"fillupId"`
+	VehicleID        uuid.UUID   `json:"vehicleId"`
+	VehicleNickname  string      `json:"vehicleNickname"`
+	Date             time.Time   `json:"date"`
+	FillingStation   string      `json:"fillingStation"`
+	FillingStationID *uuid.UUID  `json:"fillingStationId"`
+	Address          string      `json:"address"`
+	FuelUnit         db.FuelUnit `json:"fuelUnit"`
+	FuelSubType      string      `json:"fuelSubType"`
+	FuelQuantity     float32     `json:"fuelQuantity"`
+	PerUnitPrice     float32     `json:"perUnitPrice"`
+	TotalAmount      float32     `json:"totalAmount"`
+	Currency         string      `json:"currency"`
+}
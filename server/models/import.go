@@ -21,4 +21,21 @@ type ImportFillup struct {
 	UserID          uuid.UUID `gorm:"type:uuid" json:"userId"`
 	Date            string    `json:"date"`
 	FuelSubType     string    `json:"fuelSubType"`
+	Latitude        *float64  `json:"latitude"`
+	Longitude       *float64  `json:"longitude"`
+	Address         string    `json:"address"`
+}
+
+// ArchiveImportModel counts what was created from a portable archive. The
+// warnings tell about what was left out of it.
+type ArchiveImportModel struct {
+	Users       int      `json:"users"`
+	Vehicles    int      `json:"vehicles"`
+	Fillups     int      `json:"fillups"`
+	Expenses    int      `json:"expenses"`
+	Alerts      int      `json:"alerts"`
+	Documents   int      `json:"documents"`
+	Attachments int      `json:"attachments"`
+	Shares      int      `json:"shares"`
+	Warnings    []string `json:"warnings"`
 }
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type OdometerReplacementRequest struct {
+	Date           time.Time `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	LastOdoReading int       `form:"lastOdoReading" json:"lastOdoReading" binding:"required"`
+	NewOdoReading  int       `form:"newOdoReading" json:"newOdoReading"`
+	Comments       string    `form:"comments" json:"comments"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+type CreateRecurringExpenseRequest struct {
+	Title             string                  `form:"title" json:"title" binding:"required"`
+	Amount            float32                 `form:"amount" json:"amount" binding:"required"`
+	ExpenseType       string                  `form:"expenseType" json:"expenseType"`
+	ExpenseCategoryID *uuid.UUID              `form:"expenseCategoryId" json:"expenseCategoryId"`
+	Vendor            string                  `form:"vendor" json:"vendor"`
+	Comments          string                  `form:"comments" json:"comments"`
+	Frequency         *db.RecurrenceFrequency `form:"frequency" json:"frequency" binding:"required"`
+	Interval          int                     `form:"interval" json:"interval"`
+	StartDate         time.Time               `form:"startDate" json:"startDate" binding:"required" time_format:"2006-01-02"`
+	EndDate           *time.Time              `form:"endDate" json:"endDate" time_format:"2006-01-02"`
+	IsActive          *bool                   `form:"isActive" json:"isActive"`
+}
+
+type UpdateRecurringExpenseRequest struct {
+	CreateRecurringExpenseRequest
+}
+
+type RecurringExpenseOccurrenceRequest struct {
+	Date      time.Time `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	IsSkipped bool      `form:"isSkipped" json:"isSkipped"`
+	Amount    *float32  `form:"amount" json:"amount"`
+	Comments  string    `form:"comments" json:"comments"`
+}
+
+type RecurringExpenseScheduleQuery struct {
+	Count int `json:"count" query:"count" form:"count"`
+}
+
+type RecurringExpenseOccurrenceModel struct {
+	Date        time.Time  `json:"date"`
+	Amount      float32    `json:"amount"`
+	Comments    string     `json:"comments"`
+	IsSkipped   bool       `json:"isSkipped"`
+	IsGenerated bool       `json:"isGenerated"`
+	ExpenseID   *uuid.UUID `json:"expenseId"`
+}
@@ -10,16 +10,18 @@ import (
 )
 
 type MileageModel struct {
-	Date         time.Time       `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
-	VehicleID    uuid.UUID       `form:"vehicleId" gorm:"type:uuid" json:"vehicleId" binding:"required"`
-	FuelUnit     db.FuelUnit     `form:"fuelUnit" json:"fuelUnit" binding:"required"`
-	FuelQuantity float32         `form:"fuelQuantity" json:"fuelQuantity" binding:"required"`
-	PerUnitPrice float32         `form:"perUnitPrice" json:"perUnitPrice" binding:"required"`
-	Currency     string          `json:"currency"`
-	DistanceUnit db.DistanceUnit `form:"distanceUnit" json:"distanceUnit"`
-	Mileage      float32         `form:"mileage" json:"mileage" binding:"mileage"`
-	CostPerMile  float32         `form:"costPerMile" json:"costPerMile" binding:"costPerMile"`
-	OdoReading   int             `form:"odoReading" json:"odoReading" binding:"odoReading"`
+	Date           time.Time       `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	VehicleID      uuid.UUID       `form:"vehicleId" gorm:"type:uuid" json:"vehicleId" binding:"required"`
+	FuelUnit       db.FuelUnit     `form:"fuelUnit" json:"fuelUnit" binding:"required"`
+	FuelQuantity   float32         `form:"fuelQuantity" json:"fuelQuantity" binding:"required"`
+	PerUnitPrice   float32         `form:"perUnitPrice" json:"perUnitPrice" binding:"required"`
+	Currency       string          `json:"currency"`
+	DistanceUnit   db.DistanceUnit `form:"distanceUnit" json:"distanceUnit"`
+	Mileage        float32         `form:"mileage" json:"mileage" binding:"mileage"`
+	CostPerMile    float32         `form:"costPerMile" json:"costPerMile" binding:"costPerMile"`
+	OdoReading     int             `form:"odoReading" json:"odoReading" binding:"odoReading"`
+	TrueOdoReading int             `json:"trueOdoReading"`
+	Tags           []string        `json:"tags"`
 }
 
 func (v *MileageModel) FuelUnitDetail() db.EnumDetail {
@@ -39,4 +41,16 @@ func (b *MileageModel) MarshalJSON() ([]byte, error) {
 type MileageQueryModel struct {
 	Since         time.Time `json:"since" query:"since" form:"since"`
 	MileageOption string    `json:"mileageOption" query:"mileageOption" form:"mileageOption"`
+	Tags          []string  `json:"tags" query:"tags" form:"tags"`
+}
+
+// TagMileageModel is the overall mileage of the fills carrying a tag, worked
+// out from the total distance and fuel rather than averaged over fills.
+type TagMileageModel struct {
+	Tag          string          `json:"tag"`
+	CountFillups int             `json:"countFillups"`
+	Distance     float32         `json:"distance"`
+	FuelQuantity float32         `json:"fuelQuantity"`
+	Mileage      float32         `json:"mileage"`
+	DistanceUnit db.DistanceUnit `json:"distanceUnit"`
 }
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+type CreateElectricityTariffRequest struct {
+	Name          string                       `form:"name" json:"name" binding:"required"`
+	Currency      string                       `form:"currency" json:"currency"`
+	BasePrice     float32                      `form:"basePrice" json:"basePrice"`
+	EffectiveFrom time.Time                    `form:"effectiveFrom" json:"effectiveFrom" binding:"required" time_format:"2006-01-02"`
+	EffectiveTo   *time.Time                   `form:"effectiveTo" json:"effectiveTo" time_format:"2006-01-02"`
+	Bands         []ElectricityTariffBandModel `form:"bands" json:"bands" binding:"dive"`
+}
+
+type UpdateElectricityTariffRequest struct {
+	CreateElectricityTariffRequest
+}
+
+type ElectricityTariffBandModel struct {
+	Name         string  `json:"name"`
+	StartTime    string  `json:"startTime" binding:"required"`
+	EndTime      string  `json:"endTime" binding:"required"`
+	PerUnitPrice float32 `json:"perUnitPrice"`
+}
+
+type ChargingCostQueryModel struct {
+	Start           time.Time `json:"start" query:"start" form:"start"`
+	End             time.Time `json:"end" query:"end" form:"end"`
+	IncludeInactive bool      `json:"includeInactive" query:"includeInactive" form:"includeInactive"`
+}
+
+type ChargingCostModel struct {
+	Currency           string  `json:"currency"`
+	HomeSessions       int     `json:"homeSessions"`
+	HomeQuantity       float32 `json:"homeQuantity"`
+	HomeCost           float32 `json:"homeCost"`
+	HomeAvgPrice       float32 `json:"homeAvgPrice"`
+	PublicSessions     int     `json:"publicSessions"`
+	PublicQuantity     float32 `json:"publicQuantity"`
+	PublicCost         float32 `json:"publicCost"`
+	PublicAvgPrice     float32 `json:"publicAvgPrice"`
+	HomeChargingSaving float32 `json:"homeChargingSaving"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+// TrashModel holds what a user has deleted. Fillups and expenses deleted along
+// with a vehicle are counted against it rather than listed.
+type TrashModel struct {
+	Vehicles      []TrashVehicleModel `json:"vehicles"`
+	Fillups       []db.Fillup         `json:"fillups"`
+	Expenses      []db.Expense        `json:"expenses"`
+	RetentionDays int                 `json:"retentionDays"`
+}
+
+type TrashVehicleModel struct {
+	Vehicle       db.Vehicle `json:"vehicle"`
+	CountFillups  int64      `json:"countFillups"`
+	CountExpenses int64      `json:"countExpenses"`
+	PurgeDate     time.Time  `json:"purgeDate"`
+}
+
+type TrashSettingsRequest struct {
+	RetentionDays int `form:"retentionDays" json:"retentionDays" binding:"required,min=1"`
+}
+
+type TrashPurgeModel struct {
+	Before          time.Time           `json:"before"`
+	Vehicles        int                 `json:"vehicles"`
+	Fillups         int64               `json:"fillups"`
+	Expenses        int64               `json:"expenses"`
+	RemovedVehicles []VehiclePurgeModel `json:"removedVehicles"`
+}
+
+type PurgeVehicleRequest struct {
+	Archive bool `form:"archive" json:"archive"`
+}
+
+type DeleteVehicleQuery struct {
+	Permanent bool `form:"permanent" json:"permanent"`
+	Archive   bool `form:"archive" json:"archive"`
+}
+
+// VehiclePurgeModel reports what was permanently removed along with a vehicle.
+// Archive names the archive written beforehand, if one was asked for.
+type VehiclePurgeModel struct {
+	VehicleID    uuid.UUID       `json:"vehicleId"`
+	Nickname     string          `json:"nickname"`
+	Removed      db.VehiclePurge `json:"removed"`
+	FilesRemoved int             `json:"filesRemoved"`
+	FileErrors   []string        `json:"fileErrors"`
+	Archive      string          `json:"archive"`
+}
+
+type VehicleArchiveQuery struct {
+	Name string `uri:"name" binding:"required"`
+}
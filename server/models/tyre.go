@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"hammond/db"
+)
+
+type CreateTyreSetRequest struct {
+	Name         string         `form:"name" json:"name" binding:"required"`
+	Brand        string         `form:"brand" json:"brand"`
+	Model        string         `form:"model" json:"model"`
+	Size         string         `form:"size" json:"size"`
+	Season       *db.TyreSeason `form:"season" json:"season" binding:"required"`
+	PurchaseDate *time.Time     `form:"purchaseDate" json:"purchaseDate" time_format:"2006-01-02"`
+	PurchaseCost float32        `form:"purchaseCost" json:"purchaseCost"`
+	Comments     string         `form:"comments" json:"comments"`
+	IsRetired    bool           `form:"isRetired" json:"isRetired"`
+}
+
+type UpdateTyreSetRequest struct {
+	CreateTyreSetRequest
+}
+
+type TyreMountingRequest struct {
+	Date       time.Time `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	OdoReading int       `form:"odoReading" json:"odoReading" binding:"required"`
+}
+
+type CreateTyreTreadDepthRequest struct {
+	Date       time.Time `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	OdoReading int       `form:"odoReading" json:"odoReading"`
+	FrontLeft  float32   `form:"frontLeft" json:"frontLeft"`
+	FrontRight float32   `form:"frontRight" json:"frontRight"`
+	RearLeft   float32   `form:"rearLeft" json:"rearLeft"`
+	RearRight  float32   `form:"rearRight" json:"rearRight"`
+}
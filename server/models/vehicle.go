@@ -28,7 +28,9 @@ type CreateVehicleRequest struct {
 	EngineSize        float32      `form:"engineSize" json:"engineSize"`
 	FuelUnit          *db.FuelUnit `form:"fuelUnit" json:"fuelUnit" binding:"required"`
 
-	FuelType *db.FuelType `form:"fuelType" json:"fuelType" binding:"required"`
+	FuelType     *db.FuelType     `form:"fuelType" json:"fuelType" binding:"required"`
+	DistanceUnit *db.DistanceUnit `form:"distanceUnit" json:"distanceUnit"`
+	CustomFields db.CustomFields  `form:"-" json:"customFields"`
 }
 
 type UpdateVehicleRequest struct {
@@ -44,19 +46,28 @@ type UserVehicleSimpleModel struct {
 }
 
 type CreateFillupRequest struct {
-	VehicleID       uuid.UUID    `form:"vehicleId" gorm:"type:uuid" json:"vehicleId" binding:"required"`
-	FuelUnit        *db.FuelUnit `form:"fuelUnit" json:"fuelUnit" binding:"required"`
-	FuelQuantity    float32      `form:"fuelQuantity" json:"fuelQuantity" binding:"required"`
-	PerUnitPrice    float32      `form:"perUnitPrice" json:"perUnitPrice" binding:"required"`
-	TotalAmount     float32      `form:"totalAmount" json:"totalAmount" binding:"required"`
-	OdoReading      int          `form:"odoReading" json:"odoReading" binding:"required"`
-	IsTankFull      *bool        `form:"isTankFull" json:"isTankFull" binding:"required"`
-	HasMissedFillup *bool        `form:"hasMissedFillup" json:"HasMissedFillup"`
-	Comments        string       `form:"comments" json:"comments" `
-	FillingStation  string       `form:"fillingStation" json:"fillingStation"`
-	UserID          uuid.UUID    `form:"userId" gorm:"type:uuid" json:"userId" binding:"required"`
-	Date            time.Time    `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
-	FuelSubType     string       `form:"fuelSubType" json:"fuelSubType"`
+	VehicleID        uuid.UUID       `form:"vehicleId" gorm:"type:uuid" json:"vehicleId" binding:"required"`
+	FuelUnit         *db.FuelUnit    `form:"fuelUnit" json:"fuelUnit" binding:"required"`
+	FuelQuantity     float32         `form:"fuelQuantity" json:"fuelQuantity" binding:"required"`
+	PerUnitPrice     float32         `form:"perUnitPrice" json:"perUnitPrice"`
+	TotalAmount      float32         `form:"totalAmount" json:"totalAmount"`
+	OdoReading       int             `form:"odoReading" json:"odoReading" binding:"required"`
+	IsTankFull       *bool           `form:"isTankFull" json:"isTankFull" binding:"required"`
+	HasMissedFillup  *bool           `form:"hasMissedFillup" json:"HasMissedFillup"`
+	Comments         string          `form:"comments" json:"comments" `
+	FillingStation   string          `form:"fillingStation" json:"fillingStation"`
+	FillingStationID *uuid.UUID      `form:"fillingStationId" json:"fillingStationId"`
+	UserID           uuid.UUID       `form:"userId" gorm:"type:uuid" json:"userId" binding:"required"`
+	Date             time.Time       `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	FuelSubType      string          `form:"fuelSubType" json:"fuelSubType"`
+	IsHomeCharging   *bool           `form:"isHomeCharging" json:"isHomeCharging"`
+	ChargingStart    *time.Time      `form:"chargingStart" json:"chargingStart"`
+	ChargingEnd      *time.Time      `form:"chargingEnd" json:"chargingEnd"`
+	Latitude         *float64        `form:"latitude" json:"latitude"`
+	Longitude        *float64        `form:"longitude" json:"longitude"`
+	Address          string          `form:"address" json:"address"`
+	CustomFields     db.CustomFields `form:"-" json:"customFields"`
+	Tags             []string        `form:"tags" json:"tags"`
 }
 
 type UpdateFillupRequest struct {
@@ -73,16 +84,56 @@ type CreateExpenseRequest struct {
 	Amount     float32 `form:"amount" json:"amount"`
 	OdoReading int     `form:"odoReading" json:"odoReading"`
 
-	Comments    string    `form:"comments" json:"comments" `
-	ExpenseType string    `form:"expenseType" json:"expenseType"`
-	UserID      uuid.UUID `form:"userId" gorm:"type:uuid" json:"userId" binding:"required"`
-	Date        time.Time `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	Comments          string                 `form:"comments" json:"comments" `
+	ExpenseType       string                 `form:"expenseType" json:"expenseType"`
+	ExpenseCategoryID *uuid.UUID             `form:"expenseCategoryId" json:"expenseCategoryId"`
+	UserID            uuid.UUID              `form:"userId" gorm:"type:uuid" json:"userId" binding:"required"`
+	Date              time.Time              `form:"date" json:"date" binding:"required" time_format:"2006-01-02"`
+	Vendor            string                 `form:"vendor" json:"vendor"`
+	LineItems         []ExpenseLineItemModel `form:"lineItems" json:"lineItems" binding:"dive"`
+	Latitude          *float64               `form:"latitude" json:"latitude"`
+	Longitude         *float64               `form:"longitude" json:"longitude"`
+	Address           string                 `form:"address" json:"address"`
+	CustomFields      db.CustomFields        `form:"-" json:"customFields"`
+	Tags              []string               `form:"tags" json:"tags"`
+}
+
+type ExpenseLineItemModel struct {
+	LineType    *db.ExpenseLineType `json:"lineType" binding:"required"`
+	Description string              `json:"description"`
+	PartNumber  string              `json:"partNumber"`
+	Quantity    float32             `json:"quantity"`
+	UnitPrice   float32             `json:"unitPrice"`
+}
+
+type PartSearchQuery struct {
+	PartNumber string `json:"partNumber" query:"partNumber" form:"partNumber"`
+	Query      string `json:"query" query:"query" form:"query"`
+}
+
+type PartHistoryModel struct {
+	ExpenseID  uuid.UUID          `json:"expenseId"`
+	Date       time.Time          `json:"date"`
+	OdoReading int                `json:"odoReading"`
+	Vendor     string             `json:"vendor"`
+	Currency   string             `json:"currency"`
+	LineItem   db.ExpenseLineItem `json:"lineItem"`
 }
 
 type CreateVehicleAttachmentModel struct {
 	Title string `form:"title" json:"title" binding:"required"`
 }
 
+type CreateEntryAttachmentModel struct {
+	Title string `form:"title" json:"title"`
+}
+
+type EntryAttachmentQuery struct {
+	ID           string `binding:"required" uri:"id" json:"id" form:"id"`
+	SubID        string `binding:"required" uri:"subId" json:"subId" form:"subId"`
+	AttachmentID string `binding:"required" uri:"attachmentId" json:"attachmentId" form:"attachmentId"`
+}
+
 type VehicleStatsModel struct {
 	CountFillups        int     `json:"countFillups"`
 	CountExpenses       int     `json:"countExpenses"`
@@ -152,6 +203,31 @@ func (m *VehicleStatsModel) SetStats(fillups *[]db.Fillup, expenses *[]db.Expens
 }
 
 type UserStatsQueryModel struct {
-	Start time.Time `json:"start" query:"start" form:"start"`
-	End   time.Time `json:"end" query:"end" form:"end"`
+	Start           time.Time `json:"start" query:"start" form:"start"`
+	End             time.Time `json:"end" query:"end" form:"end"`
+	IncludeInactive bool      `json:"includeInactive" query:"includeInactive" form:"includeInactive"`
+	Tags            []string  `json:"tags" query:"tags" form:"tags"`
+}
+
+// TagFilterQuery narrows a listing down to the entries carrying every one of
+// the tags.
+type TagFilterQuery struct {
+	Tags []string `json:"tags" query:"tags" form:"tags"`
+}
+
+// TagStatsModel holds the stats of the entries carrying a tag. Entries without
+// any tag are reported under an empty tag.
+type TagStatsModel struct {
+	Tag   string              `json:"tag"`
+	Stats []VehicleStatsModel `json:"stats"`
+}
+
+type VehicleListQuery struct {
+	IncludeInactive bool `json:"includeInactive" query:"includeInactive" form:"includeInactive"`
+}
+
+type VehicleStatusRequest struct {
+	Status   *db.VehicleStatus `form:"status" json:"status" binding:"required"`
+	Date     *time.Time        `form:"date" json:"date" time_format:"2006-01-02"`
+	Comments string            `form:"comments" json:"comments"`
 }
@@ -11,6 +11,8 @@ import (
 	"github.com/google/uuid"
 )
 
+const processAlertsJob = "ProcessDueAlerts"
+
 func CreateAlert(model models.CreateAlertModel, vehicleId, userId uuid.UUID) (*db.VehicleAlert, error) {
 	alert := db.VehicleAlert{
 		VehicleID:       vehicleId,
@@ -79,8 +81,13 @@ func CreateAlertInstance(alertId uuid.UUID) error {
 			VehicleAlertID: alertId,
 		}
 
+		// Occurances are due at a true distance, see GetLatestOdoReadingForVehicle.
 		if alert.AlertType == db.DISTANCE || alert.AlertType == db.BOTH {
-			model.OdoReading = alert.StartOdoReading + alert.OdoFrequency
+			odo, err := getOdometer(alert.VehicleID)
+			if err != nil {
+				return err
+			}
+			model.OdoReading = odo.trueDistance(alert.StartOdoReading, alert.StartDate) + alert.OdoFrequency
 			if useOccurance {
 				model.OdoReading = lastOccurance.OdoReading + alert.OdoFrequency
 			}
@@ -155,6 +162,10 @@ func FindAlertOccurancesToProcess(today time.Time) ([]db.AlertOccurance, error)
 		if !alert.IsActive {
 			continue
 		}
+		// the vehicle is not loaded when it is in the trash
+		if occurance.Vehicle.ID == uuid.Nil {
+			continue
+		}
 		if alert.AlertType == db.DISTANCE || alert.AlertType == db.BOTH {
 			odoReading, err := GetLatestOdoReadingForVehicle(occurance.VehicleID)
 			if err != nil {
@@ -176,6 +187,42 @@ func FindAlertOccurancesToProcess(today time.Time) ([]db.AlertOccurance, error)
 	return toReturn, nil
 }
 
+// ProcessDueAlerts turns the alert occurances that have fallen due into
+// notifications. Recurring alerts get their next occurance at the same time.
+func ProcessDueAlerts() {
+	if !db.GetLock(processAlertsJob).Date.IsZero() {
+		return
+	}
+	db.Lock(processAlertsJob, 10)
+	defer db.Unlock(processAlertsJob)
+
+	today := time.Now()
+	occurances, err := FindAlertOccurancesToProcess(today)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	for _, occurance := range occurances {
+		if err := ProcessAlertOccurance(occurance, today); err != nil {
+			fmt.Println("error while processing alert occurance", err)
+			continue
+		}
+		if occurance.VehicleAlert.AlertFrequency == db.RECURRING {
+			if err := CreateAlertInstance(occurance.VehicleAlertID); err != nil {
+				fmt.Println("error while creating alert instance", err)
+			}
+		}
+	}
+}
+
+func GetNotificationsByUserId(userId uuid.UUID) (*[]db.Notification, error) {
+	return db.GetNotificationsByUserId(userId)
+}
+
+func MarkNotificationAsRead(notificationId, userId uuid.UUID) error {
+	return db.MarkNotificationAsRead(notificationId, userId, time.Now())
+}
+
 // func MarkAlertOccuranceAsCompleted() {
 
 // }
@@ -0,0 +1,915 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExportArchive writes a portable archive to a temporary file and returns its
+// path along with the name to download it as. The caller removes the file.
+// Archives of a user hold the vehicles the user has access to, those of the
+// instance every vehicle and user.
+func ExportArchive(scope db.ArchiveScope, userId uuid.UUID) (string, string, error) {
+	file, err := os.CreateTemp("", "hammond-archive-*.tar.gz")
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	if err := WriteArchive(file, scope, userId); err != nil {
+		_ = os.Remove(file.Name())
+		return "", "", err
+	}
+	name := "hammond_" + string(scope) + "_" + time.Now().Format("2006.01.02_150405") + ".tar.gz"
+	return file.Name(), name, nil
+}
+
+// WriteArchive writes a portable archive, see db.ArchiveManifest for what it
+// holds.
+func WriteArchive(writer io.Writer, scope db.ArchiveScope, userId uuid.UUID) error {
+	manifest, attachments, err := getArchiveManifest(scope, userId)
+	if err != nil {
+		return err
+	}
+	return writeArchive(writer, manifest, attachments)
+}
+
+// WriteVehicleArchive writes the portable archive of a single vehicle, which
+// may be in the trash, as the user archive of userId. It is imported like any
+// other user archive.
+func WriteVehicleArchive(writer io.Writer, vehicleId, userId uuid.UUID) error {
+	vehicle, err := db.GetTrashedArchiveVehicle(vehicleId)
+	if err != nil {
+		return err
+	}
+	if err := setArchiveVehicleCustomFields(vehicle); err != nil {
+		return err
+	}
+	manifest, attachments, err := buildArchiveManifest(db.USER_ARCHIVE, userId, []db.ArchiveVehicle{*vehicle})
+	if err != nil {
+		return err
+	}
+	return writeArchive(writer, manifest, attachments)
+}
+
+func writeArchive(writer io.Writer, manifest *db.ArchiveManifest, attachments []db.Attachment) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	// the manifest comes first so that an importer knows what it is reading
+	// before any file
+	header := &tar.Header{
+		Name:    db.ArchiveManifestName,
+		Size:    int64(len(data)),
+		Mode:    0644,
+		ModTime: manifest.CreatedAt,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tarWriter.Write(data); err != nil {
+		return err
+	}
+	for i, attachment := range attachments {
+		if err := addAttachmentToTarWriter(&attachment, manifest.Attachments[i].File, tarWriter); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// archiveIds gathers the ids records of an archive refer to.
+type archiveIds map[uuid.UUID]bool
+
+func (ids archiveIds) add(id *uuid.UUID) {
+	if id != nil && *id != uuid.Nil {
+		ids[*id] = true
+	}
+}
+
+func (ids archiveIds) list() []uuid.UUID {
+	list := make([]uuid.UUID, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	return list
+}
+
+func getArchiveManifest(scope db.ArchiveScope, userId uuid.UUID) (*db.ArchiveManifest, []db.Attachment, error) {
+	var vehicleIds []uuid.UUID
+	var err error
+	switch scope {
+	case db.USER_ARCHIVE:
+		vehicleIds, err = db.GetArchiveVehicleIds(&userId)
+	case db.INSTANCE_ARCHIVE:
+		vehicleIds, err = db.GetArchiveVehicleIds(nil)
+	default:
+		return nil, nil, fmt.Errorf("unknown archive scope %s", scope)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	vehicles := make([]db.ArchiveVehicle, 0, len(vehicleIds))
+	for _, vehicleId := range vehicleIds {
+		vehicle, err := db.GetArchiveVehicle(vehicleId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := setArchiveVehicleCustomFields(vehicle); err != nil {
+			return nil, nil, err
+		}
+		vehicles = append(vehicles, *vehicle)
+	}
+	return buildArchiveManifest(scope, userId, vehicles)
+}
+
+// buildArchiveManifest gathers what the vehicles of an archive refer to.
+func buildArchiveManifest(scope db.ArchiveScope, userId uuid.UUID, vehicles []db.ArchiveVehicle) (*db.ArchiveManifest, []db.Attachment, error) {
+	manifest := db.ArchiveManifest{
+		Format:             db.ArchiveFormat,
+		Version:            db.ArchiveVersion,
+		Scope:              scope,
+		CreatedAt:          time.Now(),
+		ExportedBy:         userId,
+		Users:              []db.ArchiveUser{},
+		ExpenseCategories:  []db.ExpenseCategory{},
+		FillingStations:    []db.FillingStation{},
+		ElectricityTariffs: []db.ElectricityTariff{},
+		Attachments:        []db.ArchiveAttachment{},
+		Vehicles:           []db.ArchiveVehicle{},
+	}
+
+	userIds, attachmentIds, categoryIds, stationIds, tariffIds := archiveIds{}, archiveIds{}, archiveIds{}, archiveIds{}, archiveIds{}
+	userIds.add(&userId)
+	for _, vehicle := range vehicles {
+		for _, share := range vehicle.Shares {
+			userIds.add(&share.UserID)
+		}
+		for _, fillup := range vehicle.Fillups {
+			userIds.add(&fillup.UserID)
+			stationIds.add(fillup.FillingStationID)
+			tariffIds.add(fillup.ElectricityTariffID)
+		}
+		for _, expense := range vehicle.Expenses {
+			userIds.add(&expense.UserID)
+			categoryIds.add(expense.ExpenseCategoryID)
+		}
+		for _, recurringExpense := range vehicle.RecurringExpenses {
+			userIds.add(&recurringExpense.UserID)
+			categoryIds.add(recurringExpense.ExpenseCategoryID)
+		}
+		for _, alert := range vehicle.Alerts {
+			userIds.add(&alert.UserID)
+		}
+		for _, document := range vehicle.Documents {
+			userIds.add(&document.UserID)
+			attachmentIds.add(document.AttachmentID)
+		}
+		for _, link := range vehicle.Attachments {
+			attachmentIds.add(&link.AttachmentID)
+		}
+		for _, link := range vehicle.FillupAttachments {
+			attachmentIds.add(&link.AttachmentID)
+		}
+		for _, link := range vehicle.ExpenseAttachments {
+			attachmentIds.add(&link.AttachmentID)
+		}
+		manifest.Vehicles = append(manifest.Vehicles, vehicle)
+	}
+
+	attachments, err := db.GetAttachmentsByIds(attachmentIds.list())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, attachment := range *attachments {
+		userIds.add(&attachment.UserID)
+		manifest.Attachments = append(manifest.Attachments, db.ArchiveAttachment{
+			ID:           attachment.ID,
+			CreatedAt:    attachment.CreatedAt,
+			OriginalName: attachment.OriginalName,
+			ContentType:  attachment.ContentType,
+			Size:         attachment.Size,
+			Hash:         attachment.Hash,
+			UserID:       attachment.UserID,
+			File:         path.Join("attachments", attachment.ID.String()+filepath.Ext(attachment.Path)),
+		})
+	}
+
+	var users *[]db.User
+	var categories *[]db.ExpenseCategory
+	var stations *[]db.FillingStation
+	if scope == db.INSTANCE_ARCHIVE {
+		users, err = db.GetAllUsers()
+		if err == nil {
+			categories, err = db.GetAllExpenseCategories()
+		}
+		if err == nil {
+			stations, err = db.GetAllFillingStations()
+		}
+	} else {
+		users, err = db.GetUsersByIds(userIds.list())
+		if err == nil {
+			categories, err = db.GetExpenseCategoriesByIds(categoryIds.list())
+		}
+		if err == nil {
+			stations, err = db.GetFillingStationsByIds(stationIds.list())
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// tariffs belong to users, those of the exported users come along even
+	// when no fillup uses them yet
+	tariffUserIds := []uuid.UUID{userId}
+	for _, user := range *users {
+		archiveUser := db.ArchiveUser{
+			ID:           user.ID,
+			CreatedAt:    user.CreatedAt,
+			Email:        user.Email,
+			Name:         user.Name,
+			Role:         user.Role,
+			Currency:     user.Currency,
+			DistanceUnit: user.DistanceUnit,
+			DateFormat:   user.DateFormat,
+			IsDisabled:   user.IsDisabled,
+		}
+		if scope == db.INSTANCE_ARCHIVE {
+			archiveUser.PasswordHash = user.Password
+			tariffUserIds = append(tariffUserIds, user.ID)
+		}
+		manifest.Users = append(manifest.Users, archiveUser)
+	}
+	tariffs, err := db.GetElectricityTariffsForArchive(tariffUserIds, tariffIds.list())
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest.ExpenseCategories = *categories
+	manifest.FillingStations = *stations
+	manifest.ElectricityTariffs = *tariffs
+	return &manifest, *attachments, nil
+}
+
+func setArchiveVehicleCustomFields(vehicle *db.ArchiveVehicle) error {
+	vehicles := []db.Vehicle{vehicle.Vehicle}
+	if err := SetVehiclesCustomFields(vehicles); err != nil {
+		return err
+	}
+	vehicle.Vehicle = vehicles[0]
+	if err := SetFillupsCustomFields(vehicle.Fillups); err != nil {
+		return err
+	}
+	return SetExpensesCustomFields(vehicle.Expenses)
+}
+
+// importedFile is a file of an archive which was stored.
+type importedFile struct {
+	storage     string
+	path        string
+	hash        string
+	size        int64
+	contentType string
+	stored      bool
+}
+
+// ImportArchive reads a portable archive into this instance. Everything gets
+// new ids, so an archive can go into another instance, another database or
+// the same instance again, where it makes copies. The archive of a user is
+// imported into the account of userId, who owns the vehicles and every record
+// in it, as an archive can't be trusted to tell who else things belong to.
+// The archive of an instance brings along its users, matched by their email.
+// Files go through the same checks as uploads.
+func ImportArchive(reader io.Reader, scope db.ArchiveScope, userId uuid.UUID) (*models.ArchiveImportModel, error) {
+	report := models.ArchiveImportModel{Warnings: []string{}}
+	storage, err := CurrentStorage()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]importedFile)
+	removeStoredFiles := func() {
+		for _, file := range files {
+			if file.stored {
+				_, _ = deleteAttachmentFile(&db.Attachment{Storage: file.storage, Path: file.path})
+			}
+		}
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, errors.New("this is not a Hammond archive")
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	var manifest *db.ArchiveManifest
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			removeStoredFiles()
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Name == db.ArchiveManifestName {
+			manifest = &db.ArchiveManifest{}
+			if err := json.NewDecoder(tarReader).Decode(manifest); err != nil {
+				removeStoredFiles()
+				return nil, fmt.Errorf("the manifest of the archive cannot be read: %s", err)
+			}
+			if err := checkArchiveManifest(manifest, scope); err != nil {
+				removeStoredFiles()
+				return nil, err
+			}
+			continue
+		}
+		if !strings.HasPrefix(header.Name, "attachments/") {
+			continue
+		}
+		file, err := importArchiveFile(tarReader, header, storage)
+		if err != nil {
+			report.Warnings = append(report.Warnings, err.Error())
+			continue
+		}
+		files[header.Name] = *file
+	}
+	if manifest == nil {
+		removeStoredFiles()
+		return nil, errors.New("this is not a Hammond archive, it has no manifest")
+	}
+
+	importer, err := newArchiveImporter(manifest, files, userId, &report)
+	if err != nil {
+		removeStoredFiles()
+		return nil, err
+	}
+	tx := db.DB.Begin()
+	importer.tx = tx
+	if err := importer.write(); err != nil {
+		tx.Rollback()
+		removeStoredFiles()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		removeStoredFiles()
+		return nil, err
+	}
+	return &report, nil
+}
+
+func checkArchiveManifest(manifest *db.ArchiveManifest, scope db.ArchiveScope) error {
+	if manifest.Format != db.ArchiveFormat {
+		return errors.New("this is not a Hammond archive")
+	}
+	if manifest.Version > db.ArchiveVersion {
+		return fmt.Errorf("this archive is of version %d, which is newer than this version of Hammond can read", manifest.Version)
+	}
+	if manifest.Scope != scope {
+		return fmt.Errorf("this archive is of the scope %s, it cannot be imported as one of the scope %s", manifest.Scope, scope)
+	}
+	return nil
+}
+
+func importArchiveFile(reader io.Reader, header *tar.Header, storage Storage) (*importedFile, error) {
+	upload, err := prepareUpload(reader, path.Base(header.Name), header.Size)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+	filePath, stored, err := upload.store(storage)
+	if err != nil {
+		return nil, err
+	}
+	return &importedFile{
+		storage:     storage.Name(),
+		path:        filePath,
+		hash:        upload.hash,
+		size:        upload.size,
+		contentType: upload.mime.String(),
+		stored:      stored,
+	}, nil
+}
+
+// archiveImporter writes the records of an archive, keeping track of the ids
+// they had in the archive and the ones they got.
+type archiveImporter struct {
+	tx       *gorm.DB
+	manifest *db.ArchiveManifest
+	files    map[string]importedFile
+	userId   uuid.UUID
+	report   *models.ArchiveImportModel
+
+	existingUsers      map[uuid.UUID]uuid.UUID
+	existingCategories map[uuid.UUID]uuid.UUID
+	existingStations   map[uuid.UUID]uuid.UUID
+	customFields       map[db.CustomFieldEntity]map[string]db.CustomFieldDefinition
+
+	users       map[uuid.UUID]uuid.UUID
+	categories  map[uuid.UUID]uuid.UUID
+	stations    map[uuid.UUID]uuid.UUID
+	tariffs     map[uuid.UUID]uuid.UUID
+	attachments map[uuid.UUID]uuid.UUID
+}
+
+// newArchiveImporter looks up what already exists before anything is written,
+// users of an instance by their email, categories and stations by their name.
+func newArchiveImporter(manifest *db.ArchiveManifest, files map[string]importedFile, userId uuid.UUID, report *models.ArchiveImportModel) (*archiveImporter, error) {
+	importer := archiveImporter{
+		manifest:           manifest,
+		files:              files,
+		userId:             userId,
+		report:             report,
+		existingUsers:      make(map[uuid.UUID]uuid.UUID),
+		existingCategories: make(map[uuid.UUID]uuid.UUID),
+		existingStations:   make(map[uuid.UUID]uuid.UUID),
+		customFields:       make(map[db.CustomFieldEntity]map[string]db.CustomFieldDefinition),
+		users:              make(map[uuid.UUID]uuid.UUID),
+		categories:         make(map[uuid.UUID]uuid.UUID),
+		stations:           make(map[uuid.UUID]uuid.UUID),
+		tariffs:            make(map[uuid.UUID]uuid.UUID),
+		attachments:        make(map[uuid.UUID]uuid.UUID),
+	}
+	for _, user := range manifest.Users {
+		if manifest.Scope != db.INSTANCE_ARCHIVE {
+			break
+		}
+		existing, err := db.FindOneUser(&db.User{Email: user.Email})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		importer.existingUsers[user.ID] = existing.ID
+	}
+	for _, category := range manifest.ExpenseCategories {
+		existing, err := db.FindExpenseCategoryByName(category.Name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		importer.existingCategories[category.ID] = existing.ID
+	}
+	for _, station := range manifest.FillingStations {
+		existing, err := db.FindFillingStationByName(station.Name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		importer.existingStations[station.ID] = existing.ID
+	}
+	for _, entityType := range []db.CustomFieldEntity{db.VEHICLE_ENTITY, db.FILLUP_ENTITY, db.EXPENSE_ENTITY} {
+		definitions, err := getCustomFieldDefinitionsByKey(entityType)
+		if err != nil {
+			return nil, err
+		}
+		importer.customFields[entityType] = definitions
+	}
+	return &importer, nil
+}
+
+func (i *archiveImporter) write() error {
+	if err := i.writeUsers(); err != nil {
+		return err
+	}
+	if err := i.writeExpenseCategories(); err != nil {
+		return err
+	}
+	if err := i.writeFillingStations(); err != nil {
+		return err
+	}
+	if err := i.writeElectricityTariffs(); err != nil {
+		return err
+	}
+	if err := i.writeAttachments(); err != nil {
+		return err
+	}
+	for _, vehicle := range i.manifest.Vehicles {
+		if err := i.writeVehicle(vehicle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeUsers creates the users of an instance which don't exist yet. Nothing
+// is mapped for the archive of a user, so that all of it goes to the
+// importing user.
+func (i *archiveImporter) writeUsers() error {
+	if i.manifest.Scope != db.INSTANCE_ARCHIVE {
+		return nil
+	}
+	for _, archiveUser := range i.manifest.Users {
+		if id, ok := i.existingUsers[archiveUser.ID]; ok {
+			i.users[archiveUser.ID] = id
+			continue
+		}
+		user := db.User{
+			Email:        archiveUser.Email,
+			Password:     archiveUser.PasswordHash,
+			Currency:     archiveUser.Currency,
+			DistanceUnit: archiveUser.DistanceUnit,
+			DateFormat:   archiveUser.DateFormat,
+			Role:         archiveUser.Role,
+			Name:         archiveUser.Name,
+			IsDisabled:   archiveUser.IsDisabled,
+		}
+		user.CreatedAt = archiveUser.CreatedAt
+		if err := i.tx.Omit(clause.Associations).Create(&user).Error; err != nil {
+			return err
+		}
+		i.users[archiveUser.ID] = user.ID
+		i.report.Users++
+	}
+	return nil
+}
+
+// user maps a user of the archive, falling back to the importing user.
+func (i *archiveImporter) user(archiveId uuid.UUID) uuid.UUID {
+	if id, ok := i.users[archiveId]; ok {
+		return id
+	}
+	return i.userId
+}
+
+// writeExpenseCategories creates the categories which don't exist yet, parents
+// before their children. Only the archive of an instance brings categories
+// along, those of a user are matched to existing ones by name or left out,
+// leaving their expenses uncategorised.
+func (i *archiveImporter) writeExpenseCategories() error {
+	for archiveId, id := range i.existingCategories {
+		i.categories[archiveId] = id
+	}
+	if i.manifest.Scope != db.INSTANCE_ARCHIVE {
+		return nil
+	}
+	for {
+		var ready, waiting []db.ExpenseCategory
+		for _, category := range i.manifest.ExpenseCategories {
+			if _, ok := i.categories[category.ID]; ok {
+				continue
+			}
+			if category.ParentID == nil || i.categories[*category.ParentID] != uuid.Nil {
+				ready = append(ready, category)
+			} else {
+				waiting = append(waiting, category)
+			}
+		}
+		// parents which are not in the archive leave their children at the top
+		if len(ready) == 0 {
+			ready, waiting = waiting, nil
+		}
+		if len(ready) == 0 {
+			return nil
+		}
+		for _, archived := range ready {
+			category := db.ExpenseCategory{
+				Name:     archived.Name,
+				ParentID: mapId(i.categories, archived.ParentID),
+				Icon:     archived.Icon,
+				Colour:   archived.Colour,
+			}
+			for _, alias := range archived.Aliases {
+				category.Aliases = append(category.Aliases, db.ExpenseCategoryAlias{Alias: alias.Alias})
+			}
+			if err := i.tx.Create(&category).Error; err != nil {
+				return err
+			}
+			i.categories[archived.ID] = category.ID
+		}
+	}
+}
+
+// writeFillingStations creates the stations which don't exist yet. As with
+// categories, only the archive of an instance brings stations along; the
+// fillups of a user archive keep the name of theirs as free text.
+func (i *archiveImporter) writeFillingStations() error {
+	for _, archived := range i.manifest.FillingStations {
+		if id, ok := i.existingStations[archived.ID]; ok {
+			i.stations[archived.ID] = id
+			continue
+		}
+		if i.manifest.Scope != db.INSTANCE_ARCHIVE {
+			continue
+		}
+		station := db.FillingStation{
+			Name:      archived.Name,
+			Brand:     archived.Brand,
+			Latitude:  archived.Latitude,
+			Longitude: archived.Longitude,
+			Address:   archived.Address,
+			Notes:     archived.Notes,
+		}
+		for _, alias := range archived.Aliases {
+			station.Aliases = append(station.Aliases, db.FillingStationAlias{Alias: alias.Alias})
+		}
+		if err := i.tx.Create(&station).Error; err != nil {
+			return err
+		}
+		i.stations[archived.ID] = station.ID
+	}
+	return nil
+}
+
+func (i *archiveImporter) writeElectricityTariffs() error {
+	for _, archived := range i.manifest.ElectricityTariffs {
+		tariff := db.ElectricityTariff{
+			UserID:        i.user(archived.UserID),
+			Name:          archived.Name,
+			Currency:      archived.Currency,
+			BasePrice:     archived.BasePrice,
+			EffectiveFrom: archived.EffectiveFrom,
+			EffectiveTo:   archived.EffectiveTo,
+		}
+		for _, band := range archived.Bands {
+			tariff.Bands = append(tariff.Bands, db.ElectricityTariffBand{
+				Name:         band.Name,
+				StartTime:    band.StartTime,
+				EndTime:      band.EndTime,
+				PerUnitPrice: band.PerUnitPrice,
+			})
+		}
+		if err := i.tx.Omit("User").Create(&tariff).Error; err != nil {
+			return err
+		}
+		i.tariffs[archived.ID] = tariff.ID
+	}
+	return nil
+}
+
+// writeAttachments records the files of the archive. Attachments whose file
+// is not in the archive, or didn't pass the checks of uploads, are left out
+// along with the links to them.
+func (i *archiveImporter) writeAttachments() error {
+	for _, archived := range i.manifest.Attachments {
+		file, ok := i.files[archived.File]
+		if !ok {
+			i.report.Warnings = append(i.report.Warnings, fmt.Sprintf("the file of %s is not in the archive", archived.OriginalName))
+			continue
+		}
+		attachment := db.Attachment{
+			Storage:      file.storage,
+			Path:         file.path,
+			Hash:         file.hash,
+			OriginalName: archived.OriginalName,
+			Size:         file.size,
+			ContentType:  file.contentType,
+			UserID:       i.user(archived.UserID),
+		}
+		attachment.CreatedAt = archived.CreatedAt
+		if err := i.tx.Omit(clause.Associations).Create(&attachment).Error; err != nil {
+			return err
+		}
+		i.attachments[archived.ID] = attachment.ID
+		i.report.Attachments++
+	}
+	return nil
+}
+
+func (i *archiveImporter) writeVehicle(archived db.ArchiveVehicle) error {
+	vehicle := archived.Vehicle
+	vehicle.Users, vehicle.Fillups, vehicle.Expenses, vehicle.Attachments = nil, nil, nil, nil
+	if err := i.tx.Omit(clause.Associations).Create(&vehicle).Error; err != nil {
+		return err
+	}
+	i.report.Vehicles++
+	if err := i.writeShares(vehicle.ID, archived.Shares); err != nil {
+		return err
+	}
+	if err := i.writeCustomFields(db.VEHICLE_ENTITY, vehicle.ID, archived.Vehicle.CustomFields); err != nil {
+		return err
+	}
+
+	recurringExpenses := make(map[uuid.UUID]uuid.UUID)
+	for _, recurringExpense := range archived.RecurringExpenses {
+		archiveId := recurringExpense.ID
+		recurringExpense.VehicleID = vehicle.ID
+		recurringExpense.UserID = i.user(recurringExpense.UserID)
+		recurringExpense.ExpenseCategoryID = mapId(i.categories, recurringExpense.ExpenseCategoryID)
+		if err := i.tx.Omit(clause.Associations).Create(&recurringExpense).Error; err != nil {
+			return err
+		}
+		recurringExpenses[archiveId] = recurringExpense.ID
+	}
+
+	fillups := make(map[uuid.UUID]uuid.UUID)
+	for _, fillup := range archived.Fillups {
+		archiveId := fillup.ID
+		fillup.VehicleID = vehicle.ID
+		fillup.UserID = i.user(fillup.UserID)
+		fillup.FillingStationID = mapId(i.stations, fillup.FillingStationID)
+		fillup.ElectricityTariffID = mapId(i.tariffs, fillup.ElectricityTariffID)
+		fillup.Vehicle, fillup.User, fillup.Attachments = db.Vehicle{}, db.User{}, nil
+		tags, err := db.FindOrCreateTags(i.tx, tagNames(fillup.Tags))
+		if err != nil {
+			return err
+		}
+		fillup.Tags = tags
+		// the tags already exist, only the links to them are created
+		if err := i.tx.Omit("Tags.*", "Vehicle", "User", "Attachments").Create(&fillup).Error; err != nil {
+			return err
+		}
+		if err := i.writeCustomFields(db.FILLUP_ENTITY, fillup.ID, fillup.CustomFields); err != nil {
+			return err
+		}
+		fillups[archiveId] = fillup.ID
+		i.report.Fillups++
+	}
+
+	expenses := make(map[uuid.UUID]uuid.UUID)
+	for _, expense := range archived.Expenses {
+		archiveId := expense.ID
+		expense.VehicleID = vehicle.ID
+		expense.UserID = i.user(expense.UserID)
+		expense.ExpenseCategoryID = mapId(i.categories, expense.ExpenseCategoryID)
+		expense.RecurringExpenseID = mapId(recurringExpenses, expense.RecurringExpenseID)
+		expense.Vehicle, expense.User, expense.Attachments = db.Vehicle{}, db.User{}, nil
+		tags, err := db.FindOrCreateTags(i.tx, tagNames(expense.Tags))
+		if err != nil {
+			return err
+		}
+		expense.Tags = tags
+		if err := i.tx.Omit("Tags.*", "Vehicle", "User", "Attachments").Create(&expense).Error; err != nil {
+			return err
+		}
+		if err := i.writeCustomFields(db.EXPENSE_ENTITY, expense.ID, expense.CustomFields); err != nil {
+			return err
+		}
+		expenses[archiveId] = expense.ID
+		i.report.Expenses++
+	}
+
+	for _, occurrence := range archived.RecurringExpenseOccurrences {
+		recurringExpenseId := mapId(recurringExpenses, &occurrence.RecurringExpenseID)
+		if recurringExpenseId == nil {
+			continue
+		}
+		occurrence.RecurringExpenseID = *recurringExpenseId
+		occurrence.ExpenseID = mapId(expenses, occurrence.ExpenseID)
+		if err := i.tx.Create(&occurrence).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, replacement := range archived.OdometerReplacements {
+		replacement.VehicleID = vehicle.ID
+		if err := i.tx.Omit(clause.Associations).Create(&replacement).Error; err != nil {
+			return err
+		}
+	}
+
+	alerts := make(map[uuid.UUID]uuid.UUID)
+	for _, alert := range archived.Alerts {
+		archiveId := alert.ID
+		alert.VehicleID = vehicle.ID
+		alert.UserID = i.user(alert.UserID)
+		if err := i.tx.Omit(clause.Associations).Create(&alert).Error; err != nil {
+			return err
+		}
+		alerts[archiveId] = alert.ID
+		i.report.Alerts++
+	}
+	for _, occurrence := range archived.AlertOccurrences {
+		alertId := mapId(alerts, &occurrence.VehicleAlertID)
+		if alertId == nil {
+			continue
+		}
+		occurrence.VehicleID = vehicle.ID
+		occurrence.VehicleAlertID = *alertId
+		occurrence.UserID = i.user(occurrence.UserID)
+		if err := i.tx.Omit(clause.Associations).Create(&occurrence).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, document := range archived.Documents {
+		document.VehicleID = vehicle.ID
+		document.UserID = i.user(document.UserID)
+		document.AttachmentID = mapId(i.attachments, document.AttachmentID)
+		document.VehicleAlertID = mapId(alerts, document.VehicleAlertID)
+		document.Attachment = nil
+		if err := i.tx.Omit(clause.Associations).Create(&document).Error; err != nil {
+			return err
+		}
+		i.report.Documents++
+	}
+
+	for _, tyreSet := range archived.TyreSets {
+		tyreSet.VehicleID = vehicle.ID
+		if err := i.tx.Omit("Vehicle").Create(&tyreSet).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, link := range archived.Attachments {
+		if attachmentId, ok := i.attachments[link.AttachmentID]; ok {
+			err := i.tx.Create(&db.VehicleAttachment{AttachmentID: attachmentId, VehicleID: vehicle.ID, Title: link.Title}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, link := range archived.FillupAttachments {
+		attachmentId, ok := i.attachments[link.AttachmentID]
+		fillupId, found := fillups[link.FillupID]
+		if ok && found {
+			err := i.tx.Create(&db.FillupAttachment{AttachmentID: attachmentId, FillupID: fillupId, Title: link.Title}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, link := range archived.ExpenseAttachments {
+		attachmentId, ok := i.attachments[link.AttachmentID]
+		expenseId, found := expenses[link.ExpenseID]
+		if ok && found {
+			err := i.tx.Create(&db.ExpenseAttachment{AttachmentID: attachmentId, ExpenseID: expenseId, Title: link.Title}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeShares gives the users of an instance their access back. Vehicles of
+// the archive of a user are owned by the importing user alone.
+func (i *archiveImporter) writeShares(vehicleId uuid.UUID, shares []db.ArchiveShare) error {
+	owners := make(map[uuid.UUID]bool)
+	if i.manifest.Scope != db.INSTANCE_ARCHIVE {
+		owners[i.userId] = true
+	} else {
+		for _, share := range shares {
+			owners[i.user(share.UserID)] = share.IsOwner
+		}
+	}
+	for userId, isOwner := range owners {
+		share := db.UserVehicle{UserID: userId, VehicleID: vehicleId, IsOwner: isOwner}
+		if err := i.tx.Omit(clause.Associations).Create(&share).Error; err != nil {
+			return err
+		}
+		i.report.Shares++
+	}
+	return nil
+}
+
+// writeCustomFields keeps the values of the fields defined here too. Values
+// which don't fit the definition here are dropped with a warning.
+func (i *archiveImporter) writeCustomFields(entityType db.CustomFieldEntity, entityId uuid.UUID, fields db.CustomFields) error {
+	var values []db.CustomFieldValue
+	for key, raw := range fields {
+		definition, ok := i.customFields[entityType][key]
+		if !ok || raw == nil {
+			continue
+		}
+		value, err := toCustomFieldValue(definition, raw)
+		if err != nil {
+			i.report.Warnings = append(i.report.Warnings, err.Error())
+			continue
+		}
+		value.EntityID = entityId
+		values = append(values, *value)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return i.tx.Create(&values).Error
+}
+
+func mapId(ids map[uuid.UUID]uuid.UUID, archiveId *uuid.UUID) *uuid.UUID {
+	if archiveId == nil {
+		return nil
+	}
+	id, ok := ids[*archiveId]
+	if !ok {
+		return nil
+	}
+	return &id
+}
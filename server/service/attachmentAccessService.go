@@ -0,0 +1,106 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+// SignedUrlLifetime is how long a signed link to an attachment works.
+const SignedUrlLifetime = 10 * time.Minute
+
+var ErrAttachmentForbidden = errors.New("you are not allowed to access this attachment")
+
+// CheckAttachmentAccess lets the user through to an attachment they uploaded,
+// one of their quick entries, or one linked to a vehicle they can see. Admins
+// can see everything.
+func CheckAttachmentAccess(userId uuid.UUID, attachment *db.Attachment) error {
+	if attachment.UserID == userId {
+		return nil
+	}
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.Role == db.ADMIN {
+		return nil
+	}
+	isQuickEntry, err := db.IsQuickEntryAttachmentOfUser(attachment.ID, userId)
+	if err != nil {
+		return err
+	}
+	if isQuickEntry {
+		return nil
+	}
+	vehicleIds, err := db.GetAttachmentVehicleIds(attachment.ID)
+	if err != nil {
+		return err
+	}
+	if len(vehicleIds) == 0 {
+		return ErrAttachmentForbidden
+	}
+	vehicles, err := GetUserVehicles(userId, true)
+	if err != nil {
+		return err
+	}
+	for _, vehicle := range *vehicles {
+		for _, vehicleId := range vehicleIds {
+			if vehicle.ID == vehicleId {
+				return nil
+			}
+		}
+	}
+	return ErrAttachmentForbidden
+}
+
+// CheckVehicleAccess lets admins and the users a vehicle is shared with
+// through to its attachments.
+func CheckVehicleAccess(userId, vehicleId uuid.UUID) error {
+	canAccess, err := canAccessVehicle(userId, vehicleId)
+	if err != nil {
+		return err
+	}
+	if !canAccess {
+		return errors.New("you are not allowed to access this vehicle")
+	}
+	return nil
+}
+
+// SignAttachmentUrl signs a link to the file of an attachment, or to its
+// thumbnail when a size is given, which works without logging in until it
+// expires.
+func SignAttachmentUrl(attachmentId uuid.UUID, size string, expiresAt time.Time) string {
+	mac := hmac.New(sha256.New, signingKey())
+	fmt.Fprintf(mac, "%s|%s|%d", attachmentId, size, expiresAt.Unix())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CheckAttachmentSignature tells whether a signed link is genuine and has not
+// expired yet.
+func CheckAttachmentSignature(attachmentId uuid.UUID, size string, expires int64, signature string) error {
+	expiresAt := time.Unix(expires, 0)
+	if time.Now().After(expiresAt) {
+		return errors.New("this link has expired")
+	}
+	expected := SignAttachmentUrl(attachmentId, size, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("this link is not valid")
+	}
+	return nil
+}
+
+// signingKey derives the key of the links from the JWT secret, so that a link
+// can never be mistaken for a token.
+func signingKey() []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("attachment links"))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const defaultAuditLogLimit = 100
+
+// auditIgnoredFields are left out of the diff of an entity: bookkeeping,
+// loaded relations and values derived from other fields.
+var auditIgnoredFields = map[string]bool{
+	"id":             true,
+	"createdAt":      true,
+	"updatedAt":      true,
+	"deletedAt":      true,
+	"user":           true,
+	"vehicle":        true,
+	"users":          true,
+	"vehicles":       true,
+	"fillups":        true,
+	"expenses":       true,
+	"attachments":    true,
+	"hasAttachments": true,
+	"isOwner":        true,
+	"trueOdoReading": true,
+}
+
+// auditIgnoredNestedFields are left out of the items nested in an entity, such
+// as its line items and tags, which are recreated on every save.
+var auditIgnoredNestedFields = map[string]bool{
+	"id":        true,
+	"createdAt": true,
+	"updatedAt": true,
+	"deletedAt": true,
+	"expenseId": true,
+}
+
+// recordAudit stores what an actor changed on an entity, in the transaction
+// which made the change. before is nil for a creation and after is nil for a
+// deletion. An update which changed nothing is not recorded.
+func recordAudit(tx *gorm.DB, actorId uuid.UUID, action db.AuditAction, entityType db.AuditEntity, entityId uuid.UUID, vehicleId *uuid.UUID, before, after interface{}) error {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	if action == db.UPDATE_ACTION && len(changes) == 0 {
+		return nil
+	}
+	log := db.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityId,
+		VehicleID:  vehicleId,
+		Changes:    changes,
+	}
+	if actorId != uuid.Nil {
+		log.ActorID = &actorId
+		if actor, err := db.LoadUser(tx, actorId); err == nil {
+			log.ActorName = actor.Name
+		}
+	}
+	return db.CreateAuditLog(tx, &log)
+}
+
+// auditDiff compares the JSON form of two versions of an entity field by
+// field.
+func auditDiff(before, after interface{}) ([]db.AuditChange, error) {
+	beforeFields, err := toAuditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := toAuditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for key := range beforeFields {
+		keys[key] = true
+	}
+	for key := range afterFields {
+		keys[key] = true
+	}
+	var fields []string
+	for key := range keys {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+
+	changes := []db.AuditChange{}
+	for _, field := range fields {
+		beforeValue, hadBefore := beforeFields[field]
+		afterValue, hasAfter := afterFields[field]
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		// creations and deletions only list the fields which hold something
+		if (!hadBefore && isEmptyAuditValue(afterValue)) || (!hasAfter && isEmptyAuditValue(beforeValue)) {
+			continue
+		}
+		changes = append(changes, db.AuditChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+	return changes, nil
+}
+
+func toAuditFields(entity interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if entity == nil {
+		return fields, nil
+	}
+	if value := reflect.ValueOf(entity); value.Kind() == reflect.Ptr && value.IsNil() {
+		return fields, nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		if auditIgnoredFields[key] || strings.HasSuffix(key, "Detail") {
+			delete(fields, key)
+			continue
+		}
+		fields[key] = stripNestedAuditFields(value)
+	}
+	return fields, nil
+}
+
+func stripNestedAuditFields(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if auditIgnoredNestedFields[key] || strings.HasSuffix(key, "Detail") {
+				delete(typed, key)
+				continue
+			}
+			typed[key] = stripNestedAuditFields(item)
+		}
+		return typed
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = stripNestedAuditFields(item)
+		}
+		return typed
+	}
+	return value
+}
+
+func isEmptyAuditValue(value interface{}) bool {
+	switch typed := value.(type) {
+	case nil:
+		return true
+	case string:
+		return typed == ""
+	case float64:
+		return typed == 0
+	case bool:
+		return !typed
+	case []interface{}:
+		return len(typed) == 0
+	case map[string]interface{}:
+		return len(typed) == 0
+	}
+	return false
+}
+
+// GetVehicleAuditLog lists the changes made to a vehicle and to everything
+// recorded against it.
+func GetVehicleAuditLog(userId, vehicleId uuid.UUID, model models.AuditLogQuery) (*[]db.AuditLog, error) {
+	if err := checkAuditVehicleAccess(userId, vehicleId); err != nil {
+		return nil, err
+	}
+	filter, err := toAuditLogFilter(model)
+	if err != nil {
+		return nil, err
+	}
+	filter.VehicleID = &vehicleId
+	return db.FindAuditLogs(filter)
+}
+
+// GetEntryAuditLog lists the changes made to a fillup or an expense of a
+// vehicle.
+func GetEntryAuditLog(userId, vehicleId uuid.UUID, entityType db.AuditEntity, entityId uuid.UUID, model models.AuditLogQuery) (*[]db.AuditLog, error) {
+	if err := checkAuditVehicleAccess(userId, vehicleId); err != nil {
+		return nil, err
+	}
+	filter, err := toAuditLogFilter(model)
+	if err != nil {
+		return nil, err
+	}
+	filter.VehicleID = &vehicleId
+	filter.EntityType = &entityType
+	filter.EntityID = &entityId
+	return db.FindAuditLogs(filter)
+}
+
+// GetAuditLog searches the whole audit log.
+func GetAuditLog(model models.AuditLogQuery) (*[]db.AuditLog, error) {
+	filter, err := toAuditLogFilter(model)
+	if err != nil {
+		return nil, err
+	}
+	return db.FindAuditLogs(filter)
+}
+
+func toAuditLogFilter(model models.AuditLogQuery) (db.AuditLogFilter, error) {
+	filter := db.AuditLogFilter{
+		EntityType: model.EntityType,
+		Action:     model.Action,
+		Start:      model.Start,
+		End:        model.End,
+		Limit:      model.Limit,
+		Offset:     model.Offset,
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLogLimit
+	}
+	var err error
+	if filter.EntityID, err = parseOptionalUUID(model.EntityID); err != nil {
+		return filter, err
+	}
+	if filter.VehicleID, err = parseOptionalUUID(model.VehicleID); err != nil {
+		return filter, err
+	}
+	if filter.ActorID, err = parseOptionalUUID(model.ActorID); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseOptionalUUID(value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// checkAuditVehicleAccess lets admins and the users the vehicle is shared with
+// see its history.
+func checkAuditVehicleAccess(userId, vehicleId uuid.UUID) error {
+	canAccess, err := canAccessVehicle(userId, vehicleId)
+	if err != nil {
+		return err
+	}
+	if !canAccess {
+		return errors.New("you are not allowed to see the history of this vehicle")
+	}
+	return nil
+}
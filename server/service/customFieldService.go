@@ -0,0 +1,429 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+func GetCustomFieldDefinitions(entityType *db.CustomFieldEntity) (*[]db.CustomFieldDefinition, error) {
+	return db.GetCustomFieldDefinitions(entityType)
+}
+
+func CreateCustomFieldDefinition(model models.CreateCustomFieldRequest) (*db.CustomFieldDefinition, error) {
+	if _, ok := db.CustomFieldEntityDetails[*model.EntityType]; !ok {
+		return nil, errors.New("unknown entity type")
+	}
+	if _, ok := db.CustomFieldTypeDetails[*model.FieldType]; !ok {
+		return nil, errors.New("unknown field type")
+	}
+	if !customFieldKeyPattern.MatchString(model.Key) {
+		return nil, errors.New("key must start with a letter and contain only letters, digits and underscores")
+	}
+	existing, err := getCustomFieldDefinitionsByKey(*model.EntityType)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := existing[model.Key]; ok {
+		return nil, fmt.Errorf("a custom field with the key '%s' already exists", model.Key)
+	}
+	options, err := customFieldOptions(*model.FieldType, model.Options)
+	if err != nil {
+		return nil, err
+	}
+	definition := db.CustomFieldDefinition{
+		EntityType: *model.EntityType,
+		Key:        model.Key,
+		Name:       strings.TrimSpace(model.Name),
+		FieldType:  *model.FieldType,
+		Options:    options,
+		IsRequired: model.IsRequired,
+		SortOrder:  model.SortOrder,
+	}
+	tx := db.DB.Create(&definition)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &definition, nil
+}
+
+// UpdateCustomFieldDefinition edits a field. Options of an enum field that are
+// still used by a value cannot be removed.
+func UpdateCustomFieldDefinition(id uuid.UUID, model models.UpdateCustomFieldRequest) error {
+	toUpdate, err := db.GetCustomFieldDefinitionById(id)
+	if err != nil {
+		return err
+	}
+	options, err := customFieldOptions(toUpdate.FieldType, model.Options)
+	if err != nil {
+		return err
+	}
+	if toUpdate.FieldType == db.ENUM_FIELD {
+		var inUse []string
+		tx := db.DB.Model(&db.CustomFieldValue{}).Where("custom_field_definition_id = ? AND value NOT IN ?", id, options).Distinct().Pluck("value", &inUse)
+		if tx.Error != nil {
+			return tx.Error
+		}
+		if len(inUse) > 0 {
+			return fmt.Errorf("options still in use cannot be removed: %s", strings.Join(inUse, ", "))
+		}
+	}
+	toUpdate.Name = strings.TrimSpace(model.Name)
+	toUpdate.Options = options
+	toUpdate.IsRequired = model.IsRequired
+	toUpdate.SortOrder = model.SortOrder
+	return db.DB.Omit(clause.Associations).Save(toUpdate).Error
+}
+
+func DeleteCustomFieldDefinition(id uuid.UUID) error {
+	if _, err := db.GetCustomFieldDefinitionById(id); err != nil {
+		return err
+	}
+	return db.DeleteCustomFieldDefinitionById(id)
+}
+
+func customFieldOptions(fieldType db.CustomFieldType, options []string) ([]string, error) {
+	if fieldType != db.ENUM_FIELD {
+		return nil, nil
+	}
+	toReturn := make([]string, 0, len(options))
+	seen := make(map[string]bool)
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[option] {
+			continue
+		}
+		seen[option] = true
+		toReturn = append(toReturn, option)
+	}
+	if len(toReturn) == 0 {
+		return nil, errors.New("an enum field needs at least one option")
+	}
+	return toReturn, nil
+}
+
+func getCustomFieldDefinitionsByKey(entityType db.CustomFieldEntity) (map[string]db.CustomFieldDefinition, error) {
+	definitions, err := db.GetCustomFieldDefinitions(&entityType)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]db.CustomFieldDefinition, len(*definitions))
+	for _, definition := range *definitions {
+		byKey[definition.Key] = definition
+	}
+	return byKey, nil
+}
+
+// toCustomFieldValue checks a value sent for a field and converts it into the
+// form in which it is stored.
+func toCustomFieldValue(definition db.CustomFieldDefinition, raw interface{}) (*db.CustomFieldValue, error) {
+	value := db.CustomFieldValue{
+		CustomFieldDefinitionID: definition.ID,
+		EntityType:              definition.EntityType,
+	}
+	switch definition.FieldType {
+	case db.NUMBER_FIELD:
+		var number float64
+		switch typed := raw.(type) {
+		case float64:
+			number = typed
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", definition.Key)
+			}
+			number = parsed
+		default:
+			return nil, fmt.Errorf("%s must be a number", definition.Key)
+		}
+		value.NumberValue = &number
+		value.Value = strconv.FormatFloat(number, 'f', -1, 64)
+	case db.DATE_FIELD:
+		text, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a date", definition.Key)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(text))
+		if err != nil {
+			date, err = time.Parse(time.RFC3339, strings.TrimSpace(text))
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a date", definition.Key)
+			}
+		}
+		date = truncateToDay(date)
+		value.DateValue = &date
+		value.Value = date.Format("2006-01-02")
+	case db.ENUM_FIELD:
+		text, ok := raw.(string)
+		if !ok || !containsString(definition.Options, text) {
+			return nil, fmt.Errorf("%s must be one of %s", definition.Key, strings.Join(definition.Options, ", "))
+		}
+		value.Value = text
+	default:
+		text, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be text", definition.Key)
+		}
+		value.Value = text
+	}
+	return &value, nil
+}
+
+func fromCustomFieldValue(definition db.CustomFieldDefinition, value db.CustomFieldValue) interface{} {
+	if definition.FieldType == db.NUMBER_FIELD && value.NumberValue != nil {
+		return *value.NumberValue
+	}
+	return value.Value
+}
+
+// customFieldChanges are the custom field values sent with an entity, checked
+// before the entity itself is saved.
+type customFieldChanges struct {
+	entityType db.CustomFieldEntity
+	values     []db.CustomFieldValue
+	cleared    []uuid.UUID
+}
+
+// prepareCustomFields checks the custom fields sent for an entity. Fields that
+// are left out are not changed, fields sent as null are cleared. A new entity
+// must be given every required field.
+func prepareCustomFields(entityType db.CustomFieldEntity, fields db.CustomFields, isNew bool) (*customFieldChanges, error) {
+	changes := customFieldChanges{entityType: entityType}
+	if fields == nil && !isNew {
+		return &changes, nil
+	}
+	definitions, err := getCustomFieldDefinitionsByKey(entityType)
+	if err != nil {
+		return nil, err
+	}
+	for key, raw := range fields {
+		definition, ok := definitions[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field '%s'", key)
+		}
+		if raw == nil || raw == "" {
+			if definition.IsRequired {
+				return nil, fmt.Errorf("%s is required", key)
+			}
+			changes.cleared = append(changes.cleared, definition.ID)
+			continue
+		}
+		value, err := toCustomFieldValue(definition, raw)
+		if err != nil {
+			return nil, err
+		}
+		changes.values = append(changes.values, *value)
+	}
+	if isNew {
+		for key, definition := range definitions {
+			if _, ok := fields[key]; definition.IsRequired && !ok {
+				return nil, fmt.Errorf("%s is required", key)
+			}
+		}
+	}
+	return &changes, nil
+}
+
+func (c *customFieldChanges) save(tx *gorm.DB, entityId uuid.UUID) error {
+	definitionIds := append([]uuid.UUID{}, c.cleared...)
+	for i := range c.values {
+		c.values[i].EntityID = entityId
+		definitionIds = append(definitionIds, c.values[i].CustomFieldDefinitionID)
+	}
+	if len(definitionIds) == 0 {
+		return nil
+	}
+	err := tx.Where("entity_type = ? AND entity_id = ? AND custom_field_definition_id IN ?", c.entityType, entityId, definitionIds).Unscoped().Delete(&db.CustomFieldValue{}).Error
+	if err != nil {
+		return err
+	}
+	if len(c.values) == 0 {
+		return nil
+	}
+	return tx.Create(&c.values).Error
+}
+
+// getCustomFields loads the custom fields of a list of entities of one type.
+func getCustomFields(tx *gorm.DB, entityType db.CustomFieldEntity, entityIds []uuid.UUID) (map[uuid.UUID]db.CustomFields, error) {
+	definitions, err := db.GetCustomFieldDefinitions(&entityType)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uuid.UUID]db.CustomFieldDefinition, len(*definitions))
+	for _, definition := range *definitions {
+		byId[definition.ID] = definition
+	}
+	values, err := db.GetCustomFieldValues(tx, entityType, entityIds)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make(map[uuid.UUID]db.CustomFields, len(entityIds))
+	for _, id := range entityIds {
+		toReturn[id] = db.CustomFields{}
+	}
+	for _, value := range *values {
+		definition, ok := byId[value.CustomFieldDefinitionID]
+		if !ok {
+			continue
+		}
+		toReturn[value.EntityID][definition.Key] = fromCustomFieldValue(definition, value)
+	}
+	return toReturn, nil
+}
+
+func SetVehiclesCustomFields(vehicles []db.Vehicle) error {
+	ids := make([]uuid.UUID, len(vehicles))
+	for i := range vehicles {
+		ids[i] = vehicles[i].ID
+	}
+	fields, err := getCustomFields(db.DB, db.VEHICLE_ENTITY, ids)
+	if err != nil {
+		return err
+	}
+	for i := range vehicles {
+		vehicles[i].CustomFields = fields[vehicles[i].ID]
+	}
+	return nil
+}
+
+func SetFillupsCustomFields(fillups []db.Fillup) error {
+	ids := make([]uuid.UUID, len(fillups))
+	for i := range fillups {
+		ids[i] = fillups[i].ID
+	}
+	fields, err := getCustomFields(db.DB, db.FILLUP_ENTITY, ids)
+	if err != nil {
+		return err
+	}
+	for i := range fillups {
+		fillups[i].CustomFields = fields[fillups[i].ID]
+	}
+	return nil
+}
+
+func SetExpensesCustomFields(expenses []db.Expense) error {
+	ids := make([]uuid.UUID, len(expenses))
+	for i := range expenses {
+		ids[i] = expenses[i].ID
+	}
+	fields, err := getCustomFields(db.DB, db.EXPENSE_ENTITY, ids)
+	if err != nil {
+		return err
+	}
+	for i := range expenses {
+		expenses[i].CustomFields = fields[expenses[i].ID]
+	}
+	return nil
+}
+
+// customFieldFilter matches entities whose custom fields equal the values
+// asked for. Text is compared case insensitively.
+type customFieldFilter struct {
+	definitions []db.CustomFieldDefinition
+	values      []db.CustomFieldValue
+}
+
+func newCustomFieldFilter(entityType db.CustomFieldEntity, filters map[string]string) (*customFieldFilter, error) {
+	filter := customFieldFilter{}
+	if len(filters) == 0 {
+		return &filter, nil
+	}
+	definitions, err := getCustomFieldDefinitionsByKey(entityType)
+	if err != nil {
+		return nil, err
+	}
+	for key, raw := range filters {
+		definition, ok := definitions[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field '%s'", key)
+		}
+		value, err := toCustomFieldValue(definition, raw)
+		if err != nil {
+			return nil, err
+		}
+		filter.definitions = append(filter.definitions, definition)
+		filter.values = append(filter.values, *value)
+	}
+	return &filter, nil
+}
+
+func (f *customFieldFilter) matches(fields db.CustomFields) bool {
+	for i, definition := range f.definitions {
+		found, ok := fields[definition.Key]
+		if !ok {
+			return false
+		}
+		wanted := fromCustomFieldValue(definition, f.values[i])
+		if text, isText := found.(string); isText && definition.FieldType == db.TEXT_FIELD {
+			if !strings.EqualFold(text, wanted.(string)) {
+				return false
+			}
+		} else if found != wanted {
+			return false
+		}
+	}
+	return true
+}
+
+func FilterVehiclesByCustomFields(vehicles []db.Vehicle, filters map[string]string) ([]db.Vehicle, error) {
+	filter, err := newCustomFieldFilter(db.VEHICLE_ENTITY, filters)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]db.Vehicle, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		if filter.matches(vehicle.CustomFields) {
+			toReturn = append(toReturn, vehicle)
+		}
+	}
+	return toReturn, nil
+}
+
+func FilterFillupsByCustomFields(fillups []db.Fillup, filters map[string]string) ([]db.Fillup, error) {
+	filter, err := newCustomFieldFilter(db.FILLUP_ENTITY, filters)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]db.Fillup, 0, len(fillups))
+	for _, fillup := range fillups {
+		if filter.matches(fillup.CustomFields) {
+			toReturn = append(toReturn, fillup)
+		}
+	}
+	return toReturn, nil
+}
+
+func FilterExpensesByCustomFields(expenses []db.Expense, filters map[string]string) ([]db.Expense, error) {
+	filter, err := newCustomFieldFilter(db.EXPENSE_ENTITY, filters)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]db.Expense, 0, len(expenses))
+	for _, expense := range expenses {
+		if filter.matches(expense.CustomFields) {
+			toReturn = append(toReturn, expense)
+		}
+	}
+	return toReturn, nil
+}
+
+func containsString(items []string, item string) bool {
+	for _, existing := range items {
+		if existing == item {
+			return true
+		}
+	}
+	return false
+}
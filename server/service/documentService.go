@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+const defaultDocumentReminderDays = 30
+
+func CreateVehicleDocument(vehicleId, userId uuid.UUID, model models.CreateVehicleDocumentRequest, attachmentId *uuid.UUID) (*db.VehicleDocument, error) {
+	document := db.VehicleDocument{
+		VehicleID:    vehicleId,
+		UserID:       userId,
+		AttachmentID: attachmentId,
+	}
+	if err := setVehicleDocument(&document, model); err != nil {
+		return nil, err
+	}
+	tx := db.DB.Create(&document)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := syncDocumentReminder(&document); err != nil {
+		return nil, err
+	}
+	return db.GetVehicleDocumentById(document.ID)
+}
+
+// UpdateVehicleDocument edits a document. The attachment is only replaced when
+// a new file is uploaded.
+func UpdateVehicleDocument(vehicleId, documentId uuid.UUID, model models.UpdateVehicleDocumentRequest, attachmentId *uuid.UUID) error {
+	toUpdate, err := getVehicleDocument(vehicleId, documentId)
+	if err != nil {
+		return err
+	}
+	if err := setVehicleDocument(toUpdate, model.CreateVehicleDocumentRequest); err != nil {
+		return err
+	}
+	if attachmentId != nil {
+		toUpdate.AttachmentID = attachmentId
+	}
+	if err := db.DB.Omit(clause.Associations).Save(toUpdate).Error; err != nil {
+		return err
+	}
+	return syncDocumentReminder(toUpdate)
+}
+
+func GetVehicleDocumentsByVehicleId(vehicleId uuid.UUID) (*[]db.VehicleDocument, error) {
+	return db.GetVehicleDocumentsByVehicleId(vehicleId)
+}
+
+func GetVehicleDocumentById(vehicleId, documentId uuid.UUID) (*db.VehicleDocument, error) {
+	return getVehicleDocument(vehicleId, documentId)
+}
+
+func DeleteVehicleDocument(vehicleId, documentId uuid.UUID) error {
+	document, err := getVehicleDocument(vehicleId, documentId)
+	if err != nil {
+		return err
+	}
+	if document.VehicleAlertID != nil {
+		if err := db.DeleteAlertById(*document.VehicleAlertID); err != nil {
+			return err
+		}
+	}
+	return db.DB.Where("id = ?", documentId).Unscoped().Delete(&db.VehicleDocument{}).Error
+}
+
+// GetExpiringDocumentsForUser lists the documents of all the user's vehicles
+// that expire within the given number of days, or have already expired.
+func GetExpiringDocumentsForUser(userId uuid.UUID, days int) (*[]db.VehicleDocument, error) {
+	if days <= 0 {
+		days = defaultDocumentReminderDays
+	}
+	vehicles, err := GetUserVehicles(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	var vehicleIds []uuid.UUID
+	for _, vehicle := range *vehicles {
+		vehicleIds = append(vehicleIds, vehicle.ID)
+	}
+	if len(vehicleIds) == 0 {
+		return &[]db.VehicleDocument{}, nil
+	}
+	return db.GetExpiringVehicleDocuments(vehicleIds, time.Now().AddDate(0, 0, days))
+}
+
+func setVehicleDocument(document *db.VehicleDocument, model models.CreateVehicleDocumentRequest) error {
+	if model.IssueDate != nil && model.ExpiryDate != nil && model.ExpiryDate.Before(*model.IssueDate) {
+		return errors.New("expiryDate should not be before issueDate")
+	}
+	reminderDays := defaultDocumentReminderDays
+	if model.ReminderDays != nil {
+		reminderDays = *model.ReminderDays
+	}
+	if reminderDays < 0 {
+		return errors.New("reminderDays should not be negative")
+	}
+	document.DocumentType = *model.DocumentType
+	document.Title = model.Title
+	document.ReferenceNumber = model.ReferenceNumber
+	document.IssueDate = model.IssueDate
+	document.ExpiryDate = model.ExpiryDate
+	document.ReminderDays = reminderDays
+	document.Comments = model.Comments
+	return nil
+}
+
+// syncDocumentReminder replaces the expiry alert of a document with one that
+// matches its current expiry date. Every user of the vehicle is reminded.
+func syncDocumentReminder(document *db.VehicleDocument) error {
+	if document.VehicleAlertID != nil {
+		if err := db.DeleteAlertById(*document.VehicleAlertID); err != nil {
+			return err
+		}
+		document.VehicleAlertID = nil
+	}
+	if document.ExpiryDate != nil {
+		distanceUnit, err := db.GetVehicleDistanceUnit(document.VehicleID)
+		if err != nil {
+			return err
+		}
+		alert := db.VehicleAlert{
+			VehicleID:      document.VehicleID,
+			UserID:         document.UserID,
+			Title:          fmt.Sprintf("%s expires on %s", document.Title, document.ExpiryDate.Format("2006-01-02")),
+			Comments:       document.ReferenceNumber,
+			StartDate:      document.ExpiryDate.AddDate(0, 0, -document.ReminderDays),
+			DistanceUnit:   distanceUnit,
+			AlertFrequency: db.ONETIME,
+			AlertType:      db.TIME,
+			AlertAllUsers:  true,
+			IsActive:       true,
+			EndDate:        document.ExpiryDate,
+		}
+		if err := db.DB.Create(&alert).Error; err != nil {
+			return err
+		}
+		if err := CreateAlertInstance(alert.ID); err != nil {
+			return err
+		}
+		document.VehicleAlertID = &alert.ID
+	}
+	return db.DB.Model(&db.VehicleDocument{}).Where("id = ?", document.ID).Update("vehicle_alert_id", document.VehicleAlertID).Error
+}
+
+func getVehicleDocument(vehicleId, documentId uuid.UUID) (*db.VehicleDocument, error) {
+	document, err := db.GetVehicleDocumentById(documentId)
+	if err != nil {
+		return nil, err
+	}
+	if document.VehicleID != vehicleId {
+		return nil, errors.New("document does not belong to this vehicle")
+	}
+	return document, nil
+}
@@ -11,7 +11,7 @@ import (
 	"hammond/db"
 )
 
-func DrivvoParseExpenses(content []byte, user *db.User, vehicle *db.Vehicle) ([]db.Expense, []string) {
+func DrivvoParseExpenses(content []byte, user *db.User, vehicle *db.Vehicle, importLocation bool) ([]db.Expense, []string) {
 	expenseReader := csv.NewReader(bytes.NewReader(content))
 	expenseReader.Comment = '#'
 	// Read headers (there is a trailing comma at the end, that's why we have to read the first line)
@@ -43,7 +43,13 @@ func DrivvoParseExpenses(content []byte, user *db.User, vehicle *db.Vehicle) ([]
 			errors = append(errors, "Found an invalid odometer reading at service/expense row "+strconv.Itoa(index+1))
 		}
 
+		var latitude, longitude *float64
+		var address string
 		notes := fmt.Sprintf("Location: %s\nNotes: %s\n", record[4], record[5])
+		if importLocation {
+			latitude, longitude, address = parseImportedLocation(record[4])
+			notes = fmt.Sprintf("Notes: %s\n", record[5])
+		}
 
 		expenses = append(expenses, db.Expense{
 			UserID:       user.ID,
@@ -55,6 +61,9 @@ func DrivvoParseExpenses(content []byte, user *db.User, vehicle *db.Vehicle) ([]
 			Currency:     user.Currency,
 			DistanceUnit: user.DistanceUnit,
 			Comments:     notes,
+			Latitude:     latitude,
+			Longitude:    longitude,
+			Address:      address,
 			Source:       "Drivvo",
 		})
 	}
@@ -96,9 +105,11 @@ func DrivvoParseRefuelings(content []byte, user *db.User, vehicle *db.Vehicle, i
 			errors = append(errors, "Found an invalid odometer reading at refuel row "+strconv.Itoa(index+1))
 		}
 
-		location := ""
+		var latitude, longitude *float64
+		var location, address string
 		if importLocation {
 			location = record[17]
+			latitude, longitude, address = parseImportedLocation(location)
 		}
 
 		pricePerUnit, err := strconv.ParseFloat(record[3], 32)
@@ -134,6 +145,9 @@ func DrivvoParseRefuelings(content []byte, user *db.User, vehicle *db.Vehicle, i
 			Currency:        user.Currency,
 			DistanceUnit:    user.DistanceUnit,
 			Comments:        notes,
+			Latitude:        latitude,
+			Longitude:       longitude,
+			Address:         address,
 			Source:          "Drivvo",
 		})
 
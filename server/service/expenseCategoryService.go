@@ -0,0 +1,291 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetAllExpenseCategories() (*[]db.ExpenseCategory, error) {
+	return db.GetAllExpenseCategories()
+}
+
+func GetExpenseCategoryById(id uuid.UUID) (*db.ExpenseCategory, error) {
+	return db.GetExpenseCategoryById(id)
+}
+
+func CreateExpenseCategory(model models.CreateExpenseCategoryRequest) (*db.ExpenseCategory, error) {
+	name := strings.Join(strings.Fields(model.Name), " ")
+	if err := checkExpenseCategoryNameIsFree(name, uuid.Nil); err != nil {
+		return nil, err
+	}
+	if model.ParentID != nil {
+		if _, err := db.GetExpenseCategoryById(*model.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	category := db.ExpenseCategory{
+		Name:     name,
+		ParentID: model.ParentID,
+		Icon:     model.Icon,
+		Colour:   model.Colour,
+	}
+	tx := db.DB.Create(&category)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &category, nil
+}
+
+// UpdateExpenseCategory edits a category. Renaming it rewrites the expense type
+// of every expense in it so that both stay in step.
+func UpdateExpenseCategory(id uuid.UUID, model models.UpdateExpenseCategoryRequest) error {
+	toUpdate, err := db.GetExpenseCategoryById(id)
+	if err != nil {
+		return err
+	}
+	name := strings.Join(strings.Fields(model.Name), " ")
+	if err := checkExpenseCategoryNameIsFree(name, id); err != nil {
+		return err
+	}
+	if err := checkExpenseCategoryParent(id, model.ParentID); err != nil {
+		return err
+	}
+
+	toUpdate.Name = name
+	toUpdate.ParentID = model.ParentID
+	toUpdate.Icon = model.Icon
+	toUpdate.Colour = model.Colour
+
+	tx := db.DB.Begin()
+	if err := tx.Omit(clause.Associations).Save(toUpdate).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&db.Expense{}).Where("expense_category_id = ?", id).Update("expense_type", name).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// DeleteExpenseCategory removes a user defined category. Its expenses and sub
+// categories move up to its parent.
+func DeleteExpenseCategory(id uuid.UUID) error {
+	category, err := db.GetExpenseCategoryById(id)
+	if err != nil {
+		return err
+	}
+	if category.IsSystem {
+		return errors.New("system categories cannot be deleted")
+	}
+
+	expenseUpdates := map[string]interface{}{"expense_category_id": category.ParentID}
+	if category.ParentID != nil {
+		parent, err := db.GetExpenseCategoryById(*category.ParentID)
+		if err != nil {
+			return err
+		}
+		expenseUpdates["expense_type"] = parent.Name
+	}
+
+	tx := db.DB.Begin()
+	if err := tx.Model(&db.Expense{}).Where("expense_category_id = ?", id).Updates(expenseUpdates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&db.ExpenseCategory{}).Where("parent_id = ?", id).Update("parent_id", category.ParentID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("expense_category_id = ?", id).Unscoped().Delete(&db.ExpenseCategoryAlias{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("id = ?", id).Unscoped().Delete(&db.ExpenseCategory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// MergeExpenseCategory folds one category into another. The expenses and sub
+// categories of the source move to the target, and the name and aliases of the
+// source become aliases of the target so that later imports land there too.
+func MergeExpenseCategory(sourceId, targetId uuid.UUID) error {
+	if sourceId == targetId {
+		return errors.New("a category cannot be merged into itself")
+	}
+	source, err := db.GetExpenseCategoryById(sourceId)
+	if err != nil {
+		return err
+	}
+	if source.IsSystem {
+		return errors.New("system categories cannot be merged into another category")
+	}
+	target, err := db.GetExpenseCategoryById(targetId)
+	if err != nil {
+		return err
+	}
+	if err := checkExpenseCategoryParent(sourceId, &targetId); err != nil {
+		return errors.New("a category cannot be merged into one of its sub categories")
+	}
+
+	aliases := []string{db.NormaliseCategoryName(source.Name)}
+	for _, alias := range source.Aliases {
+		aliases = append(aliases, alias.Alias)
+	}
+
+	tx := db.DB.Begin()
+	err = tx.Model(&db.Expense{}).Where("expense_category_id = ?", sourceId).Updates(map[string]interface{}{
+		"expense_category_id": targetId,
+		"expense_type":        target.Name,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&db.ExpenseCategory{}).Where("parent_id = ?", sourceId).Update("parent_id", targetId).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("expense_category_id = ?", sourceId).Unscoped().Delete(&db.ExpenseCategoryAlias{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, alias := range aliases {
+		if alias == db.NormaliseCategoryName(target.Name) || hasExpenseCategoryAlias(target, alias) {
+			continue
+		}
+		if err := tx.Create(&db.ExpenseCategoryAlias{ExpenseCategoryID: targetId, Alias: alias}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Where("id = ?", sourceId).Unscoped().Delete(&db.ExpenseCategory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func CreateExpenseCategoryAlias(id uuid.UUID, model models.ExpenseCategoryAliasRequest) (*db.ExpenseCategoryAlias, error) {
+	if _, err := db.GetExpenseCategoryById(id); err != nil {
+		return nil, err
+	}
+	alias := db.NormaliseCategoryName(model.Alias)
+	if err := checkExpenseCategoryNameIsFree(alias, uuid.Nil); err != nil {
+		return nil, err
+	}
+	categoryAlias := db.ExpenseCategoryAlias{
+		ExpenseCategoryID: id,
+		Alias:             alias,
+	}
+	tx := db.DB.Create(&categoryAlias)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &categoryAlias, nil
+}
+
+func DeleteExpenseCategoryAlias(id, aliasId uuid.UUID) error {
+	tx := db.DB.Where("id = ? AND expense_category_id = ?", aliasId, id).Unscoped().Delete(&db.ExpenseCategoryAlias{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errors.New("alias does not belong to this category")
+	}
+	return nil
+}
+
+// resolveExpenseCategory picks the category of an expense, either the one asked
+// for explicitly or the one matching its free text type. A type that matches
+// nothing is kept as free text only, categories are made by admins.
+func resolveExpenseCategory(categoryId *uuid.UUID, expenseType string) (*db.ExpenseCategory, error) {
+	if categoryId != nil {
+		return db.GetExpenseCategoryById(*categoryId)
+	}
+	if strings.TrimSpace(expenseType) == "" {
+		return nil, nil
+	}
+	category, err := db.FindExpenseCategoryByName(expenseType)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return category, err
+}
+
+// resolveImportedExpenseCategories maps the expense types found in an import
+// onto categories through their names and aliases. Types matching none stay
+// uncategorised.
+func resolveImportedExpenseCategories(expenses []db.Expense) error {
+	resolved := make(map[string]*db.ExpenseCategory)
+	for i := range expenses {
+		key := db.NormaliseCategoryName(expenses[i].ExpenseType)
+		if key == "" {
+			continue
+		}
+		category, ok := resolved[key]
+		if !ok {
+			var err error
+			category, err = resolveExpenseCategory(nil, expenses[i].ExpenseType)
+			if err != nil {
+				return err
+			}
+			resolved[key] = category
+		}
+		if category == nil {
+			continue
+		}
+		expenses[i].ExpenseCategoryID = &category.ID
+		expenses[i].ExpenseType = category.Name
+	}
+	return nil
+}
+
+func checkExpenseCategoryNameIsFree(name string, id uuid.UUID) error {
+	existing, err := db.FindExpenseCategoryByName(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if existing.ID != id {
+		return fmt.Errorf("'%s' is already used by the category '%s'", name, existing.Name)
+	}
+	return nil
+}
+
+// checkExpenseCategoryParent makes sure that the category would not end up
+// being its own ancestor.
+func checkExpenseCategoryParent(id uuid.UUID, parentId *uuid.UUID) error {
+	for current := parentId; current != nil; {
+		if *current == id {
+			return errors.New("a category cannot be nested under itself")
+		}
+		parent, err := db.GetExpenseCategoryById(*current)
+		if err != nil {
+			return err
+		}
+		current = parent.ParentID
+	}
+	return nil
+}
+
+func hasExpenseCategoryAlias(category *db.ExpenseCategory, alias string) bool {
+	for _, item := range category.Aliases {
+		if item.Alias == alias {
+			return true
+		}
+	}
+	return false
+}
@@ -18,28 +18,17 @@ import (
 	"hammond/models"
 )
 
-func CreateAttachment(path, originalName string, size int64, contentType string, userId uuid.UUID) (*db.Attachment, error) {
-	model := &db.Attachment{
-		Path:         path,
-		OriginalName: originalName,
-		Size:         size,
-		ContentType:  contentType,
-		UserID:       userId,
-	}
-	tx := db.DB.Create(&model)
-
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-	return model, nil
-}
-
-func CreateQuickEntry(model models.CreateQuickEntryModel, attachmentId, userId uuid.UUID) (*db.QuickEntry, error) {
+func CreateQuickEntry(model models.CreateQuickEntryModel, attachment *db.Attachment, userId uuid.UUID) (*db.QuickEntry, error) {
 	toCreate := &db.QuickEntry{
-		AttachmentID: attachmentId,
+		AttachmentID: attachment.ID,
 		UserID:       userId,
 		Comments:     model.Comments,
 	}
+	if attachment.Photo != nil {
+		toCreate.CaptureDate = attachment.Photo.CaptureDate
+		toCreate.Latitude = attachment.Photo.Latitude
+		toCreate.Longitude = attachment.Photo.Longitude
+	}
 	tx := db.DB.Create(&toCreate)
 
 	if tx.Error != nil {
@@ -65,7 +54,7 @@ func DeleteQuickEntryById(id uuid.UUID) error {
 }
 
 func SetQuickEntryAsProcessed(id uuid.UUID) error {
-	return db.SetQuickEntryAsProcessed(id, time.Now())
+	return db.SetQuickEntryAsProcessed(db.DB, id, time.Now())
 
 }
 
@@ -114,18 +103,6 @@ func deleteOldBackup() {
 	}
 }
 
-func GetFilePath(originalName string) string {
-	dataPath := os.Getenv("DATA")
-	return path.Join(dataPath, getFileName(originalName))
-}
-
-func getFileName(orig string) string {
-
-	ext := filepath.Ext(orig)
-	return uuid.New().String() + ext
-
-}
-
 func CreateBackup() (string, error) {
 
 	backupFileName := "hammond_backup_" + time.Now().Format("2006.01.02_150405") + ".tar.gz"
@@ -155,14 +132,14 @@ func CreateBackup() (string, error) {
 		_ = tarWriter.Close()
 	}()
 
-	err = addFileToTarWriter(dbPath, tarWriter)
+	err = addFileToTarWriter(dbPath, dbPath, tarWriter)
 	if err == nil {
 		deleteOldBackup()
 	}
 	return backupFileName, err
 }
 
-func addFileToTarWriter(filePath string, tarWriter *tar.Writer) error {
+func addFileToTarWriter(filePath, name string, tarWriter *tar.Writer) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("could not open file '%s', got error '%s'", filePath, err.Error())
@@ -177,7 +154,7 @@ func addFileToTarWriter(filePath string, tarWriter *tar.Writer) error {
 	}
 
 	header := &tar.Header{
-		Name:    filePath,
+		Name:    name,
 		Size:    stat.Size(),
 		Mode:    int64(stat.Mode()),
 		ModTime: stat.ModTime(),
@@ -0,0 +1,469 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"hammond/common"
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stationMatchThreshold is the score above which two station names are taken
+// to be spellings of the same station.
+const stationMatchThreshold = 0.7
+
+func GetAllFillingStations() (*[]db.FillingStation, error) {
+	return db.GetAllFillingStations()
+}
+
+func GetFillingStationById(id uuid.UUID) (*db.FillingStation, error) {
+	return db.GetFillingStationById(id)
+}
+
+func CreateFillingStation(model models.CreateFillingStationRequest) (*db.FillingStation, error) {
+	name := strings.Join(strings.Fields(model.Name), " ")
+	if err := checkStationNameIsFree(name, uuid.Nil); err != nil {
+		return nil, err
+	}
+	if err := checkLocation(model.Latitude, model.Longitude); err != nil {
+		return nil, err
+	}
+	station := db.FillingStation{
+		Name:      name,
+		Brand:     strings.TrimSpace(model.Brand),
+		Latitude:  model.Latitude,
+		Longitude: model.Longitude,
+		Address:   model.Address,
+		Notes:     model.Notes,
+	}
+	tx := db.DB.Begin()
+	if err := tx.Create(&station).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// fillups already carrying its name are linked to the new station
+	if err := db.LinkFillupsToStation(tx, &station, []string{name}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &station, nil
+}
+
+// UpdateFillingStation edits a station. Renaming it renames every fillup linked
+// to it so that both stay in step.
+func UpdateFillingStation(id uuid.UUID, model models.UpdateFillingStationRequest) error {
+	toUpdate, err := db.GetFillingStationById(id)
+	if err != nil {
+		return err
+	}
+	name := strings.Join(strings.Fields(model.Name), " ")
+	if err := checkStationNameIsFree(name, id); err != nil {
+		return err
+	}
+	if err := checkLocation(model.Latitude, model.Longitude); err != nil {
+		return err
+	}
+
+	toUpdate.Name = name
+	toUpdate.Brand = strings.TrimSpace(model.Brand)
+	toUpdate.Latitude = model.Latitude
+	toUpdate.Longitude = model.Longitude
+	toUpdate.Address = model.Address
+	toUpdate.Notes = model.Notes
+
+	tx := db.DB.Begin()
+	if err := tx.Omit(clause.Associations).Save(toUpdate).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&db.Fillup{}).Where("filling_station_id = ?", id).Update("filling_station", name).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// DeleteFillingStation removes a station. Its fillups keep its name as free
+// text.
+func DeleteFillingStation(id uuid.UUID) error {
+	if _, err := db.GetFillingStationById(id); err != nil {
+		return err
+	}
+	return db.DeleteFillingStationById(id)
+}
+
+// MergeFillingStation folds one station into another. The fillups of the source
+// move to the target, and the name and aliases of the source become aliases of
+// the target so that later fillups and imports land there too.
+func MergeFillingStation(sourceId, targetId uuid.UUID) error {
+	if sourceId == targetId {
+		return errors.New("a station cannot be merged into itself")
+	}
+	source, err := db.GetFillingStationById(sourceId)
+	if err != nil {
+		return err
+	}
+	target, err := db.GetFillingStationById(targetId)
+	if err != nil {
+		return err
+	}
+
+	aliases := []string{db.NormaliseStationName(source.Name)}
+	for _, alias := range source.Aliases {
+		aliases = append(aliases, alias.Alias)
+	}
+
+	tx := db.DB.Begin()
+	err = tx.Model(&db.Fillup{}).Where("filling_station_id = ?", sourceId).Updates(map[string]interface{}{
+		"filling_station_id": targetId,
+		"filling_station":    target.Name,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("filling_station_id = ?", sourceId).Unscoped().Delete(&db.FillingStationAlias{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := addStationAliases(tx, target, aliases); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("id = ?", sourceId).Unscoped().Delete(&db.FillingStation{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// MergeStationNames folds free text station names into a station. The names
+// become aliases of the station and the unlinked fillups carrying them are
+// linked to it.
+func MergeStationNames(id uuid.UUID, model models.FillingStationNamesRequest) (*db.FillingStation, error) {
+	station, err := db.GetFillingStationById(id)
+	if err != nil {
+		return nil, err
+	}
+	var aliases []string
+	for _, name := range model.Names {
+		alias := db.NormaliseStationName(name)
+		if alias == "" {
+			continue
+		}
+		if err := checkStationNameIsFree(alias, id); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+
+	tx := db.DB.Begin()
+	if err := addStationAliases(tx, station, aliases); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := db.LinkFillupsToStation(tx, station, model.Names); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return db.GetFillingStationById(id)
+}
+
+func DeleteFillingStationAlias(id, aliasId uuid.UUID) error {
+	tx := db.DB.Where("id = ? AND filling_station_id = ?", aliasId, id).Unscoped().Delete(&db.FillingStationAlias{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errors.New("alias does not belong to this station")
+	}
+	return nil
+}
+
+// MatchFillingStations lists the stations resembling a name, closest first.
+func MatchFillingStations(name string) ([]models.FillingStationMatchModel, error) {
+	stations, err := db.GetAllFillingStations()
+	if err != nil {
+		return nil, err
+	}
+	return matchStations(*stations, name), nil
+}
+
+// GetUnlinkedStationNames lists the free text station names no station claims
+// yet, along with the stations and other names they resemble, so that they can
+// be folded into stations.
+func GetUnlinkedStationNames() ([]models.UnlinkedStationNameModel, error) {
+	usages, err := db.GetUnlinkedStationNames()
+	if err != nil {
+		return nil, err
+	}
+	stations, err := db.GetAllFillingStations()
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]models.UnlinkedStationNameModel, 0, len(usages))
+	for _, usage := range usages {
+		model := models.UnlinkedStationNameModel{
+			Name:         usage.FillingStation,
+			CountFillups: usage.CountFillups,
+			Matches:      matchStations(*stations, usage.FillingStation),
+			Similar:      []string{},
+		}
+		for _, other := range usages {
+			if other.FillingStation != usage.FillingStation && stationNameSimilarity(usage.FillingStation, other.FillingStation) >= stationMatchThreshold {
+				model.Similar = append(model.Similar, other.FillingStation)
+			}
+		}
+		toReturn = append(toReturn, model)
+	}
+	return toReturn, nil
+}
+
+// AutocompleteFillingStations suggests the stations matching what the user has
+// typed so far. Stations the user fills up at often and those close to the
+// given location come first.
+func AutocompleteFillingStations(userId uuid.UUID, model models.FillingStationAutocompleteQuery) ([]models.FillingStationSuggestionModel, error) {
+	if err := checkLocation(model.Latitude, model.Longitude); err != nil {
+		return nil, err
+	}
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	vehicles, err := GetUserVehicles(userId, true)
+	if err != nil {
+		return nil, err
+	}
+	var vehicleIds []uuid.UUID
+	for _, vehicle := range *vehicles {
+		vehicleIds = append(vehicleIds, vehicle.ID)
+	}
+	usages, err := db.GetStationUsage(vehicleIds)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uuid.UUID]int)
+	maxCount := 0
+	for _, usage := range usages {
+		counts[usage.FillingStationID] = usage.CountFillups
+		if usage.CountFillups > maxCount {
+			maxCount = usage.CountFillups
+		}
+	}
+	stations, err := db.GetAllFillingStations()
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.NormaliseStationName(model.Query)
+	var suggestions []models.FillingStationSuggestionModel
+	for _, station := range *stations {
+		if query != "" && !stationMatchesQuery(station, query) {
+			continue
+		}
+		suggestion := models.FillingStationSuggestionModel{
+			Station:      station,
+			CountFillups: counts[station.ID],
+			DistanceUnit: user.DistanceUnit,
+		}
+		if maxCount > 0 {
+			suggestion.Score = float64(suggestion.CountFillups) / float64(maxCount)
+		}
+		if model.Latitude != nil && station.Latitude != nil && station.Longitude != nil {
+			distance := haversineDistance(*model.Latitude, *model.Longitude, *station.Latitude, *station.Longitude)
+			// closeness halves every couple of kilometres
+			suggestion.Score += 1 / (1 + distance/2)
+			userDistance := float32(distance)
+			if user.DistanceUnit == db.MILES {
+				userDistance = common.KmToMiles(userDistance)
+			}
+			suggestion.Distance = &userDistance
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Station.Name < suggestions[j].Station.Name
+	})
+
+	limit := model.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// resolveFillingStation picks the station of a fillup, either the one asked for
+// explicitly or the one whose name or alias matches its free text station. A
+// name that matches nothing is kept as free text only.
+func resolveFillingStation(stationId *uuid.UUID, name string) (*db.FillingStation, error) {
+	if stationId != nil {
+		return db.GetFillingStationById(*stationId)
+	}
+	station, err := db.FindFillingStationByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return station, err
+}
+
+// resolveImportedFillingStations links the fillups found in an import to the
+// stations matching their free text station.
+func resolveImportedFillingStations(fillups []db.Fillup) error {
+	resolved := make(map[string]*db.FillingStation)
+	for i := range fillups {
+		key := db.NormaliseStationName(fillups[i].FillingStation)
+		if key == "" {
+			continue
+		}
+		station, ok := resolved[key]
+		if !ok {
+			var err error
+			station, err = resolveFillingStation(nil, fillups[i].FillingStation)
+			if err != nil {
+				return err
+			}
+			resolved[key] = station
+		}
+		if station != nil {
+			fillups[i].FillingStationID = &station.ID
+			fillups[i].FillingStation = station.Name
+		}
+	}
+	return nil
+}
+
+func addStationAliases(tx *gorm.DB, station *db.FillingStation, aliases []string) error {
+	added := map[string]bool{db.NormaliseStationName(station.Name): true}
+	for _, alias := range station.Aliases {
+		added[alias.Alias] = true
+	}
+	for _, alias := range aliases {
+		if added[alias] {
+			continue
+		}
+		if err := tx.Create(&db.FillingStationAlias{FillingStationID: station.ID, Alias: alias}).Error; err != nil {
+			return err
+		}
+		added[alias] = true
+	}
+	return nil
+}
+
+func checkStationNameIsFree(name string, id uuid.UUID) error {
+	existing, err := db.FindFillingStationByName(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if existing.ID != id {
+		return fmt.Errorf("'%s' is already used by the station '%s'", name, existing.Name)
+	}
+	return nil
+}
+
+func matchStations(stations []db.FillingStation, name string) []models.FillingStationMatchModel {
+	matches := []models.FillingStationMatchModel{}
+	for _, station := range stations {
+		score := 0.0
+		for _, candidate := range stationNames(station) {
+			score = math.Max(score, stationNameSimilarity(name, candidate))
+		}
+		if score >= stationMatchThreshold {
+			matches = append(matches, models.FillingStationMatchModel{Station: station, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+// stationNames are the names a station goes by: its own, its name prefixed by
+// its brand, and its aliases.
+func stationNames(station db.FillingStation) []string {
+	names := []string{station.Name}
+	if station.Brand != "" {
+		names = append(names, station.Brand+" "+station.Name)
+	}
+	for _, alias := range station.Aliases {
+		names = append(names, alias.Alias)
+	}
+	return names
+}
+
+func stationMatchesQuery(station db.FillingStation, query string) bool {
+	for _, name := range append(stationNames(station), station.Brand) {
+		normalised := db.NormaliseStationName(name)
+		if strings.Contains(normalised, query) || stationNameSimilarity(normalised, query) >= stationMatchThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// stationNameSimilarity scores from 0 to 1 how alike two station names are,
+// based on the edit distance between their normalised forms.
+func stationNameSimilarity(a, b string) float64 {
+	first := []rune(db.NormaliseStationName(a))
+	second := []rune(db.NormaliseStationName(b))
+	longest := len(first)
+	if len(second) > longest {
+		longest = len(second)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(first, second))/float64(longest)
+}
+
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// haversineDistance is the distance in kilometres between two points.
+func haversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
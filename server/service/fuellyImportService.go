@@ -30,7 +30,7 @@ func FuellyParseAll(content []byte, userId uuid.UUID) ([]db.Fillup, []db.Expense
 		return nil, nil, errors
 	}
 
-	vehicles, err := GetUserVehicles(userId)
+	vehicles, err := GetUserVehicles(userId, true)
 	if err != nil {
 		errors = append(errors, err.Error())
 		return nil, nil, errors
@@ -83,6 +83,7 @@ func FuellyParseAll(content []byte, userId uuid.UUID) ([]db.Fillup, []db.Expense
 			errors = append(errors, "Found an invalid odo reading at row "+strconv.Itoa(index+1))
 		}
 		location := record[12]
+		latitude, longitude, address := parseImportedLocation(location)
 
 		//Create Fillup
 		if record[0] == "Gas" {
@@ -115,6 +116,9 @@ func FuellyParseAll(content []byte, userId uuid.UUID) ([]db.Fillup, []db.Expense
 				IsTankFull:      &isTankFull,
 				Comments:        notes,
 				FillingStation:  location,
+				Latitude:        latitude,
+				Longitude:       longitude,
+				Address:         address,
 				HasMissedFillup: &fal,
 				UserID:          userId,
 				Date:            date,
@@ -138,6 +142,9 @@ func FuellyParseAll(content []byte, userId uuid.UUID) ([]db.Fillup, []db.Expense
 				Currency:     user.Currency,
 				Date:         date,
 				DistanceUnit: user.DistanceUnit,
+				Latitude:     latitude,
+				Longitude:    longitude,
+				Address:      address,
 				Source:       "Fuelly",
 			})
 		}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+)
+
+const collectGarbageJob = "CollectGarbage"
+
+// Files are saved before their attachment is created, and attachments are
+// uploaded before they are linked to anything, so whatever is younger than
+// these is left alone.
+const (
+	orphanedFileGracePeriod       = time.Hour
+	orphanedAttachmentGracePeriod = 24 * time.Hour
+)
+
+// CollectGarbage looks for what is left behind by removed attachments and
+// quick entries. It only removes it when the setting allows it, otherwise
+// what it finds is logged.
+func CollectGarbage() {
+	if !db.GetLock(collectGarbageJob).Date.IsZero() {
+		return
+	}
+	db.Lock(collectGarbageJob, 60)
+	defer db.Unlock(collectGarbageJob)
+
+	report, err := collectGarbage(db.GetOrCreateSetting().GarbageCollectionCleanUp)
+	if err != nil {
+		fmt.Println("error while collecting garbage", err)
+		return
+	}
+	verb := "found"
+	if report.CleanUp {
+		verb = "removed"
+	}
+	log.Printf("garbage collection %s %d orphaned files, %d orphaned attachments, %d unlinked and %d expired quick entries, %d stale thumbnails, and found %d attachments with missing files",
+		verb, len(report.OrphanedFiles), len(report.OrphanedAttachments), len(report.UnlinkedQuickEntries), len(report.ExpiredQuickEntries), report.StaleThumbnails, len(report.MissingFiles))
+	for _, err := range report.Errors {
+		log.Println(err)
+	}
+}
+
+// CollectGarbageNow runs the garbage collection straight away, unless it is
+// already running.
+func CollectGarbageNow(cleanUp bool) (*models.GarbageCollectionModel, error) {
+	if !db.GetLock(collectGarbageJob).Date.IsZero() {
+		return nil, errors.New("the garbage collection is already running")
+	}
+	db.Lock(collectGarbageJob, 60)
+	defer db.Unlock(collectGarbageJob)
+
+	return collectGarbage(cleanUp)
+}
+
+func UpdateGarbageCollectionSettings(model models.GarbageCollectionSettingsRequest) error {
+	setting := db.GetOrCreateSetting()
+	setting.QuickEntryRetentionDays = model.QuickEntryRetentionDays
+	if model.CleanUp != nil {
+		setting.GarbageCollectionCleanUp = *model.CleanUp
+	}
+	return db.UpdateSettings(setting)
+}
+
+func quickEntryRetentionDays() int {
+	days := db.GetOrCreateSetting().QuickEntryRetentionDays
+	if days <= 0 {
+		return db.DefaultQuickEntryRetentionDays
+	}
+	return days
+}
+
+func collectGarbage(cleanUp bool) (*models.GarbageCollectionModel, error) {
+	report := models.GarbageCollectionModel{
+		CleanUp:              cleanUp,
+		OrphanedFiles:        []models.OrphanedFileModel{},
+		MissingFiles:         []db.Attachment{},
+		OrphanedAttachments:  []db.Attachment{},
+		UnlinkedQuickEntries: []uuid.UUID{},
+		ExpiredQuickEntries:  []uuid.UUID{},
+		Errors:               []string{},
+	}
+
+	attachments, err := db.GetAllAttachments()
+	if err != nil {
+		return nil, err
+	}
+	stored := findStoredFiles(*attachments, &report)
+	// attachments whose file is missing are only reported, a DATA folder
+	// which is not mounted or was moved makes every one of them look missing
+	report.MissingFiles = findMissingFiles(*attachments, stored, &report)
+	storageLooksWrong := len(report.MissingFiles) > 0 && len(report.MissingFiles)*2 >= len(*attachments)
+	if storageLooksWrong {
+		report.Errors = append(report.Errors, fmt.Sprintf("%d of %d attachments have no file, check that the storage is set up right; files no attachment refers to are left alone", len(report.MissingFiles), len(*attachments)))
+	}
+
+	var goneAttachmentIds []uuid.UUID
+	expired, err := db.GetQuickEntriesProcessedBefore(time.Now().AddDate(0, 0, -quickEntryRetentionDays()))
+	if err != nil {
+		return nil, err
+	}
+	unlinked, err := db.GetUnlinkedQuickEntries()
+	if err != nil {
+		return nil, err
+	}
+	var goneQuickEntryIds []uuid.UUID
+	for _, quickEntry := range *unlinked {
+		report.UnlinkedQuickEntries = append(report.UnlinkedQuickEntries, quickEntry.ID)
+		goneQuickEntryIds = append(goneQuickEntryIds, quickEntry.ID)
+	}
+	for _, quickEntry := range *expired {
+		if !containsId(goneQuickEntryIds, quickEntry.ID) {
+			report.ExpiredQuickEntries = append(report.ExpiredQuickEntries, quickEntry.ID)
+			goneQuickEntryIds = append(goneQuickEntryIds, quickEntry.ID)
+		}
+	}
+
+	orphaned, err := db.GetUnreferencedAttachments(time.Now().Add(-orphanedAttachmentGracePeriod), goneQuickEntryIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range *orphaned {
+		if !containsId(goneAttachmentIds, attachment.ID) {
+			report.OrphanedAttachments = append(report.OrphanedAttachments, attachment)
+			goneAttachmentIds = append(goneAttachmentIds, attachment.ID)
+		}
+	}
+
+	keptAttachmentIds := make(map[uuid.UUID]bool)
+	for _, attachment := range *attachments {
+		keptAttachmentIds[attachment.ID] = true
+	}
+	for _, id := range goneAttachmentIds {
+		delete(keptAttachmentIds, id)
+	}
+
+	if cleanUp {
+		tx := db.DB.Begin()
+		if err := db.DeleteQuickEntries(tx, goneQuickEntryIds); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := db.DeleteAttachments(tx, goneAttachmentIds); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		for _, attachment := range report.OrphanedAttachments {
+			RemoveThumbnails(attachment.ID)
+			deleted, err := deleteAttachmentFile(&attachment)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", attachment.ID, err))
+				}
+				continue
+			}
+			if deleted {
+				report.FreedBytes += attachment.Size
+			}
+		}
+		if !storageLooksWrong {
+			removeOrphanedFiles(&report)
+		}
+	}
+	collectStaleThumbnails(keptAttachmentIds, &report)
+	return &report, nil
+}
+
+// findStoredFiles lists the files of every storage in use, noting those which
+// no attachment refers to as orphaned. A file named like the file of an
+// attachment is not orphaned even if it is somewhere else, as it may be in a
+// DATA folder which was moved. Storages which can't be listed are left out, so
+// their attachments are not taken for missing.
+func findStoredFiles(attachments []db.Attachment, report *models.GarbageCollectionModel) map[string]map[string]bool {
+	referenced := map[string]map[string]bool{LocalStorageName: {}}
+	for _, attachment := range attachments {
+		storage := attachment.Storage
+		if storage == "" {
+			storage = LocalStorageName
+		}
+		if referenced[storage] == nil {
+			referenced[storage] = make(map[string]bool)
+		}
+		referenced[storage][attachment.Path] = true
+		referenced[storage][path.Base(attachment.Path)] = true
+	}
+	if current, err := CurrentStorage(); err == nil && referenced[current.Name()] == nil {
+		referenced[current.Name()] = make(map[string]bool)
+	}
+
+	stored := make(map[string]map[string]bool)
+	before := time.Now().Add(-orphanedFileGracePeriod)
+	for name := range referenced {
+		storage, err := GetStorage(name)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		files := make(map[string]bool)
+		var orphans []models.OrphanedFileModel
+		err = storage.Walk(func(filePath string, size int64, modTime time.Time) error {
+			files[filePath] = true
+			if !referenced[name][filePath] && !referenced[name][path.Base(filePath)] && isAttachmentFileName(filePath) && modTime.Before(before) {
+				orphans = append(orphans, models.OrphanedFileModel{
+					Storage: name,
+					Path:    filePath,
+					Size:    size,
+					ModTime: modTime,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		stored[name] = files
+		report.OrphanedFiles = append(report.OrphanedFiles, orphans...)
+	}
+	return stored
+}
+
+// findMissingFiles finds the attachments whose file is not in its storage.
+// They are reported, never removed.
+// Files which were not listed are opened before they are taken for missing,
+// as local files may have been saved outside of the DATA folder of today.
+func findMissingFiles(attachments []db.Attachment, stored map[string]map[string]bool, report *models.GarbageCollectionModel) []db.Attachment {
+	missing := []db.Attachment{}
+	for _, attachment := range attachments {
+		storage := attachment.Storage
+		if storage == "" {
+			storage = LocalStorageName
+		}
+		files, ok := stored[storage]
+		if !ok || files[attachment.Path] {
+			continue
+		}
+		file, err := OpenAttachment(&attachment)
+		switch {
+		case os.IsNotExist(err):
+			missing = append(missing, attachment)
+		case err != nil:
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", attachment.ID, err))
+		default:
+			file.Close()
+		}
+	}
+	return missing
+}
+
+func removeOrphanedFiles(report *models.GarbageCollectionModel) {
+	for _, orphan := range report.OrphanedFiles {
+		// an upload of the same content may have brought it back since
+		count, err := db.CountAttachmentsOfFile(orphan.Storage, orphan.Path)
+		if err != nil || count > 0 {
+			continue
+		}
+		storage, err := GetStorage(orphan.Storage)
+		if err == nil {
+			err = storage.Delete(orphan.Path)
+		}
+		if err != nil {
+			if !os.IsNotExist(err) {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", orphan.Path, err))
+			}
+			continue
+		}
+		report.FreedBytes += orphan.Size
+	}
+}
+
+// collectStaleThumbnails counts the thumbnails of attachments which are gone,
+// and removes them when cleaning up.
+func collectStaleThumbnails(keptAttachmentIds map[uuid.UUID]bool, report *models.GarbageCollectionModel) {
+	folder := createConfigFolderIfNotExists("thumbnails")
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("thumbnails: %s", err))
+		return
+	}
+	before := time.Now().Add(-orphanedFileGracePeriod)
+	for _, entry := range entries {
+		name := entry.Name()
+		// named <attachment id>-<size>.jpg
+		if len(name) < 37 || name[36] != '-' {
+			continue
+		}
+		id, err := uuid.Parse(name[:36])
+		if err != nil || keptAttachmentIds[id] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if report.CleanUp {
+			if err := os.Remove(path.Join(folder, name)); err != nil {
+				if !os.IsNotExist(err) {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", name, err))
+				}
+				continue
+			}
+			report.FreedBytes += info.Size()
+		}
+		report.StaleThumbnails++
+	}
+}
+
+// isAttachmentFileName tells whether a file is named the way attachments are
+// saved, by their content hash or, before that, by a random id. Anything else
+// found in storage is not ours to remove.
+func isAttachmentFileName(filePath string) bool {
+	name := path.Base(filePath)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if len(name) == 64 {
+		_, err := hex.DecodeString(name)
+		return err == nil
+	}
+	_, err := uuid.Parse(name)
+	return err == nil && len(name) == 36
+}
+
+func containsId(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
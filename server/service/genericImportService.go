@@ -24,6 +24,10 @@ func GenericParseRefuelings(content []models.ImportFillup, user *db.User, vehicl
 			missedFillup = *record.HasMissedFillup
 		}
 
+		if err := checkLocation(record.Latitude, record.Longitude); err != nil {
+			errors = append(errors, err.Error())
+		}
+
 		fillups = append(fillups, db.Fillup{
 			VehicleID:       vehicle.ID,
 			UserID:          user.ID,
@@ -39,6 +43,9 @@ func GenericParseRefuelings(content []models.ImportFillup, user *db.User, vehicl
 			Currency:        user.Currency,
 			DistanceUnit:    user.DistanceUnit,
 			Comments:        record.Comments,
+			Latitude:        record.Latitude,
+			Longitude:       record.Longitude,
+			Address:         record.Address,
 			Source:          "Generic Import",
 		})
 	}
@@ -11,6 +11,18 @@ import (
 
 func WriteToDB(fillups []db.Fillup, expenses []db.Expense) []string {
 	var errors []string
+	if err := resolveImportedExpenseCategories(expenses); err != nil {
+		errors = append(errors, err.Error())
+		return errors
+	}
+	if err := resolveImportedFillingStations(fillups); err != nil {
+		errors = append(errors, err.Error())
+		return errors
+	}
+	if err := toImportedVehicleDistanceUnit(fillups, expenses); err != nil {
+		errors = append(errors, err.Error())
+		return errors
+	}
 	tx := db.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -43,6 +55,40 @@ func WriteToDB(fillups []db.Fillup, expenses []db.Expense) []string {
 
 }
 
+// toImportedVehicleDistanceUnit converts imported odometer readings, which are
+// in the importing user's unit, into the unit of the vehicle they belong to.
+func toImportedVehicleDistanceUnit(fillups []db.Fillup, expenses []db.Expense) error {
+	vehicleUnits := make(map[uuid.UUID]db.DistanceUnit)
+	vehicleUnit := func(vehicleId uuid.UUID) (db.DistanceUnit, error) {
+		if unit, ok := vehicleUnits[vehicleId]; ok {
+			return unit, nil
+		}
+		unit, err := db.GetVehicleDistanceUnit(vehicleId)
+		if err != nil {
+			return 0, err
+		}
+		vehicleUnits[vehicleId] = unit
+		return unit, nil
+	}
+	for i := range fillups {
+		unit, err := vehicleUnit(fillups[i].VehicleID)
+		if err != nil {
+			return err
+		}
+		fillups[i].OdoReading = db.ConvertDistance(fillups[i].OdoReading, fillups[i].DistanceUnit, unit)
+		fillups[i].DistanceUnit = unit
+	}
+	for i := range expenses {
+		unit, err := vehicleUnit(expenses[i].VehicleID)
+		if err != nil {
+			return err
+		}
+		expenses[i].OdoReading = db.ConvertDistance(expenses[i].OdoReading, expenses[i].DistanceUnit, unit)
+		expenses[i].DistanceUnit = unit
+	}
+	return nil
+}
+
 func DrivvoImport(content []byte, userId uuid.UUID, vehicleId uuid.UUID, importLocation bool) []string {
 	var errors []string
 	user, err := GetUserById(userId)
@@ -80,13 +126,13 @@ func DrivvoImport(content []byte, userId uuid.UUID, vehicleId uuid.UUID, importL
 	fillups, errors = DrivvoParseRefuelings(content[:refuelEndIndex], user, vehicle, importLocation)
 
 	var allExpenses []db.Expense
-	services, parseErrors := DrivvoParseExpenses(content[refuelEndIndex:serviceEndIndex], user, vehicle)
+	services, parseErrors := DrivvoParseExpenses(content[refuelEndIndex:serviceEndIndex], user, vehicle, importLocation)
 	if parseErrors != nil {
 		errors = append(errors, parseErrors...)
 	}
 	allExpenses = append(allExpenses, services...)
 
-	expenses, parseErrors := DrivvoParseExpenses(content[serviceEndIndex:endParseIndex], user, vehicle)
+	expenses, parseErrors := DrivvoParseExpenses(content[serviceEndIndex:endParseIndex], user, vehicle, importLocation)
 	if parseErrors != nil {
 		errors = append(errors, parseErrors...)
 	}
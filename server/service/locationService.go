@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+)
+
+func GetFillupLocationsForVehicle(vehicleId uuid.UUID, model models.FillupLocationQueryModel) (*models.GeoJSONFeatureCollection, error) {
+	vehicle, err := db.GetVehicleById(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	return getFillupLocations([]db.Vehicle{*vehicle}, model)
+}
+
+func GetFillupLocationsForUser(userId uuid.UUID, model models.FillupLocationQueryModel) (*models.GeoJSONFeatureCollection, error) {
+	vehicles, err := GetUserVehicles(userId, model.IncludeInactive)
+	if err != nil {
+		return nil, err
+	}
+	return getFillupLocations(*vehicles, model)
+}
+
+func getFillupLocations(vehicles []db.Vehicle, model models.FillupLocationQueryModel) (*models.GeoJSONFeatureCollection, error) {
+	nicknames := make(map[uuid.UUID]string)
+	vehicleIds := make([]uuid.UUID, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		nicknames[vehicle.ID] = vehicle.Nickname
+		vehicleIds = append(vehicleIds, vehicle.ID)
+	}
+	end := model.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	collection := models.GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []models.GeoJSONFeature{},
+	}
+	if len(vehicleIds) == 0 {
+		return &collection, nil
+	}
+	fillups, err := db.FindLocatedFillupsForDateRange(vehicleIds, model.Start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, fillup := range *fillups {
+		collection.Features = append(collection.Features, models.GeoJSONFeature{
+			Type: "Feature",
+			Geometry: models.GeoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{*fillup.Longitude, *fillup.Latitude},
+			},
+			Properties: models.FillupLocationProperties{
+				FillupID:         fillup.ID,
+				VehicleID:        fillup.VehicleID,
+				VehicleNickname:  nicknames[fillup.VehicleID],
+				Date:             fillup.Date,
+				FillingStation:   fillup.FillingStation,
+				FillingStationID: fillup.FillingStationID,
+				Address:          fillup.Address,
+				FuelUnit:         fillup.FuelUnit,
+				FuelSubType:      fillup.FuelSubType,
+				FuelQuantity:     fillup.FuelQuantity,
+				PerUnitPrice:     fillup.PerUnitPrice,
+				TotalAmount:      fillup.TotalAmount,
+				Currency:         fillup.Currency,
+			},
+		})
+	}
+	return &collection, nil
+}
+
+func checkLocation(latitude, longitude *float64) error {
+	if (latitude == nil) != (longitude == nil) {
+		return errors.New("latitude and longitude must be set together")
+	}
+	if latitude == nil {
+		return nil
+	}
+	if *latitude < -90 || *latitude > 90 {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if *longitude < -180 || *longitude > 180 {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// parseImportedLocation reads the location column of an import. Exports keep
+// either a "lat,lng" pair or a free text address there, so anything which is
+// not a valid pair of coordinates is returned as the address.
+func parseImportedLocation(location string) (latitude, longitude *float64, address string) {
+	location = strings.TrimSpace(location)
+	parts := strings.Split(location, ",")
+	if len(parts) == 2 {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if latErr == nil && lngErr == nil && checkLocation(&lat, &lng) == nil {
+			return &lat, &lng, ""
+		}
+	}
+	return nil, nil, location
+}
@@ -23,10 +23,20 @@ func UpdateUserSettings(userId uuid.UUID, currency string, distanceUnit db.Dista
 		return err
 	}
 
+	before := *user
 	user.Currency = currency
 	user.DistanceUnit = distanceUnit
 	user.DateFormat = dateFormat
-	return db.UpdateUser(user)
+	tx := db.DB.Begin()
+	if err := db.UpdateUser(tx, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, userId, db.UPDATE_ACTION, db.AUDIT_USER, userId, nil, &before, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
 func GetSettings() *db.Setting {
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+func GetOdometerReplacementsByVehicleId(vehicleId uuid.UUID) (*[]db.OdometerReplacement, error) {
+	return db.GetOdometerReplacementsByVehicleId(vehicleId)
+}
+
+func GetOdometerReplacementById(vehicleId, replacementId uuid.UUID) (*db.OdometerReplacement, error) {
+	return getVehicleOdometerReplacement(vehicleId, replacementId)
+}
+
+func CreateOdometerReplacement(vehicleId uuid.UUID, model models.OdometerReplacementRequest) (*db.OdometerReplacement, error) {
+	if _, err := db.GetVehicleDistanceUnit(vehicleId); err != nil {
+		return nil, err
+	}
+	replacement := db.OdometerReplacement{
+		VehicleID:      vehicleId,
+		Date:           model.Date,
+		LastOdoReading: model.LastOdoReading,
+		NewOdoReading:  model.NewOdoReading,
+		Comments:       model.Comments,
+	}
+	tx := db.DB.Create(&replacement)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &replacement, nil
+}
+
+func UpdateOdometerReplacement(vehicleId, replacementId uuid.UUID, model models.OdometerReplacementRequest) error {
+	toUpdate, err := getVehicleOdometerReplacement(vehicleId, replacementId)
+	if err != nil {
+		return err
+	}
+	toUpdate.Date = model.Date
+	toUpdate.LastOdoReading = model.LastOdoReading
+	toUpdate.NewOdoReading = model.NewOdoReading
+	toUpdate.Comments = model.Comments
+	return db.DB.Omit(clause.Associations).Save(toUpdate).Error
+}
+
+func DeleteOdometerReplacement(vehicleId, replacementId uuid.UUID) error {
+	if _, err := getVehicleOdometerReplacement(vehicleId, replacementId); err != nil {
+		return err
+	}
+	return db.DB.Where("id = ?", replacementId).Unscoped().Delete(&db.OdometerReplacement{}).Error
+}
+
+func getVehicleOdometerReplacement(vehicleId, replacementId uuid.UUID) (*db.OdometerReplacement, error) {
+	replacement, err := db.GetOdometerReplacementById(replacementId)
+	if err != nil {
+		return nil, err
+	}
+	if replacement.VehicleID != vehicleId {
+		return nil, errors.New("odometer replacement does not belong to this vehicle")
+	}
+	return replacement, nil
+}
+
+// odometer maps the readings of a vehicle, which may jump backwards when its
+// odometer is replaced or rolls over, onto a continuous true distance.
+type odometer []db.OdometerReplacement
+
+func getOdometer(vehicleId uuid.UUID) (odometer, error) {
+	replacements, err := db.GetOdometerReplacementsByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	return odometer(*replacements), nil
+}
+
+// trueDistance adds the offsets of every replacement made before the reading.
+// A reading from the day of a replacement may have been taken on either
+// odometer, so it is put after the replacement when it is closer to the
+// reading the new odometer started at than to the last one of the old.
+func (o odometer) trueDistance(odoReading int, date time.Time) int {
+	offset := 0
+	for _, replacement := range o {
+		if sameDay(replacement.Date, date) {
+			if absDistance(odoReading, replacement.NewOdoReading) >= absDistance(odoReading, replacement.LastOdoReading) {
+				break
+			}
+		} else if replacement.Date.After(date) {
+			break
+		}
+		offset += replacement.Offset()
+	}
+	return odoReading + offset
+}
+
+func sameDay(a, b time.Time) bool {
+	a, b = a.UTC(), b.UTC()
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func absDistance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// odometers caches the odometer of each vehicle met while going over entries
+// of possibly several vehicles.
+type odometers map[uuid.UUID]odometer
+
+func (o odometers) get(vehicleId uuid.UUID) (odometer, error) {
+	if found, ok := o[vehicleId]; ok {
+		return found, nil
+	}
+	found, err := getOdometer(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	o[vehicleId] = found
+	return found, nil
+}
+
+func SetFillupsTrueOdoReading(fillups []db.Fillup) error {
+	cache := odometers{}
+	for i := range fillups {
+		odo, err := cache.get(fillups[i].VehicleID)
+		if err != nil {
+			return err
+		}
+		fillups[i].TrueOdoReading = odo.trueDistance(fillups[i].OdoReading, fillups[i].Date)
+	}
+	return nil
+}
+
+func SetExpensesTrueOdoReading(expenses []db.Expense) error {
+	cache := odometers{}
+	for i := range expenses {
+		odo, err := cache.get(expenses[i].VehicleID)
+		if err != nil {
+			return err
+		}
+		expenses[i].TrueOdoReading = odo.trueDistance(expenses[i].OdoReading, expenses[i].Date)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOdometerTrueDistance(t *testing.T) {
+	day := func(d int, hour int) time.Time {
+		return time.Date(2026, time.March, d, hour, 0, 0, 0, time.UTC)
+	}
+	// swapped on the 10th at 150000 for one starting at 0, then rolled over
+	// at 99999 on the 20th
+	odo := odometer{
+		{Date: day(10, 0), LastOdoReading: 150000, NewOdoReading: 0},
+		{Date: day(20, 12), LastOdoReading: 99999, NewOdoReading: 0},
+	}
+	tests := []struct {
+		name       string
+		odoReading int
+		date       time.Time
+		want       int
+	}{
+		{"before any replacement", 149000, day(9, 8), 149000},
+		{"replacement day, old odometer", 149950, day(10, 8), 149950},
+		{"replacement day, new odometer", 30, day(10, 18), 150030},
+		{"between replacements", 5000, day(15, 8), 155000},
+		{"rollover day, before it", 99990, day(20, 8), 249990},
+		{"rollover day, after it", 12, day(20, 18), 250011},
+		{"after every replacement", 800, day(25, 8), 250799},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := odo.trueDistance(test.odoReading, test.date); got != test.want {
+				t.Fatalf("trueDistance(%d, %s) = %d, want %d", test.odoReading, test.date.Format(time.DateOnly), got, test.want)
+			}
+		})
+	}
+}
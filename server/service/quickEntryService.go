@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"hammond/common"
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// nearbyStationDistance is how far, in kilometres, a filling station may be
+// from where a photo was taken to be offered for it.
+const nearbyStationDistance = 1.0
+
+// ConvertQuickEntry turns a quick entry into the fillup or the expense it was
+// taken for. The photo of the quick entry is attached to the new record and the
+// quick entry is marked as processed in the same transaction.
+func ConvertQuickEntry(userId, quickEntryId uuid.UUID, model models.ConvertQuickEntryRequest) (*models.ConvertQuickEntryModel, error) {
+	if (model.Fillup == nil) == (model.Expense == nil) {
+		return nil, errors.New("either a fillup or an expense is needed")
+	}
+	quickEntry, err := db.GetQuickEntryById(quickEntryId)
+	if err != nil {
+		return nil, err
+	}
+	if quickEntry.ProcessDate != nil {
+		return nil, errors.New("this quick entry has been processed already")
+	}
+	if err := checkQuickEntryAccess(userId, quickEntry); err != nil {
+		return nil, err
+	}
+	title := model.Title
+	if title == "" {
+		title = quickEntry.Comments
+	}
+	if title == "" {
+		title = quickEntry.Attachment.OriginalName
+	}
+
+	converted := models.ConvertQuickEntryModel{QuickEntryID: quickEntry.ID}
+	if model.Fillup != nil {
+		if err := checkQuickEntryVehicle(userId, model.Fillup.VehicleID); err != nil {
+			return nil, err
+		}
+		if err := applyQuickEntryLocation(quickEntry, model.Fillup); err != nil {
+			return nil, err
+		}
+		fillup, customFields, err := prepareFillup(*model.Fillup, userId)
+		if err != nil {
+			return nil, err
+		}
+		fillup.Source = "Quick Entry"
+		err = processQuickEntry(quickEntry, func(tx *gorm.DB) error {
+			if err := insertFillup(tx, fillup, model.Fillup.Tags, customFields); err != nil {
+				return err
+			}
+			err := tx.Create(&db.FillupAttachment{
+				FillupID:     fillup.ID,
+				AttachmentID: quickEntry.AttachmentID,
+				Title:        title,
+			}).Error
+			if err != nil {
+				return err
+			}
+			converted.Fillup, err = auditCreatedFillup(tx, fillup.ID, userId)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &converted, nil
+	}
+
+	if err := checkQuickEntryVehicle(userId, model.Expense.VehicleID); err != nil {
+		return nil, err
+	}
+	expense, customFields, err := prepareExpense(*model.Expense, userId)
+	if err != nil {
+		return nil, err
+	}
+	expense.Source = "Quick Entry"
+	err = processQuickEntry(quickEntry, func(tx *gorm.DB) error {
+		if err := insertExpense(tx, expense, model.Expense.Tags, customFields); err != nil {
+			return err
+		}
+		err := tx.Create(&db.ExpenseAttachment{
+			ExpenseID:    expense.ID,
+			AttachmentID: quickEntry.AttachmentID,
+			Title:        title,
+		}).Error
+		if err != nil {
+			return err
+		}
+		converted.Expense, err = auditCreatedExpense(tx, expense.ID, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &converted, nil
+}
+
+// processQuickEntry writes the record made from a quick entry and marks the
+// quick entry as processed, or does neither. The quick entry is claimed first,
+// so a second conversion of it fails before writing anything.
+func processQuickEntry(quickEntry *db.QuickEntry, create func(tx *gorm.DB) error) error {
+	tx := db.DB.Begin()
+	if err := db.SetQuickEntryAsProcessed(tx, quickEntry.ID, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := create(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func checkQuickEntryVehicle(userId, vehicleId uuid.UUID) error {
+	canAccess, err := canAccessVehicle(userId, vehicleId)
+	if err != nil {
+		return err
+	}
+	if !canAccess {
+		return errors.New("you are not allowed to add entries to this vehicle")
+	}
+	return nil
+}
+
+func checkQuickEntryAccess(userId uuid.UUID, quickEntry *db.QuickEntry) error {
+	if quickEntry.UserID == userId {
+		return nil
+	}
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.Role != db.ADMIN {
+		return errors.New("you are not allowed to process this quick entry")
+	}
+	return nil
+}
+
+// GetQuickEntryFillupDefaults offers what the photo of a quick entry tells
+// about the fillup it was taken for: when, where and at which station.
+func GetQuickEntryFillupDefaults(userId, quickEntryId uuid.UUID) (*models.QuickEntryFillupDefaultsModel, error) {
+	quickEntry, err := db.GetQuickEntryById(quickEntryId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuickEntryAccess(userId, quickEntry); err != nil {
+		return nil, err
+	}
+	defaults := models.QuickEntryFillupDefaultsModel{
+		Date:      quickEntry.CaptureDate,
+		Latitude:  quickEntry.Latitude,
+		Longitude: quickEntry.Longitude,
+		Comments:  quickEntry.Comments,
+	}
+	station, distance, err := nearestFillingStation(quickEntry)
+	if err != nil || station == nil {
+		return &defaults, err
+	}
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	userDistance := float32(distance)
+	if user.DistanceUnit == db.MILES {
+		userDistance = common.KmToMiles(userDistance)
+	}
+	defaults.FillingStation = &models.FillingStationSuggestionModel{
+		Station:      *station,
+		Distance:     &userDistance,
+		DistanceUnit: user.DistanceUnit,
+		Score:        1 / (1 + distance/2),
+	}
+	return &defaults, nil
+}
+
+// nearestFillingStation finds the station closest to where the photo of a
+// quick entry was taken, if there is one close enough, along with its distance
+// in kilometres.
+func nearestFillingStation(quickEntry *db.QuickEntry) (*db.FillingStation, float64, error) {
+	if quickEntry.Latitude == nil || quickEntry.Longitude == nil {
+		return nil, 0, nil
+	}
+	stations, err := db.GetAllFillingStations()
+	if err != nil {
+		return nil, 0, err
+	}
+	var nearest *db.FillingStation
+	nearestDistance := nearbyStationDistance
+	for i, station := range *stations {
+		if station.Latitude == nil || station.Longitude == nil {
+			continue
+		}
+		distance := haversineDistance(*quickEntry.Latitude, *quickEntry.Longitude, *station.Latitude, *station.Longitude)
+		if distance <= nearestDistance {
+			nearest = &(*stations)[i]
+			nearestDistance = distance
+		}
+	}
+	return nearest, nearestDistance, nil
+}
+
+// applyQuickEntryLocation defaults the location and the station of a fillup
+// made from a quick entry to those of its photo.
+func applyQuickEntryLocation(quickEntry *db.QuickEntry, model *models.CreateFillupRequest) error {
+	if model.Latitude == nil && model.Longitude == nil {
+		model.Latitude = quickEntry.Latitude
+		model.Longitude = quickEntry.Longitude
+	}
+	if model.FillingStationID != nil || model.FillingStation != "" {
+		return nil
+	}
+	station, _, err := nearestFillingStation(quickEntry)
+	if err != nil || station == nil {
+		return err
+	}
+	model.FillingStationID = &station.ID
+	return nil
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+const recurringExpenseJob = "CreateDueRecurringExpenses"
+
+func CreateRecurringExpense(vehicleId, userId uuid.UUID, model models.CreateRecurringExpenseRequest) (*db.RecurringExpense, error) {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	recurringExpense := db.RecurringExpense{
+		VehicleID: vehicleId,
+		UserID:    userId,
+		Currency:  user.Currency,
+		IsActive:  true,
+	}
+	if err := setRecurringExpense(&recurringExpense, model); err != nil {
+		return nil, err
+	}
+	recurringExpense.NextDate = nextRecurringExpenseDate(&recurringExpense)
+
+	tx := db.DB.Create(&recurringExpense)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &recurringExpense, nil
+}
+
+// UpdateRecurringExpense edits a schedule. Occurrences that were already
+// handled are never generated again, even if the new schedule moves them, and
+// a schedule that is resumed does not catch up on the dates it was paused for.
+func UpdateRecurringExpense(vehicleId, recurringExpenseId uuid.UUID, model models.UpdateRecurringExpenseRequest) error {
+	toUpdate, err := getVehicleRecurringExpense(vehicleId, recurringExpenseId)
+	if err != nil {
+		return err
+	}
+	var lastHandled *time.Time
+	if toUpdate.GeneratedCount > 0 {
+		date := recurringExpenseDate(toUpdate, toUpdate.GeneratedCount-1)
+		lastHandled = &date
+	}
+	wasActive := toUpdate.IsActive
+
+	if err := setRecurringExpense(toUpdate, model.CreateRecurringExpenseRequest); err != nil {
+		return err
+	}
+	toUpdate.GeneratedCount = 0
+	if lastHandled != nil {
+		for !recurringExpenseDate(toUpdate, toUpdate.GeneratedCount).After(*lastHandled) {
+			toUpdate.GeneratedCount++
+		}
+	}
+	if toUpdate.IsActive && !wasActive {
+		today := truncateToDay(time.Now())
+		for recurringExpenseDate(toUpdate, toUpdate.GeneratedCount).Before(today) {
+			toUpdate.GeneratedCount++
+		}
+	}
+	toUpdate.NextDate = nextRecurringExpenseDate(toUpdate)
+	return db.DB.Omit(clause.Associations).Save(toUpdate).Error
+}
+
+func GetRecurringExpensesByVehicleId(vehicleId uuid.UUID) (*[]db.RecurringExpense, error) {
+	return db.GetRecurringExpensesByVehicleId(vehicleId)
+}
+
+func GetRecurringExpenseById(vehicleId, recurringExpenseId uuid.UUID) (*db.RecurringExpense, error) {
+	return getVehicleRecurringExpense(vehicleId, recurringExpenseId)
+}
+
+func DeleteRecurringExpense(vehicleId, recurringExpenseId uuid.UUID) error {
+	if _, err := getVehicleRecurringExpense(vehicleId, recurringExpenseId); err != nil {
+		return err
+	}
+	return db.DeleteRecurringExpenseById(recurringExpenseId)
+}
+
+// GetRecurringExpenseSchedule lists the occurrences handled so far followed by
+// the next count upcoming ones, with any skip or adjustment applied.
+func GetRecurringExpenseSchedule(vehicleId, recurringExpenseId uuid.UUID, count int) (*[]models.RecurringExpenseOccurrenceModel, error) {
+	recurringExpense, err := getVehicleRecurringExpense(vehicleId, recurringExpenseId)
+	if err != nil {
+		return nil, err
+	}
+	occurrences, err := db.GetRecurringExpenseOccurrences(recurringExpenseId)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		count = 5
+	}
+
+	schedule := []models.RecurringExpenseOccurrenceModel{}
+	for _, occurrence := range *occurrences {
+		if occurrence.ExpenseID != nil || (occurrence.IsSkipped && !isUpcomingOccurrence(recurringExpense, occurrence.Date)) {
+			schedule = append(schedule, toRecurringExpenseOccurrenceModel(recurringExpense, &occurrence, occurrence.Date))
+		}
+	}
+	for i := recurringExpense.GeneratedCount; i < recurringExpense.GeneratedCount+count; i++ {
+		date := recurringExpenseDate(recurringExpense, i)
+		if recurringExpense.EndDate != nil && date.After(*recurringExpense.EndDate) {
+			break
+		}
+		schedule = append(schedule, toRecurringExpenseOccurrenceModel(recurringExpense, findRecurringExpenseOccurrence(occurrences, date), date))
+	}
+	return &schedule, nil
+}
+
+// SetRecurringExpenseOccurrence skips or adjusts a single instance. An instance
+// that is already generated is changed through its expense, an upcoming one is
+// remembered until the job reaches it.
+func SetRecurringExpenseOccurrence(vehicleId, recurringExpenseId uuid.UUID, model models.RecurringExpenseOccurrenceRequest) error {
+	recurringExpense, err := getVehicleRecurringExpense(vehicleId, recurringExpenseId)
+	if err != nil {
+		return err
+	}
+	date := truncateToDay(model.Date)
+	if !isRecurringExpenseDate(recurringExpense, date) {
+		return fmt.Errorf("%s is not a date on which this expense recurs", date.Format("2006-01-02"))
+	}
+	occurrences, err := db.GetRecurringExpenseOccurrences(recurringExpenseId)
+	if err != nil {
+		return err
+	}
+	occurrence := findRecurringExpenseOccurrence(occurrences, date)
+	if occurrence == nil {
+		if !isUpcomingOccurrence(recurringExpense, date) {
+			return errors.New("this occurrence has already been handled")
+		}
+		occurrence = &db.RecurringExpenseOccurrence{
+			RecurringExpenseID: recurringExpenseId,
+			Date:               date,
+		}
+	}
+
+	if occurrence.ExpenseID != nil {
+		if model.IsSkipped {
+			// removing the expense marks the occurrence as skipped
+			return db.PurgeExpenses(db.DB, []uuid.UUID{*occurrence.ExpenseID})
+		}
+		updates := map[string]interface{}{"comments": model.Comments}
+		if model.Amount != nil {
+			updates["amount"] = *model.Amount
+		}
+		if err := db.DB.Model(&db.Expense{}).Where("id = ?", *occurrence.ExpenseID).Updates(updates).Error; err != nil {
+			return err
+		}
+	} else if !isUpcomingOccurrence(recurringExpense, date) && !model.IsSkipped {
+		return errors.New("a skipped occurrence that has passed cannot be restored")
+	}
+
+	occurrence.IsSkipped = model.IsSkipped
+	occurrence.Amount = model.Amount
+	occurrence.Comments = model.Comments
+	if occurrence.ID == uuid.Nil {
+		return db.DB.Create(occurrence).Error
+	}
+	return db.DB.Save(occurrence).Error
+}
+
+// CreateDueRecurringExpenses creates the expenses of every recurring expense
+// that has fallen due. It is run periodically by the scheduler.
+func CreateDueRecurringExpenses() {
+	if !db.GetLock(recurringExpenseJob).Date.IsZero() {
+		return
+	}
+	db.Lock(recurringExpenseJob, 10)
+	defer db.Unlock(recurringExpenseJob)
+
+	now := time.Now()
+	recurringExpenses, err := db.GetDueRecurringExpenses(now)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	for i := range *recurringExpenses {
+		if err := generateRecurringExpenses(&(*recurringExpenses)[i], now); err != nil {
+			fmt.Printf("could not create recurring expense '%s': %s\n", (*recurringExpenses)[i].Title, err)
+		}
+	}
+}
+
+func generateRecurringExpenses(recurringExpense *db.RecurringExpense, now time.Time) error {
+	distanceUnit, err := db.GetVehicleDistanceUnit(recurringExpense.VehicleID)
+	if err != nil {
+		return err
+	}
+	occurrences, err := db.GetRecurringExpenseOccurrences(recurringExpense.ID)
+	if err != nil {
+		return err
+	}
+
+	tx := db.DB.Begin()
+	for recurringExpense.NextDate != nil && !recurringExpense.NextDate.After(now) {
+		date := truncateToDay(*recurringExpense.NextDate)
+		occurrence := findRecurringExpenseOccurrence(occurrences, date)
+		if occurrence == nil {
+			occurrence = &db.RecurringExpenseOccurrence{
+				RecurringExpenseID: recurringExpense.ID,
+				Date:               date,
+			}
+		}
+		if !occurrence.IsSkipped {
+			expense := db.Expense{
+				VehicleID:          recurringExpense.VehicleID,
+				UserID:             recurringExpense.UserID,
+				Amount:             recurringExpense.Amount,
+				ExpenseType:        recurringExpense.ExpenseType,
+				ExpenseCategoryID:  recurringExpense.ExpenseCategoryID,
+				Vendor:             recurringExpense.Vendor,
+				Comments:           recurringExpense.Comments,
+				Date:               date,
+				Currency:           recurringExpense.Currency,
+				DistanceUnit:       distanceUnit,
+				Source:             "Recurring",
+				RecurringExpenseID: &recurringExpense.ID,
+			}
+			if occurrence.Amount != nil {
+				expense.Amount = *occurrence.Amount
+			}
+			if occurrence.Comments != "" {
+				expense.Comments = occurrence.Comments
+			}
+			if err := tx.Create(&expense).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			occurrence.ExpenseID = &expense.ID
+		}
+		if occurrence.ID == uuid.Nil {
+			err = tx.Create(occurrence).Error
+		} else {
+			err = tx.Save(occurrence).Error
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		recurringExpense.GeneratedCount++
+		recurringExpense.NextDate = nextRecurringExpenseDate(recurringExpense)
+	}
+	if err := tx.Omit(clause.Associations).Save(recurringExpense).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func setRecurringExpense(recurringExpense *db.RecurringExpense, model models.CreateRecurringExpenseRequest) error {
+	interval := model.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	if interval < 0 {
+		return errors.New("interval should be a positive number")
+	}
+	startDate := truncateToDay(model.StartDate)
+	var endDate *time.Time
+	if model.EndDate != nil {
+		date := truncateToDay(*model.EndDate)
+		if date.Before(startDate) {
+			return errors.New("endDate should not be before startDate")
+		}
+		endDate = &date
+	}
+	category, err := resolveExpenseCategory(model.ExpenseCategoryID, model.ExpenseType)
+	if err != nil {
+		return err
+	}
+
+	recurringExpense.Title = model.Title
+	recurringExpense.Amount = model.Amount
+	recurringExpense.ExpenseType = model.ExpenseType
+	recurringExpense.ExpenseCategoryID = nil
+	if category != nil {
+		recurringExpense.ExpenseCategoryID = &category.ID
+		recurringExpense.ExpenseType = category.Name
+	}
+	recurringExpense.Vendor = model.Vendor
+	recurringExpense.Comments = model.Comments
+	recurringExpense.Frequency = *model.Frequency
+	recurringExpense.Interval = interval
+	recurringExpense.StartDate = startDate
+	recurringExpense.EndDate = endDate
+	if model.IsActive != nil {
+		recurringExpense.IsActive = *model.IsActive
+	}
+	return nil
+}
+
+func getVehicleRecurringExpense(vehicleId, recurringExpenseId uuid.UUID) (*db.RecurringExpense, error) {
+	recurringExpense, err := db.GetRecurringExpenseById(recurringExpenseId)
+	if err != nil {
+		return nil, err
+	}
+	if recurringExpense.VehicleID != vehicleId {
+		return nil, errors.New("recurring expense does not belong to this vehicle")
+	}
+	return recurringExpense, nil
+}
+
+// recurringExpenseDate returns the date of the n-th occurrence, counting from
+// zero. Monthly dates that do not exist, like the 31st of April, fall back to
+// the last day of the month.
+func recurringExpenseDate(recurringExpense *db.RecurringExpense, n int) time.Time {
+	start := recurringExpense.StartDate
+	switch recurringExpense.Frequency {
+	case db.WEEKLY:
+		return start.AddDate(0, 0, 7*recurringExpense.Interval*n)
+	case db.YEARLY:
+		return addMonths(start, 12*recurringExpense.Interval*n)
+	default:
+		return addMonths(start, recurringExpense.Interval*n)
+	}
+}
+
+func nextRecurringExpenseDate(recurringExpense *db.RecurringExpense) *time.Time {
+	date := recurringExpenseDate(recurringExpense, recurringExpense.GeneratedCount)
+	if recurringExpense.EndDate != nil && date.After(*recurringExpense.EndDate) {
+		return nil
+	}
+	return &date
+}
+
+func isRecurringExpenseDate(recurringExpense *db.RecurringExpense, date time.Time) bool {
+	if date.Before(recurringExpense.StartDate) || (recurringExpense.EndDate != nil && date.After(*recurringExpense.EndDate)) {
+		return false
+	}
+	for i := 0; ; i++ {
+		current := recurringExpenseDate(recurringExpense, i)
+		if current.Equal(date) {
+			return true
+		}
+		if current.After(date) {
+			return false
+		}
+	}
+}
+
+func isUpcomingOccurrence(recurringExpense *db.RecurringExpense, date time.Time) bool {
+	return recurringExpense.NextDate != nil && !date.Before(*recurringExpense.NextDate)
+}
+
+func findRecurringExpenseOccurrence(occurrences *[]db.RecurringExpenseOccurrence, date time.Time) *db.RecurringExpenseOccurrence {
+	for i := range *occurrences {
+		if truncateToDay((*occurrences)[i].Date).Equal(date) {
+			return &(*occurrences)[i]
+		}
+	}
+	return nil
+}
+
+func toRecurringExpenseOccurrenceModel(recurringExpense *db.RecurringExpense, occurrence *db.RecurringExpenseOccurrence, date time.Time) models.RecurringExpenseOccurrenceModel {
+	model := models.RecurringExpenseOccurrenceModel{
+		Date:     date,
+		Amount:   recurringExpense.Amount,
+		Comments: recurringExpense.Comments,
+	}
+	if occurrence != nil {
+		model.IsSkipped = occurrence.IsSkipped
+		model.IsGenerated = occurrence.ExpenseID != nil
+		model.ExpenseID = occurrence.ExpenseID
+		if occurrence.Amount != nil {
+			model.Amount = *occurrence.Amount
+		}
+		if occurrence.Comments != "" {
+			model.Comments = occurrence.Comments
+		}
+	}
+	return model
+}
+
+func addMonths(date time.Time, months int) time.Time {
+	year, month, day := date.Date()
+	first := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, date.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, date.Location())
+}
+
+func truncateToDay(date time.Time) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
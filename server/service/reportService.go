@@ -11,7 +11,79 @@ import (
 	"github.com/google/uuid"
 )
 
-func GetMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption string) (mileage []models.MileageModel, err error) {
+// mileageEntry is a row of the mileage report along with the distance and the
+// fuel it was worked out from, both converted to the units of the report.
+type mileageEntry struct {
+	mileage      models.MileageModel
+	distance     float32
+	fuelQuantity float32
+	distanceUnit db.DistanceUnit
+	isComputed   bool
+	tags         []db.Tag
+}
+
+func GetMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption string, tags []string) (mileage []models.MileageModel, err error) {
+	entries, err := getMileageEntries(vehicleId, since, mileageOption)
+	if err != nil {
+		return nil, err
+	}
+	mileages := make([]models.MileageModel, 0, len(entries))
+	for _, entry := range entries {
+		if hasAllTags(entry.tags, tags) {
+			mileages = append(mileages, entry.mileage)
+		}
+	}
+	return mileages, nil
+}
+
+// GetTagMileageByVehicleId compares the mileage of the fills carrying each tag
+// with that of the fills carrying none.
+func GetTagMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption string) ([]models.TagMileageModel, error) {
+	entries, err := getMileageEntries(vehicleId, since, mileageOption)
+	if err != nil {
+		return nil, err
+	}
+	byTag := make(map[string]*models.TagMileageModel)
+	var names []string
+	add := func(name string, entry mileageEntry) {
+		model, ok := byTag[name]
+		if !ok {
+			model = &models.TagMileageModel{Tag: name, DistanceUnit: entry.distanceUnit}
+			byTag[name] = model
+			names = append(names, name)
+		}
+		model.CountFillups++
+		model.Distance += entry.distance
+		model.FuelQuantity += entry.fuelQuantity
+	}
+	for _, entry := range entries {
+		if !entry.isComputed {
+			continue
+		}
+		if len(entry.tags) == 0 {
+			add("", entry)
+		}
+		for _, tag := range entry.tags {
+			add(tag.Name, entry)
+		}
+	}
+	sort.Strings(names)
+	toReturn := make([]models.TagMileageModel, 0, len(names))
+	for _, name := range names {
+		model := byTag[name]
+		if model.Distance > 0 && model.FuelQuantity > 0 {
+			if mileageOption == "litre_100km" {
+				model.Mileage = model.FuelQuantity / model.Distance * 100
+			} else {
+				model.Mileage = model.Distance / model.FuelQuantity
+			}
+		}
+		toReturn = append(toReturn, *model)
+	}
+	return toReturn, nil
+}
+
+func getMileageEntries(vehicleId uuid.UUID, since time.Time, mileageOption string) ([]mileageEntry, error) {
 	data, err := db.GetFillupsByVehicleIdSince(vehicleId, since)
 	if err != nil {
 		return nil, err
@@ -19,11 +91,14 @@ func GetMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption s
 
 	fillups := make([]db.Fillup, len(*data))
 	copy(fillups, *data)
+	if err := SetFillupsTrueOdoReading(fillups); err != nil {
+		return nil, err
+	}
 	sort.Slice(fillups, func(i, j int) bool {
-		return fillups[i].OdoReading > fillups[j].OdoReading
+		return fillups[i].TrueOdoReading > fillups[j].TrueOdoReading
 	})
 
-	var mileages []models.MileageModel
+	var entries []mileageEntry
 
 	for i := 0; i < len(fillups)-1; i++ {
 		last := i + 1
@@ -32,27 +107,34 @@ func GetMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption s
 		lastFillup := fillups[last]
 
 		mileage := models.MileageModel{
-			Date:         currentFillup.Date,
-			VehicleID:    currentFillup.VehicleID,
-			FuelUnit:     currentFillup.FuelUnit,
-			FuelQuantity: currentFillup.FuelQuantity,
-			PerUnitPrice: currentFillup.PerUnitPrice,
-			OdoReading:   currentFillup.OdoReading,
-			Currency:     currentFillup.Currency,
-			DistanceUnit: currentFillup.DistanceUnit,
-			Mileage:      0,
-			CostPerMile:  0,
+			Date:           currentFillup.Date,
+			VehicleID:      currentFillup.VehicleID,
+			FuelUnit:       currentFillup.FuelUnit,
+			FuelQuantity:   currentFillup.FuelQuantity,
+			PerUnitPrice:   currentFillup.PerUnitPrice,
+			OdoReading:     currentFillup.OdoReading,
+			TrueOdoReading: currentFillup.TrueOdoReading,
+			Currency:       currentFillup.Currency,
+			DistanceUnit:   currentFillup.DistanceUnit,
+			Mileage:        0,
+			CostPerMile:    0,
+			Tags:           tagNames(currentFillup.Tags),
+		}
+		entry := mileageEntry{
+			distanceUnit: currentFillup.DistanceUnit,
+			tags:         currentFillup.Tags,
 		}
 
 		if currentFillup.IsTankFull != nil && *currentFillup.IsTankFull && (currentFillup.HasMissedFillup == nil || !(*currentFillup.HasMissedFillup)) {
-			currentOdoReading := float32(currentFillup.OdoReading)
-			lastFillupOdoReading := float32(lastFillup.OdoReading)
+			currentOdoReading := float32(currentFillup.TrueOdoReading)
+			lastFillupOdoReading := float32(lastFillup.TrueOdoReading)
 			currentFuelQuantity := float32(currentFillup.FuelQuantity)
 			// If miles per gallon option and distanceUnit is km, convert from km to miles
 			// 	then check if fuel unit is litres. If it is, convert to gallons
 			if mileageOption == "mpg" && mileage.DistanceUnit == db.KILOMETERS {
 				currentOdoReading = common.KmToMiles(currentOdoReading)
 				lastFillupOdoReading = common.KmToMiles(lastFillupOdoReading)
+				entry.distanceUnit = db.MILES
 				if mileage.FuelUnit == db.LITRE {
 					currentFuelQuantity = common.LitreToGallon(currentFuelQuantity)
 				}
@@ -64,6 +146,7 @@ func GetMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption s
 			if (mileageOption == "km_litre" || mileageOption == "litre_100km") && mileage.DistanceUnit == db.MILES {
 				currentOdoReading = common.MilesToKm(currentOdoReading)
 				lastFillupOdoReading = common.MilesToKm(lastFillupOdoReading)
+				entry.distanceUnit = db.KILOMETERS
 
 				if mileage.FuelUnit == db.US_GALLON {
 					currentFuelQuantity = common.GallonToLitre(currentFuelQuantity)
@@ -79,12 +162,89 @@ func GetMileageByVehicleId(vehicleId uuid.UUID, since time.Time, mileageOption s
 
 			mileage.CostPerMile = distance / currentFillup.TotalAmount
 
+			entry.distance = distance
+			entry.fuelQuantity = currentFuelQuantity
+			entry.isComputed = true
 		}
 
-		mileages = append(mileages, mileage)
+		entry.mileage = mileage
+		entries = append(entries, entry)
 	}
-	if mileages == nil {
-		mileages = make([]models.MileageModel, 0)
+	return entries, nil
+}
+
+func GetChargingCostForVehicle(vehicleId uuid.UUID, model models.ChargingCostQueryModel) ([]models.ChargingCostModel, error) {
+	start, end := chargingCostRange(model)
+	fillups, err := db.FindFillupsForDateRange([]uuid.UUID{vehicleId}, start, end)
+	if err != nil {
+		return nil, err
 	}
-	return mileages, nil
+	return compareChargingCost(fillups), nil
+}
+
+func GetChargingCostForUser(userId uuid.UUID, model models.ChargingCostQueryModel) ([]models.ChargingCostModel, error) {
+	vehicles, err := GetUserVehicles(userId, model.IncludeInactive)
+	if err != nil {
+		return nil, err
+	}
+	var vehicleIds []uuid.UUID
+	for _, v := range *vehicles {
+		vehicleIds = append(vehicleIds, v.ID)
+	}
+	start, end := chargingCostRange(model)
+	fillups, err := db.FindFillupsForDateRange(vehicleIds, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return compareChargingCost(fillups), nil
+}
+
+func chargingCostRange(model models.ChargingCostQueryModel) (time.Time, time.Time) {
+	end := model.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return model.Start, end
+}
+
+// compareChargingCost splits electric fillups into home and public charging
+// per currency. The saving is what the energy charged at home would have cost
+// at the average public price, less what it actually cost.
+func compareChargingCost(fillups *[]db.Fillup) []models.ChargingCostModel {
+	byCurrency := make(map[string]*models.ChargingCostModel)
+	var currencies []string
+	for _, fillup := range *fillups {
+		if fillup.FuelUnit != db.KILOWATT_HOUR {
+			continue
+		}
+		model, ok := byCurrency[fillup.Currency]
+		if !ok {
+			model = &models.ChargingCostModel{Currency: fillup.Currency}
+			byCurrency[fillup.Currency] = model
+			currencies = append(currencies, fillup.Currency)
+		}
+		if fillup.IsHomeCharging != nil && *fillup.IsHomeCharging {
+			model.HomeSessions++
+			model.HomeQuantity += fillup.FuelQuantity
+			model.HomeCost += fillup.TotalAmount
+		} else {
+			model.PublicSessions++
+			model.PublicQuantity += fillup.FuelQuantity
+			model.PublicCost += fillup.TotalAmount
+		}
+	}
+
+	toReturn := make([]models.ChargingCostModel, 0, len(currencies))
+	for _, currency := range currencies {
+		model := byCurrency[currency]
+		if model.HomeQuantity > 0 {
+			model.HomeAvgPrice = model.HomeCost / model.HomeQuantity
+		}
+		if model.PublicQuantity > 0 {
+			model.PublicAvgPrice = model.PublicCost / model.PublicQuantity
+			model.HomeChargingSaving = model.HomeQuantity*model.PublicAvgPrice - model.HomeCost
+		}
+		toReturn = append(toReturn, *model)
+	}
+	return toReturn
 }
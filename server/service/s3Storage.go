@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Storage keeps attachments in a bucket of S3 or anything speaking its API,
+// like MinIO. It is set up by these environment variables:
+//
+//	S3_ENDPOINT    host and port of the service, like s3.amazonaws.com
+//	S3_BUCKET      the bucket, which is created when missing
+//	S3_ACCESS_KEY  the access key id
+//	S3_SECRET_KEY  the secret access key
+//	S3_REGION      the region, if the service needs one
+//	S3_USE_SSL     false to talk plain HTTP, true by default
+//	S3_PREFIX      a folder inside the bucket to keep the files in
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3StorageFromEnv() (Storage, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	bucket := os.Getenv("S3_BUCKET")
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are needed for the s3 storage")
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+		Secure: !strings.EqualFold(os.Getenv("S3_USE_SSL"), "false"),
+		Region: os.Getenv("S3_REGION"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: os.Getenv("S3_REGION")}); err != nil {
+			return nil, err
+		}
+	}
+	return &s3Storage{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(os.Getenv("S3_PREFIX"), "/"),
+	}, nil
+}
+
+func (s *s3Storage) Name() string {
+	return S3StorageName
+}
+
+func (s *s3Storage) Save(name string, reader io.Reader, size int64, contentType string) (string, error) {
+	key := path.Join(s.prefix, name)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, reader, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *s3Storage) Open(key string) (StoredFile, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the object is only fetched once it is read, asking for its details finds
+	// out whether it is there at all
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return s3File{Object: object, info: info}, nil
+}
+
+func (s *s3Storage) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Storage) Walk(walk func(path string, size int64, modTime time.Time) error) error {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err := walk(object.Key, object.Size, object.LastModified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type s3File struct {
+	*minio.Object
+	info minio.ObjectInfo
+}
+
+func (f s3File) Size() int64 {
+	return f.info.Size
+}
+
+func (f s3File) ModTime() time.Time {
+	return f.info.LastModified
+}
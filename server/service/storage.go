@@ -0,0 +1,191 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"hammond/db"
+)
+
+const (
+	LocalStorageName = "local"
+	S3StorageName    = "s3"
+)
+
+// Storage keeps the files of attachments somewhere. The path returned when a
+// file is saved is what the storage finds it by later on.
+type Storage interface {
+	Name() string
+	Save(name string, reader io.Reader, size int64, contentType string) (string, error)
+	Open(path string) (StoredFile, error)
+	Delete(path string) error
+	// Walk calls walk for every file the storage keeps.
+	Walk(walk func(path string, size int64, modTime time.Time) error) error
+}
+
+// StoredFile is a file opened from a storage, which can be served with range
+// requests. Files which don't exist fail to open with os.ErrNotExist.
+type StoredFile interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+var (
+	s3Backend     Storage
+	s3BackendLock sync.Mutex
+)
+
+// GetStorage returns the storage backend of the given name.
+func GetStorage(name string) (Storage, error) {
+	switch name {
+	case "", LocalStorageName:
+		return localStorage{folder: os.Getenv("DATA")}, nil
+	case S3StorageName:
+		s3BackendLock.Lock()
+		defer s3BackendLock.Unlock()
+		// set up once it works, so a bucket unreachable for a moment is tried
+		// again the next time
+		if s3Backend == nil {
+			storage, err := newS3StorageFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			s3Backend = storage
+		}
+		return s3Backend, nil
+	}
+	return nil, fmt.Errorf("unknown storage %s", name)
+}
+
+// CurrentStorage is the storage new attachments go to, chosen by the STORAGE
+// environment variable. It defaults to the DATA folder.
+func CurrentStorage() (Storage, error) {
+	return GetStorage(strings.ToLower(os.Getenv("STORAGE")))
+}
+
+// OpenAttachment opens the file of an attachment from wherever it is kept.
+func OpenAttachment(attachment *db.Attachment) (StoredFile, error) {
+	storage, err := GetStorage(attachment.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(attachment.Path)
+}
+
+// withLocalFile hands the file of an attachment to something which needs it on
+// disk, copying it into a temporary file first when it is kept elsewhere.
+func withLocalFile(attachment *db.Attachment, use func(filePath string) error) error {
+	if attachment.Storage == "" || attachment.Storage == LocalStorageName {
+		return use(attachment.Path)
+	}
+	file, err := OpenAttachment(attachment)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	local, err := os.CreateTemp("", "hammond-*"+filepath.Ext(attachment.Path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local.Name())
+	if _, err := io.Copy(local, file); err != nil {
+		local.Close()
+		return err
+	}
+	if err := local.Close(); err != nil {
+		return err
+	}
+	return use(local.Name())
+}
+
+type localStorage struct {
+	folder string
+}
+
+func (s localStorage) Name() string {
+	return LocalStorageName
+}
+
+// Save writes the file aside and moves it in place, as files are named after
+// their content and one which is already there may be in use by attachments.
+func (s localStorage) Save(name string, reader io.Reader, size int64, contentType string) (string, error) {
+	filePath := path.Join(s.folder, name)
+	file, err := os.CreateTemp(s.folder, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return "", err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return filePath, os.Rename(file.Name(), filePath)
+}
+
+func (s localStorage) Open(filePath string) (StoredFile, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return localFile{File: file, info: info}, nil
+}
+
+func (s localStorage) Delete(filePath string) error {
+	return os.Remove(filePath)
+}
+
+// Walk only looks at the top of the folder, which is where files are saved.
+func (s localStorage) Walk(walk func(path string, size int64, modTime time.Time) error) error {
+	entries, err := os.ReadDir(s.folder)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := walk(path.Join(s.folder, entry.Name()), info.Size(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type localFile struct {
+	*os.File
+	info os.FileInfo
+}
+
+func (f localFile) Size() int64 {
+	return f.info.Size()
+}
+
+func (f localFile) ModTime() time.Time {
+	return f.info.ModTime()
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+
+	"hammond/db"
+	"hammond/models"
+)
+
+const migrateStorageJob = "MigrateStorage"
+
+// MigrateStorage moves the files of all attachments kept anywhere else into the
+// given storage, one at a time, so an interrupted migration can simply be run
+// again. The source files are removed unless keepSource is set.
+func MigrateStorage(target string, keepSource bool) (*models.StorageMigrationModel, error) {
+	targetStorage, err := GetStorage(target)
+	if err != nil {
+		return nil, err
+	}
+	if !db.GetLock(migrateStorageJob).Date.IsZero() {
+		return nil, errors.New("the attachments are already being migrated")
+	}
+	db.Lock(migrateStorageJob, 24*60)
+	defer db.Unlock(migrateStorageJob)
+
+	attachments, err := db.GetAttachmentsNotInStorage(targetStorage.Name())
+	if err != nil {
+		return nil, err
+	}
+	migration := models.StorageMigrationModel{
+		Storage: targetStorage.Name(),
+		Errors:  []string{},
+	}
+	// attachments sharing a file move along with the first of them
+	moved := make(map[string]bool)
+	for _, attachment := range *attachments {
+		file := attachment.Storage + "|" + attachment.Path
+		if moved[file] {
+			continue
+		}
+		moved[file] = true
+		err := migrateAttachment(&attachment, targetStorage, keepSource)
+		switch {
+		case os.IsNotExist(err):
+			migration.Missing++
+			log.Printf("%s: file %s is missing", attachment.ID, attachment.Path)
+		case err != nil:
+			migration.Failed++
+			migration.Errors = append(migration.Errors, fmt.Sprintf("%s: %s", attachment.ID, err))
+			log.Printf("%s: %s", attachment.ID, err)
+		default:
+			migration.Moved++
+		}
+	}
+	return &migration, nil
+}
+
+func migrateAttachment(attachment *db.Attachment, target Storage, keepSource bool) error {
+	source, err := GetStorage(attachment.Storage)
+	if err != nil {
+		return err
+	}
+	if source.Name() == target.Name() {
+		return db.UpdateAttachmentStorage(attachment.Storage, attachment.Path, target.Name(), attachment.Path)
+	}
+	file, err := source.Open(attachment.Path)
+	if err != nil {
+		return err
+	}
+	newPath, err := target.Save(path.Base(attachment.Path), file, file.Size(), attachment.ContentType)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if err := db.UpdateAttachmentStorage(attachment.Storage, attachment.Path, target.Name(), newPath); err != nil {
+		_ = target.Delete(newPath)
+		return err
+	}
+	if !keepSource {
+		if err := source.Delete(attachment.Path); err != nil && !os.IsNotExist(err) {
+			log.Printf("%s: could not remove %s: %s", attachment.ID, attachment.Path, err)
+		}
+	}
+	return nil
+}
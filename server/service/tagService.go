@@ -0,0 +1,128 @@
+package service
+
+import (
+	"sort"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+)
+
+func GetAllTags() (*[]db.TagUsage, error) {
+	return db.GetAllTags()
+}
+
+func tagNames(tags []db.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// hasAllTags tells whether the tags include every one of the wanted names.
+func hasAllTags(tags []db.Tag, wanted []string) bool {
+	for _, name := range wanted {
+		name = db.NormaliseTagName(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, tag := range tags {
+			if tag.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func FilterFillupsByTags(fillups []db.Fillup, tags []string) []db.Fillup {
+	toReturn := make([]db.Fillup, 0, len(fillups))
+	for _, fillup := range fillups {
+		if hasAllTags(fillup.Tags, tags) {
+			toReturn = append(toReturn, fillup)
+		}
+	}
+	return toReturn
+}
+
+func FilterExpensesByTags(expenses []db.Expense, tags []string) []db.Expense {
+	toReturn := make([]db.Expense, 0, len(expenses))
+	for _, expense := range expenses {
+		if hasAllTags(expense.Tags, tags) {
+			toReturn = append(toReturn, expense)
+		}
+	}
+	return toReturn
+}
+
+func GetVehicleTagStats(vehicleId uuid.UUID) ([]models.TagStatsModel, error) {
+	fillups, err := db.GetFillupsByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := db.GetExpensesByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	return getTagStats(*fillups, *expenses), nil
+}
+
+func GetUserTagStats(userId uuid.UUID, model models.UserStatsQueryModel) ([]models.TagStatsModel, error) {
+	fillups, expenses, err := getUserStatsEntries(userId, model)
+	if err != nil {
+		return nil, err
+	}
+	return getTagStats(*fillups, *expenses), nil
+}
+
+// getTagStats works out the stats of the entries carrying each tag. An entry
+// with several tags counts towards each of them.
+func getTagStats(fillups []db.Fillup, expenses []db.Expense) []models.TagStatsModel {
+	fillupsByTag := make(map[string][]db.Fillup)
+	expensesByTag := make(map[string][]db.Expense)
+	names := make(map[string]bool)
+	for _, fillup := range fillups {
+		if len(fillup.Tags) == 0 {
+			fillupsByTag[""] = append(fillupsByTag[""], fillup)
+			names[""] = true
+		}
+		for _, tag := range fillup.Tags {
+			fillupsByTag[tag.Name] = append(fillupsByTag[tag.Name], fillup)
+			names[tag.Name] = true
+		}
+	}
+	for _, expense := range expenses {
+		if len(expense.Tags) == 0 {
+			expensesByTag[""] = append(expensesByTag[""], expense)
+			names[""] = true
+		}
+		for _, tag := range expense.Tags {
+			expensesByTag[tag.Name] = append(expensesByTag[tag.Name], expense)
+			names[tag.Name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	toReturn := make([]models.TagStatsModel, 0, len(sorted))
+	for _, name := range sorted {
+		tagFillups := fillupsByTag[name]
+		tagExpenses := expensesByTag[name]
+		model := models.VehicleStatsModel{}
+		toReturn = append(toReturn, models.TagStatsModel{
+			Tag:   name,
+			Stats: model.SetStats(&tagFillups, &tagExpenses),
+		})
+	}
+	return toReturn
+}
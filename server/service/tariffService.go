@@ -0,0 +1,211 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+const tariffClockLayout = "15:04"
+
+func CreateElectricityTariff(model models.CreateElectricityTariffRequest, userId uuid.UUID) (*db.ElectricityTariff, error) {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	bands, err := toTariffBands(model.Bands)
+	if err != nil {
+		return nil, err
+	}
+	tariff := db.ElectricityTariff{
+		UserID:        userId,
+		Name:          model.Name,
+		Currency:      model.Currency,
+		BasePrice:     model.BasePrice,
+		EffectiveFrom: model.EffectiveFrom,
+		EffectiveTo:   model.EffectiveTo,
+		Bands:         bands,
+	}
+	if tariff.Currency == "" {
+		tariff.Currency = user.Currency
+	}
+	if tariff.EffectiveTo != nil && !tariff.EffectiveTo.After(tariff.EffectiveFrom) {
+		return nil, errors.New("effectiveTo should be after effectiveFrom")
+	}
+
+	tx := db.DB.Create(&tariff)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &tariff, nil
+}
+
+func UpdateElectricityTariff(tariffId uuid.UUID, model models.UpdateElectricityTariffRequest) error {
+	toUpdate, err := db.GetElectricityTariffById(tariffId)
+	if err != nil {
+		return err
+	}
+	bands, err := toTariffBands(model.Bands)
+	if err != nil {
+		return err
+	}
+	if model.EffectiveTo != nil && !model.EffectiveTo.After(model.EffectiveFrom) {
+		return errors.New("effectiveTo should be after effectiveFrom")
+	}
+
+	toUpdate.Name = model.Name
+	toUpdate.BasePrice = model.BasePrice
+	toUpdate.EffectiveFrom = model.EffectiveFrom
+	toUpdate.EffectiveTo = model.EffectiveTo
+	if model.Currency != "" {
+		toUpdate.Currency = model.Currency
+	}
+
+	tx := db.DB.Begin()
+	if err := tx.Omit(clause.Associations).Save(toUpdate).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("electricity_tariff_id = ?", tariffId).Unscoped().Delete(&db.ElectricityTariffBand{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i := range bands {
+		bands[i].ElectricityTariffID = tariffId
+	}
+	if len(bands) > 0 {
+		if err := tx.Create(&bands).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func GetElectricityTariffsForUser(userId uuid.UUID) (*[]db.ElectricityTariff, error) {
+	return db.GetElectricityTariffsForUser(userId)
+}
+
+func GetElectricityTariffById(tariffId uuid.UUID) (*db.ElectricityTariff, error) {
+	return db.GetElectricityTariffById(tariffId)
+}
+
+func DeleteElectricityTariffById(tariffId uuid.UUID) error {
+	return db.DeleteElectricityTariffById(tariffId)
+}
+
+func CanAccessElectricityTariff(tariffId, userId uuid.UUID) (bool, error) {
+	tariff, err := db.GetElectricityTariffById(tariffId)
+	if err != nil {
+		return false, err
+	}
+	return tariff.UserID == userId, nil
+}
+
+// CalculateChargingCost prices a charging session from the user's tariffs.
+// The energy is assumed to be delivered evenly over the session, so each
+// minute is charged at the rate of the band it falls in.
+func CalculateChargingCost(userId uuid.UUID, start, end time.Time, quantity float32) (*db.ElectricityTariff, float32, error) {
+	if !end.After(start) {
+		return nil, 0, errors.New("charging end should be after charging start")
+	}
+	tariffs, err := db.GetElectricityTariffsForUserInRange(userId, start, end)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(*tariffs) == 0 {
+		return nil, 0, errors.New("no electricity tariff is effective for this charging session")
+	}
+
+	total := end.Sub(start).Seconds()
+	var cost float64
+	var startTariff *db.ElectricityTariff
+	for current := start; current.Before(end); {
+		next := current.Truncate(time.Minute).Add(time.Minute)
+		if next.After(end) {
+			next = end
+		}
+		tariff := findEffectiveTariff(tariffs, current)
+		if tariff == nil {
+			return nil, 0, fmt.Errorf("no electricity tariff is effective at %s", current.Format(time.RFC3339))
+		}
+		if startTariff == nil {
+			startTariff = tariff
+		}
+		price, err := tariffPriceAt(tariff, current)
+		if err != nil {
+			return nil, 0, err
+		}
+		cost += float64(quantity) * next.Sub(current).Seconds() / total * float64(price)
+		current = next
+	}
+	return startTariff, float32(cost), nil
+}
+
+func findEffectiveTariff(tariffs *[]db.ElectricityTariff, date time.Time) *db.ElectricityTariff {
+	// tariffs are sorted by effective_from desc, so the first match is the latest one
+	for i := range *tariffs {
+		if (*tariffs)[i].IsEffectiveAt(date) {
+			return &(*tariffs)[i]
+		}
+	}
+	return nil
+}
+
+func tariffPriceAt(tariff *db.ElectricityTariff, date time.Time) (float32, error) {
+	minute := date.Hour()*60 + date.Minute()
+	for _, band := range tariff.Bands {
+		start, end, err := parseBandWindow(band.StartTime, band.EndTime)
+		if err != nil {
+			return 0, err
+		}
+		if minuteInWindow(minute, start, end) {
+			return band.PerUnitPrice, nil
+		}
+	}
+	return tariff.BasePrice, nil
+}
+
+func minuteInWindow(minute, start, end int) bool {
+	if start == end {
+		return true
+	}
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+func parseBandWindow(startTime, endTime string) (int, int, error) {
+	start, err := time.Parse(tariffClockLayout, startTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid band start time '%s', expected HH:MM", startTime)
+	}
+	end, err := time.Parse(tariffClockLayout, endTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid band end time '%s', expected HH:MM", endTime)
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
+
+func toTariffBands(items []models.ElectricityTariffBandModel) ([]db.ElectricityTariffBand, error) {
+	bands := make([]db.ElectricityTariffBand, 0, len(items))
+	for _, model := range items {
+		if _, _, err := parseBandWindow(model.StartTime, model.EndTime); err != nil {
+			return nil, err
+		}
+		bands = append(bands, db.ElectricityTariffBand{
+			Name:         model.Name,
+			StartTime:    model.StartTime,
+			EndTime:      model.EndTime,
+			PerUnitPrice: model.PerUnitPrice,
+		})
+	}
+	return bands, nil
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+	"github.com/rwcarlsen/goexif/exif"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ThumbnailSizes maps the sizes a thumbnail can be asked for to the longest
+// side of it in pixels.
+var ThumbnailSizes = map[string]int{
+	"small":  160,
+	"medium": 480,
+	"large":  1200,
+}
+
+const DefaultThumbnailSize = "medium"
+
+// maxThumbnailPixels guards against images which would take too much memory
+// to decode.
+const maxThumbnailPixels = 100 * 1000 * 1000
+
+// Making a thumbnail decodes a whole image or runs poppler, so only a few are
+// made at once. Those of new uploads wait in a queue, and when it is full, or
+// the source is large, they are left to be made the first time they are asked
+// for.
+const (
+	thumbnailWorkers           = 2
+	thumbnailQueueSize         = 100
+	maxEagerThumbnailPixels    = 24 * 1000 * 1000
+	maxEagerThumbnailFileBytes = 20 * 1024 * 1024
+)
+
+var (
+	thumbnailSlots     = make(chan struct{}, thumbnailWorkers)
+	thumbnailQueue     = make(chan db.Attachment, thumbnailQueueSize)
+	thumbnailQueueOnce sync.Once
+)
+
+var ErrNoThumbnail = errors.New("no thumbnail can be made for this attachment")
+
+// GetAttachmentThumbnail returns the path of the thumbnail of an attachment,
+// making it first if it hasn't been made yet.
+func GetAttachmentThumbnail(attachment *db.Attachment, size string) (string, error) {
+	maxSide, ok := ThumbnailSizes[size]
+	if !ok {
+		return "", fmt.Errorf("unknown thumbnail size %s", size)
+	}
+	thumbnailPath := getThumbnailPath(attachment.ID, size)
+	if _, err := os.Stat(thumbnailPath); err == nil {
+		return thumbnailPath, nil
+	}
+	thumbnailSlots <- struct{}{}
+	defer func() { <-thumbnailSlots }()
+	if err := createThumbnail(attachment, maxSide, thumbnailPath); err != nil {
+		return "", err
+	}
+	return thumbnailPath, nil
+}
+
+// queueThumbnails has the thumbnails of a freshly uploaded attachment made in
+// the background, so lists don't wait for them.
+func queueThumbnails(attachment db.Attachment) {
+	thumbnailQueueOnce.Do(func() {
+		for i := 0; i < thumbnailWorkers; i++ {
+			go func() {
+				for attachment := range thumbnailQueue {
+					createThumbnails(attachment)
+				}
+			}()
+		}
+	})
+	select {
+	case thumbnailQueue <- attachment:
+	default:
+	}
+}
+
+// createThumbnails makes the thumbnails of an attachment in every size.
+// Anything which isn't an image or a PDF is skipped, and so are large files.
+func createThumbnails(attachment db.Attachment) {
+	if attachment.Size > maxEagerThumbnailFileBytes {
+		return
+	}
+	largest := 0
+	for _, maxSide := range ThumbnailSizes {
+		largest = max(largest, maxSide)
+	}
+	thumbnailSlots <- struct{}{}
+	defer func() { <-thumbnailSlots }()
+	img, orientation, err := loadThumbnailSource(&attachment, largest, maxEagerThumbnailPixels)
+	if err != nil {
+		return
+	}
+	for size, maxSide := range ThumbnailSizes {
+		if err := writeThumbnail(img, orientation, maxSide, getThumbnailPath(attachment.ID, size)); err != nil {
+			return
+		}
+	}
+}
+
+// RemoveThumbnails deletes the thumbnails of an attachment which is gone.
+func RemoveThumbnails(attachmentId uuid.UUID) {
+	for size := range ThumbnailSizes {
+		_ = os.Remove(getThumbnailPath(attachmentId, size))
+	}
+}
+
+func getThumbnailPath(attachmentId uuid.UUID, size string) string {
+	folder := createConfigFolderIfNotExists("thumbnails")
+	return path.Join(folder, attachmentId.String()+"-"+size+".jpg")
+}
+
+func createThumbnail(attachment *db.Attachment, maxSide int, thumbnailPath string) error {
+	img, orientation, err := loadThumbnailSource(attachment, maxSide, maxThumbnailPixels)
+	if err != nil {
+		return err
+	}
+	return writeThumbnail(img, orientation, maxSide, thumbnailPath)
+}
+
+// loadThumbnailSource reads what a thumbnail is made from, along with its
+// EXIF orientation. Images of more than maxPixels are not decoded.
+func loadThumbnailSource(attachment *db.Attachment, maxSide, maxPixels int) (image.Image, int, error) {
+	if isPdf(attachment) {
+		var img image.Image
+		err := withLocalFile(attachment, func(filePath string) error {
+			var err error
+			img, err = renderPdfFirstPage(filePath, maxSide)
+			return err
+		})
+		return img, 1, err
+	}
+	file, err := OpenAttachment(attachment)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	return decodeImage(file, maxPixels)
+}
+
+func writeThumbnail(img image.Image, orientation, maxSide int, thumbnailPath string) error {
+	// turned after scaling down, which is a lot cheaper
+	thumbnail := orientImage(scaleImage(img, maxSide), orientation)
+	// written aside and moved in place, so that a thumbnail being made while
+	// it is asked for is never served half written
+	file, err := os.CreateTemp(filepath.Dir(thumbnailPath), ".thumbnail-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := jpeg.Encode(file, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), thumbnailPath)
+}
+
+func isPdf(attachment *db.Attachment) bool {
+	return attachment.ContentType == "application/pdf" || strings.EqualFold(filepath.Ext(attachment.Path), ".pdf")
+}
+
+// decodeImage reads an image along with the EXIF orientation it is meant to
+// be looked at in.
+func decodeImage(file io.ReadSeeker, maxPixels int) (image.Image, int, error) {
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, 0, ErrNoThumbnail
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, 0, ErrNoThumbnail
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, 0, err
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, 0, ErrNoThumbnail
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, 0, err
+	}
+	return img, readOrientation(file), nil
+}
+
+// renderPdfFirstPage has poppler draw the first page of a PDF. Without it
+// installed PDFs get no preview.
+func renderPdfFirstPage(filePath string, maxSide int) (image.Image, error) {
+	pdftoppm, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return nil, ErrNoThumbnail
+	}
+	folder, err := os.MkdirTemp("", "hammond-pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(folder)
+	prefix := path.Join(folder, "page")
+	cmd := exec.Command(pdftoppm, "-f", "1", "-l", "1", "-singlefile", "-jpeg", "-scale-to", fmt.Sprint(maxSide), filePath, prefix)
+	if err := cmd.Run(); err != nil {
+		return nil, ErrNoThumbnail
+	}
+	page, err := os.Open(prefix + ".jpg")
+	if err != nil {
+		return nil, err
+	}
+	defer page.Close()
+	img, _, err := decodeImage(page, maxThumbnailPixels)
+	return img, err
+}
+
+func readOrientation(file io.Reader) int {
+	x, err := exif.Decode(file)
+	if err != nil {
+		return 1
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+	orientation, err := tag.Int(0)
+	if err != nil {
+		return 1
+	}
+	return orientation
+}
+
+// scaleImage fits an image into a square of maxSide pixels, never making it
+// bigger. Transparent parts end up white as JPEG has no alpha.
+func scaleImage(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = max(1, height*maxSide/width)
+			width = maxSide
+		} else {
+			width = max(1, width*maxSide/height)
+			height = maxSide
+		}
+	}
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, bounds, draw.Over, nil)
+	return thumbnail
+}
+
+// orientImage undoes the rotation and mirroring an EXIF orientation
+// describes.
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	swap := orientation >= 5
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	if swap {
+		out = image.NewRGBA(image.Rect(0, 0, height, width))
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+			out.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+)
+
+const purgeTrashJob = "PurgeTrash"
+
+func GetUserTrash(userId uuid.UUID) (*models.TrashModel, error) {
+	retentionDays := trashRetentionDays()
+	trash := models.TrashModel{
+		Vehicles:      []models.TrashVehicleModel{},
+		Fillups:       []db.Fillup{},
+		Expenses:      []db.Expense{},
+		RetentionDays: retentionDays,
+	}
+
+	vehicles, err := db.GetTrashedVehiclesByOwner(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, vehicle := range *vehicles {
+		countFillups, countExpenses, err := db.CountTrashedWithVehicle(&vehicle)
+		if err != nil {
+			return nil, err
+		}
+		trash.Vehicles = append(trash.Vehicles, models.TrashVehicleModel{
+			Vehicle:       vehicle,
+			CountFillups:  countFillups,
+			CountExpenses: countExpenses,
+			PurgeDate:     vehicle.DeletedAt.Time.AddDate(0, 0, retentionDays),
+		})
+	}
+
+	liveVehicles, err := GetUserVehicles(userId, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(*liveVehicles) == 0 {
+		return &trash, nil
+	}
+	var vehicleIds []uuid.UUID
+	for _, vehicle := range *liveVehicles {
+		vehicleIds = append(vehicleIds, vehicle.ID)
+	}
+	fillups, err := db.GetTrashedFillups(vehicleIds)
+	if err != nil {
+		return nil, err
+	}
+	trash.Fillups = *fillups
+	expenses, err := db.GetTrashedExpenses(vehicleIds)
+	if err != nil {
+		return nil, err
+	}
+	trash.Expenses = *expenses
+	return &trash, nil
+}
+
+// GetTrashedVehicleById loads a vehicle which is in the trash.
+func GetTrashedVehicleById(vehicleId uuid.UUID) (*db.Vehicle, error) {
+	return db.GetTrashedVehicleById(vehicleId)
+}
+
+// RestoreVehicle takes a vehicle out of the trash along with the fillups and
+// expenses deleted with it. Only its owner may restore it.
+func RestoreVehicle(userId, vehicleId uuid.UUID) error {
+	vehicle, err := db.GetTrashedVehicleById(vehicleId)
+	if err != nil {
+		return err
+	}
+	canRestore, err := CanDeleteVehicle(vehicle.ID, userId)
+	if err != nil {
+		return err
+	}
+	if !canRestore {
+		return errors.New("you are not allowed to restore this vehicle")
+	}
+	tx := db.DB.Begin()
+	if err := db.RestoreVehicle(tx, vehicle); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, userId, db.RESTORE_ACTION, db.AUDIT_VEHICLE, vehicle.ID, &vehicle.ID, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func RestoreFillup(userId, fillupId uuid.UUID) error {
+	fillup, err := db.GetTrashedFillupById(fillupId)
+	if err != nil {
+		return err
+	}
+	if err := checkRestoreVehicle(userId, fillup.VehicleID); err != nil {
+		return err
+	}
+	tx := db.DB.Begin()
+	if err := db.RestoreFillupById(tx, fillup.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, userId, db.RESTORE_ACTION, db.AUDIT_FILLUP, fillup.ID, &fillup.VehicleID, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func RestoreExpense(userId, expenseId uuid.UUID) error {
+	expense, err := db.GetTrashedExpenseById(expenseId)
+	if err != nil {
+		return err
+	}
+	if err := checkRestoreVehicle(userId, expense.VehicleID); err != nil {
+		return err
+	}
+	tx := db.DB.Begin()
+	if err := db.RestoreExpenseById(tx, expense.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, userId, db.RESTORE_ACTION, db.AUDIT_EXPENSE, expense.ID, &expense.VehicleID, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// checkRestoreVehicle makes sure that an entry goes back to a vehicle the user
+// can see, which is not in the trash itself.
+func checkRestoreVehicle(userId, vehicleId uuid.UUID) error {
+	vehicles, err := GetUserVehicles(userId, true)
+	if err != nil {
+		return err
+	}
+	for _, vehicle := range *vehicles {
+		if vehicle.ID == vehicleId {
+			return nil
+		}
+	}
+	if _, err := db.GetTrashedVehicleById(vehicleId); err == nil {
+		return errors.New("the vehicle of this entry is in the trash, restore the vehicle first")
+	}
+	return errors.New("you are not allowed to restore this entry")
+}
+
+func UpdateTrashSettings(model models.TrashSettingsRequest) error {
+	setting := db.GetOrCreateSetting()
+	setting.TrashRetentionDays = model.RetentionDays
+	return db.UpdateSettings(setting)
+}
+
+func trashRetentionDays() int {
+	days := db.GetOrCreateSetting().TrashRetentionDays
+	if days <= 0 {
+		return db.DefaultTrashRetentionDays
+	}
+	return days
+}
+
+// PurgeTrash permanently removes what has been in the trash for longer than
+// the retention period.
+func PurgeTrash() {
+	if !db.GetLock(purgeTrashJob).Date.IsZero() {
+		return
+	}
+	db.Lock(purgeTrashJob, 30)
+	defer db.Unlock(purgeTrashJob)
+
+	if _, err := purgeTrash(); err != nil {
+		fmt.Println("error while purging the trash", err)
+	}
+}
+
+// PurgeTrashNow runs the purge straight away, unless it is already running.
+func PurgeTrashNow() (*models.TrashPurgeModel, error) {
+	if !db.GetLock(purgeTrashJob).Date.IsZero() {
+		return nil, errors.New("the trash is already being purged")
+	}
+	db.Lock(purgeTrashJob, 30)
+	defer db.Unlock(purgeTrashJob)
+
+	return purgeTrash()
+}
+
+func purgeTrash() (*models.TrashPurgeModel, error) {
+	purge := models.TrashPurgeModel{
+		Before: time.Now().AddDate(0, 0, -trashRetentionDays()),
+	}
+	vehicleIds, err := db.GetVehicleIdsTrashedBefore(purge.Before)
+	if err != nil {
+		return nil, err
+	}
+	purge.RemovedVehicles = []models.VehiclePurgeModel{}
+	for _, vehicleId := range vehicleIds {
+		vehicle, err := db.GetAnyVehicleById(vehicleId)
+		if err != nil {
+			return nil, err
+		}
+		removed, err := purgeVehicle(vehicle, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		purge.Vehicles++
+		purge.RemovedVehicles = append(purge.RemovedVehicles, *removed)
+	}
+	purge.Fillups, err = db.PurgeFillupsTrashedBefore(purge.Before)
+	if err != nil {
+		return nil, err
+	}
+	purge.Expenses, err = db.PurgeExpensesTrashedBefore(purge.Before)
+	if err != nil {
+		return nil, err
+	}
+	return &purge, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+func CreateTyreSet(vehicleId, userId uuid.UUID, model models.CreateTyreSetRequest) (*db.TyreSet, error) {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	tyreSet := db.TyreSet{
+		VehicleID:    vehicleId,
+		Name:         model.Name,
+		Brand:        model.Brand,
+		Model:        model.Model,
+		Size:         model.Size,
+		Season:       *model.Season,
+		PurchaseDate: model.PurchaseDate,
+		PurchaseCost: model.PurchaseCost,
+		Currency:     user.Currency,
+		Comments:     model.Comments,
+		IsRetired:    model.IsRetired,
+	}
+	tx := db.DB.Create(&tyreSet)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &tyreSet, nil
+}
+
+func UpdateTyreSet(vehicleId, tyreSetId uuid.UUID, model models.UpdateTyreSetRequest) error {
+	toUpdate, err := getVehicleTyreSet(vehicleId, tyreSetId)
+	if err != nil {
+		return err
+	}
+	toUpdate.Name = model.Name
+	toUpdate.Brand = model.Brand
+	toUpdate.Model = model.Model
+	toUpdate.Size = model.Size
+	toUpdate.Season = *model.Season
+	toUpdate.PurchaseDate = model.PurchaseDate
+	toUpdate.PurchaseCost = model.PurchaseCost
+	toUpdate.Comments = model.Comments
+	toUpdate.IsRetired = model.IsRetired
+	return db.DB.Omit(clause.Associations).Save(toUpdate).Error
+}
+
+func GetTyreSetsByVehicleId(vehicleId uuid.UUID) (*[]db.TyreSet, error) {
+	tyreSets, err := db.GetTyreSetsByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	odoReading, err := GetLatestOdoReadingForVehicle(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	odo, err := getOdometer(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range *tyreSets {
+		(*tyreSets)[i].DistanceDriven = tyreSetDistance(&(*tyreSets)[i], odo, odoReading)
+	}
+	return tyreSets, nil
+}
+
+func GetTyreSetById(vehicleId, tyreSetId uuid.UUID) (*db.TyreSet, error) {
+	tyreSet, err := getVehicleTyreSet(vehicleId, tyreSetId)
+	if err != nil {
+		return nil, err
+	}
+	odoReading, err := GetLatestOdoReadingForVehicle(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	odo, err := getOdometer(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	tyreSet.DistanceDriven = tyreSetDistance(tyreSet, odo, odoReading)
+	return tyreSet, nil
+}
+
+func DeleteTyreSet(vehicleId, tyreSetId uuid.UUID) error {
+	if _, err := getVehicleTyreSet(vehicleId, tyreSetId); err != nil {
+		return err
+	}
+	return db.DeleteTyreSetById(tyreSetId)
+}
+
+// MountTyreSet fits a tyre set to its vehicle. Any set that is still mounted
+// on the vehicle is unmounted at the same date and odometer reading.
+func MountTyreSet(vehicleId, tyreSetId uuid.UUID, model models.TyreMountingRequest) error {
+	tyreSet, err := getVehicleTyreSet(vehicleId, tyreSetId)
+	if err != nil {
+		return err
+	}
+	if tyreSet.IsRetired {
+		return errors.New("a retired tyre set cannot be mounted")
+	}
+	if tyreSet.CurrentMounting() != nil {
+		return errors.New("tyre set is already mounted")
+	}
+	mounted, err := db.GetMountedTyreMountingsByVehicleId(vehicleId)
+	if err != nil {
+		return err
+	}
+	odo, err := getOdometer(vehicleId)
+	if err != nil {
+		return err
+	}
+
+	tx := db.DB.Begin()
+	for _, mounting := range *mounted {
+		if odo.trueDistance(model.OdoReading, model.Date) < odo.trueDistance(mounting.MountOdoReading, mounting.MountDate) {
+			tx.Rollback()
+			return errors.New("odometer reading is lower than the one at which the current tyres were mounted")
+		}
+		err := tx.Model(&db.TyreMounting{}).Where("id = ?", mounting.ID).Updates(map[string]interface{}{
+			"unmount_date":        model.Date,
+			"unmount_odo_reading": model.OdoReading,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Create(&db.TyreMounting{
+		TyreSetID:       tyreSetId,
+		MountDate:       model.Date,
+		MountOdoReading: model.OdoReading,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func UnmountTyreSet(vehicleId, tyreSetId uuid.UUID, model models.TyreMountingRequest) error {
+	tyreSet, err := getVehicleTyreSet(vehicleId, tyreSetId)
+	if err != nil {
+		return err
+	}
+	mounting := tyreSet.CurrentMounting()
+	if mounting == nil {
+		return errors.New("tyre set is not mounted")
+	}
+	odo, err := getOdometer(vehicleId)
+	if err != nil {
+		return err
+	}
+	if odo.trueDistance(model.OdoReading, model.Date) < odo.trueDistance(mounting.MountOdoReading, mounting.MountDate) {
+		return errors.New("odometer reading is lower than the one at which the tyres were mounted")
+	}
+	mounting.UnmountDate = &model.Date
+	mounting.UnmountOdoReading = &model.OdoReading
+	return db.DB.Save(mounting).Error
+}
+
+func CreateTyreTreadDepth(vehicleId, tyreSetId uuid.UUID, model models.CreateTyreTreadDepthRequest) (*db.TyreTreadDepth, error) {
+	if _, err := getVehicleTyreSet(vehicleId, tyreSetId); err != nil {
+		return nil, err
+	}
+	treadDepth := db.TyreTreadDepth{
+		TyreSetID:  tyreSetId,
+		Date:       model.Date,
+		OdoReading: model.OdoReading,
+		FrontLeft:  model.FrontLeft,
+		FrontRight: model.FrontRight,
+		RearLeft:   model.RearLeft,
+		RearRight:  model.RearRight,
+	}
+	tx := db.DB.Create(&treadDepth)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &treadDepth, nil
+}
+
+func getVehicleTyreSet(vehicleId, tyreSetId uuid.UUID) (*db.TyreSet, error) {
+	tyreSet, err := db.GetTyreSetById(tyreSetId)
+	if err != nil {
+		return nil, err
+	}
+	if tyreSet.VehicleID != vehicleId {
+		return nil, errors.New("tyre set does not belong to this vehicle")
+	}
+	return tyreSet, nil
+}
+
+// tyreSetDistance adds up the true distance covered in every mounting. A set
+// that is still mounted counts up to the latest known true distance.
+func tyreSetDistance(tyreSet *db.TyreSet, odo odometer, currentOdoReading int) int {
+	distance := 0
+	for _, mounting := range tyreSet.Mountings {
+		start := odo.trueDistance(mounting.MountOdoReading, mounting.MountDate)
+		end := currentOdoReading
+		if mounting.UnmountOdoReading != nil {
+			end = odo.trueDistance(*mounting.UnmountOdoReading, *mounting.UnmountDate)
+		}
+		if end > start {
+			distance += end - start
+		}
+	}
+	return distance
+}
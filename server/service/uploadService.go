@@ -0,0 +1,369 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"hammond/db"
+	"hammond/models"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// CreateAttachment checks an uploaded file against the upload settings and
+// records it. The type of the file is found from its content rather than
+// trusting the client, and a file with the same content as one uploaded
+// before is not stored again but shared.
+func CreateAttachment(reader io.Reader, originalName string, size int64, userId uuid.UUID) (*db.Attachment, error) {
+	upload, err := prepareUpload(reader, originalName, size)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	storage, err := CurrentStorage()
+	if err != nil {
+		return nil, err
+	}
+	filePath, stored, err := upload.store(storage)
+	if err != nil {
+		return nil, err
+	}
+	model := &db.Attachment{
+		Storage:      storage.Name(),
+		Path:         filePath,
+		Hash:         upload.hash,
+		OriginalName: originalName,
+		Size:         upload.size,
+		ContentType:  upload.mime.String(),
+		UserID:       userId,
+	}
+	tx := db.DB.Create(&model)
+
+	if tx.Error != nil {
+		// the file may have been saved by another upload of the same content
+		// meanwhile, so it only goes if no attachment refers to it
+		if stored {
+			_, _ = deleteAttachmentFile(model)
+		}
+		return nil, tx.Error
+	}
+	model.Photo = upload.photo
+	queueThumbnails(*model)
+	return model, nil
+}
+
+// upload is a file which passed the checks of the upload settings, kept in a
+// temporary file until it is stored.
+type upload struct {
+	file  *os.File
+	size  int64
+	mime  *mimetype.MIME
+	hash  string
+	photo *db.PhotoMetadata
+}
+
+func prepareUpload(reader io.Reader, originalName string, size int64) (*upload, error) {
+	setting := db.GetOrCreateSetting()
+	maxSize := maxUploadSize(setting)
+	if size > maxSize {
+		return nil, fmt.Errorf("%s is too large, files can be at most %s", originalName, formatUploadSize(maxSize))
+	}
+
+	// kept aside while it is looked into, and limited as the size given by the
+	// client can't be trusted either
+	file, err := os.CreateTemp("", "hammond-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	upload := &upload{file: file}
+	upload.size, err = io.Copy(file, io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		upload.Close()
+		return nil, err
+	}
+	if upload.size > maxSize {
+		upload.Close()
+		return nil, fmt.Errorf("%s is too large, files can be at most %s", originalName, formatUploadSize(maxSize))
+	}
+	if err := upload.check(originalName, setting); err != nil {
+		upload.Close()
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (u *upload) check(originalName string, setting *db.Setting) error {
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var err error
+	u.mime, err = mimetype.DetectReader(u.file)
+	if err != nil {
+		return err
+	}
+	if !isUploadTypeAllowed(u.mime, setting) {
+		return fmt.Errorf("%s is a file of type %s, which cannot be uploaded", originalName, u.mime.String())
+	}
+
+	if u.mime.Is("image/jpeg") {
+		if u.photo, err = readJpegUpload(u.file, setting.StripImageLocation); err != nil {
+			return err
+		}
+	}
+
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, u.file); err != nil {
+		return err
+	}
+	u.hash = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// store saves the upload under its content hash, unless a file with the same
+// content is kept by the storage already. It tells whether it stored a new
+// file.
+func (u *upload) store(storage Storage) (string, bool, error) {
+	existing, err := db.FindAttachmentByHash(u.hash, storage.Name())
+	if err != nil {
+		return "", false, err
+	}
+	if existing != nil {
+		if file, err := storage.Open(existing.Path); err == nil {
+			file.Close()
+			return existing.Path, false, nil
+		}
+	}
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return "", false, err
+	}
+	filePath, err := storage.Save(u.hash+u.mime.Extension(), u.file, u.size, u.mime.String())
+	return filePath, err == nil, err
+}
+
+func (u *upload) Close() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// deleteAttachmentFile removes the file of an attachment which is gone from
+// its storage, unless other attachments share it. It tells whether the file
+// was removed.
+func deleteAttachmentFile(attachment *db.Attachment) (bool, error) {
+	count, err := db.CountAttachmentsOfFile(attachment.Storage, attachment.Path)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	storage, err := GetStorage(attachment.Storage)
+	if err != nil {
+		return false, err
+	}
+	return true, storage.Delete(attachment.Path)
+}
+
+func UpdateUploadSettings(model models.UploadSettingsRequest) error {
+	for _, allowed := range model.AllowedTypes {
+		if !strings.Contains(allowed, "/") {
+			return fmt.Errorf("%s is not a MIME type", allowed)
+		}
+	}
+	setting := db.GetOrCreateSetting()
+	setting.MaxUploadSize = model.MaxUploadSize
+	setting.AllowedUploadTypes = strings.Join(model.AllowedTypes, ",")
+	if model.StripImageLocation != nil {
+		setting.StripImageLocation = *model.StripImageLocation
+	}
+	return db.UpdateSettings(setting)
+}
+
+func maxUploadSize(setting *db.Setting) int64 {
+	if setting.MaxUploadSize <= 0 {
+		return db.DefaultMaxUploadSize
+	}
+	return setting.MaxUploadSize
+}
+
+// isUploadTypeAllowed matches a type against the allowed ones, where image/*
+// allows any image. Parent types are not followed, so allowing text/plain
+// doesn't let HTML through.
+func isUploadTypeAllowed(mime *mimetype.MIME, setting *db.Setting) bool {
+	allowedTypes := setting.AllowedUploadTypes
+	if strings.TrimSpace(allowedTypes) == "" {
+		allowedTypes = db.DefaultAllowedUploadTypes
+	}
+	for _, allowed := range strings.Split(allowedTypes, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*/*" {
+			return true
+		}
+		if group, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mime.String(), group+"/") {
+				return true
+			}
+			continue
+		}
+		if allowed != "" && mime.Is(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatUploadSize(size int64) string {
+	if size >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	}
+	return fmt.Sprintf("%d KB", size/1024)
+}
+
+// readJpegUpload reads when and where a photo was taken, then strips the
+// location from the file if asked to. Other image types keep their location,
+// as only the EXIF segment of a JPEG is rewritten.
+func readJpegUpload(upload *os.File, stripLocation bool) (*db.PhotoMetadata, error) {
+	if _, err := upload.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	photo := readPhotoMetadata(upload)
+	if !stripLocation || photo == nil {
+		return photo, nil
+	}
+	if _, err := upload.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(upload)
+	if err != nil {
+		return nil, err
+	}
+	if !stripJpegLocation(content) {
+		return photo, nil
+	}
+	if _, err := upload.WriteAt(content, 0); err != nil {
+		return nil, err
+	}
+	return photo, nil
+}
+
+// readPhotoMetadata reads when and where a photo was taken from its EXIF
+// data. Photos without any give nil.
+func readPhotoMetadata(reader io.Reader) *db.PhotoMetadata {
+	x, err := exif.Decode(reader)
+	if err != nil {
+		return nil
+	}
+	var photo db.PhotoMetadata
+	if captureDate, err := x.DateTime(); err == nil {
+		photo.CaptureDate = &captureDate
+	}
+	if latitude, longitude, err := x.LatLong(); err == nil && checkLocation(&latitude, &longitude) == nil {
+		photo.Latitude = &latitude
+		photo.Longitude = &longitude
+	}
+	return &photo
+}
+
+// stripJpegLocation blanks out the GPS data in the EXIF segment of a JPEG, in
+// place so that no offset in the file changes. The GPS directory is left
+// behind empty. It tells whether there was anything to strip.
+func stripJpegLocation(content []byte) bool {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return false
+	}
+	for position := 2; position+4 <= len(content); {
+		if content[position] != 0xFF {
+			return false
+		}
+		marker := content[position+1]
+		// the image data starts after the start of scan, no metadata follows
+		if marker == 0xDA || marker == 0xD9 {
+			return false
+		}
+		length := int(binary.BigEndian.Uint16(content[position+2:]))
+		end := position + 2 + length
+		if length < 2 || end > len(content) {
+			return false
+		}
+		segment := content[position+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return stripTiffLocation(segment[6:])
+		}
+		position = end
+	}
+	return false
+}
+
+const gpsInfoTag = 0x8825
+
+func stripTiffLocation(tiff []byte) bool {
+	if len(tiff) < 8 {
+		return false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return false
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return false
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return false
+		}
+		if order.Uint16(tiff[entry:]) != gpsInfoTag {
+			continue
+		}
+		return blankIfd(tiff, int(order.Uint32(tiff[entry+8:])), order)
+	}
+	return false
+}
+
+// blankIfd zeroes a directory of a TIFF along with the values it points to.
+func blankIfd(tiff []byte, ifd int, order binary.ByteOrder) bool {
+	typeSizes := map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+	if ifd+2 > len(tiff) {
+		return false
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	end := ifd + 2 + entries*12
+	if end > len(tiff) {
+		return false
+	}
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		size := typeSizes[order.Uint16(tiff[entry+2:])] * int(order.Uint32(tiff[entry+4:]))
+		if size <= 4 {
+			continue
+		}
+		offset := int(order.Uint32(tiff[entry+8:]))
+		if offset >= 0 && offset+size <= len(tiff) {
+			clear(tiff[offset : offset+size])
+		}
+	}
+	// the count goes to zero along with the entries, and so does the link to
+	// a next directory which followed them
+	clear(tiff[ifd:end])
+	if end+4 <= len(tiff) {
+		clear(tiff[end : end+4])
+	}
+	return true
+}
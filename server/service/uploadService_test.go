@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// tiffEntry is a directory entry of a TIFF, its value already laid out in
+// the byte order of the file.
+type tiffEntry struct {
+	tag, kind uint16
+	count     uint32
+	value     uint32
+}
+
+// tiffByteOrder is a byte order which can lay out a TIFF as well as read it.
+type tiffByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// buildTiff lays out a TIFF with a first directory holding the given entries
+// and, if gps is set, a GPS directory with a latitude and a longitude. It
+// returns the TIFF and where the GPS data starts.
+func buildTiff(order tiffByteOrder, entries []tiffEntry, gps bool) ([]byte, int) {
+	var tiff []byte
+	if order.String() == binary.LittleEndian.String() {
+		tiff = append(tiff, 'I', 'I')
+	} else {
+		tiff = append(tiff, 'M', 'M')
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+
+	gpsIfd := 8 + 2 + (len(entries)+1)*12 + 4
+	if gps {
+		entries = append(entries, tiffEntry{tag: gpsInfoTag, kind: 4, count: 1, value: uint32(gpsIfd)})
+	}
+	tiff = order.AppendUint16(tiff, uint16(len(entries)))
+	for _, entry := range entries {
+		tiff = order.AppendUint16(tiff, entry.tag)
+		tiff = order.AppendUint16(tiff, entry.kind)
+		tiff = order.AppendUint32(tiff, entry.count)
+		tiff = order.AppendUint32(tiff, entry.value)
+	}
+	tiff = order.AppendUint32(tiff, 0)
+	if !gps {
+		return tiff, len(tiff)
+	}
+
+	// each reference fits in its entry, the three rationals of each
+	// coordinate are stored after the directory
+	coordinates := gpsIfd + 2 + 4*12 + 4
+	tiff = order.AppendUint16(tiff, 4)
+	for i, ref := range []byte{'N', 'E'} {
+		tiff = order.AppendUint16(tiff, uint16(1+2*i))
+		tiff = order.AppendUint16(tiff, 2)
+		tiff = order.AppendUint32(tiff, 2)
+		tiff = append(tiff, ref, 0, 0, 0)
+		tiff = order.AppendUint16(tiff, uint16(2+2*i))
+		tiff = order.AppendUint16(tiff, 5)
+		tiff = order.AppendUint32(tiff, 3)
+		tiff = order.AppendUint32(tiff, uint32(coordinates+i*24))
+	}
+	tiff = order.AppendUint32(tiff, 0)
+	for _, part := range []uint32{51, 1, 30, 1, 1234, 100, 0, 1, 7, 1, 3900, 100} {
+		tiff = order.AppendUint32(tiff, part)
+	}
+	return tiff, gpsIfd
+}
+
+// buildJpeg wraps a TIFF into the EXIF segment of a minimal JPEG and tells
+// where the TIFF starts in it.
+func buildJpeg(tiff []byte) ([]byte, int) {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(segment)+2))
+	start := len(jpeg) + 6
+	jpeg = append(jpeg, segment...)
+	jpeg = append(jpeg, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+	return jpeg, start
+}
+
+func TestStripJpegLocation(t *testing.T) {
+	orientation := tiffEntry{tag: 0x0112, kind: 3, count: 1, value: 1}
+	tests := []struct {
+		name  string
+		order tiffByteOrder
+		gps   bool
+	}{
+		{"little endian", binary.LittleEndian, true},
+		{"big endian", binary.BigEndian, true},
+		{"little endian without GPS", binary.LittleEndian, false},
+		{"big endian without GPS", binary.BigEndian, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tiff, gpsStart := buildTiff(test.order, []tiffEntry{orientation}, test.gps)
+			content, tiffStart := buildJpeg(tiff)
+			original := bytes.Clone(content)
+			if photo := readPhotoMetadata(bytes.NewReader(content)); test.gps && (photo == nil || photo.Latitude == nil) {
+				t.Fatal("the sample has no readable location to strip")
+			}
+
+			stripped := stripJpegLocation(content)
+			if stripped != test.gps {
+				t.Fatalf("stripJpegLocation() = %v, want %v", stripped, test.gps)
+			}
+			if len(content) != len(original) {
+				t.Fatalf("length changed from %d to %d", len(original), len(content))
+			}
+			gpsFrom, gpsTo := tiffStart+gpsStart, tiffStart+len(tiff)
+			if !bytes.Equal(content[:gpsFrom], original[:gpsFrom]) || !bytes.Equal(content[gpsTo:], original[gpsTo:]) {
+				t.Fatal("bytes outside the GPS data changed")
+			}
+			want := original[gpsFrom:gpsTo]
+			if test.gps {
+				want = make([]byte, gpsTo-gpsFrom)
+			}
+			if !bytes.Equal(content[gpsFrom:gpsTo], want) {
+				t.Fatalf("GPS data is % x, want % x", content[gpsFrom:gpsTo], want)
+			}
+			if photo := readPhotoMetadata(bytes.NewReader(content)); photo != nil && photo.Latitude != nil {
+				t.Fatalf("location %v is still readable", *photo.Latitude)
+			}
+		})
+	}
+}
+
+func TestStripJpegLocationLeavesOtherFilesAlone(t *testing.T) {
+	tiff, _ := buildTiff(binary.LittleEndian, nil, true)
+	withGps, _ := buildJpeg(tiff)
+	// the GPS directory points past the end of the TIFF
+	broken := bytes.Clone(tiff)
+	binary.LittleEndian.PutUint32(broken[8+2+8:], uint32(len(broken)))
+	brokenGps, _ := buildJpeg(broken)
+	// EXIF after the start of scan is image data, not metadata
+	afterScan := append([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, withGps[2:]...)
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"empty", nil},
+		{"PNG", append([]byte("\x89PNG\r\n\x1a\n"), tiff...)},
+		{"WebP", append([]byte("RIFF\x00\x00\x00\x00WEBPEXIF"), tiff...)},
+		{"TIFF without JPEG", tiff},
+		{"truncated JPEG", withGps[:len(withGps)/2]},
+		{"GPS directory out of range", brokenGps},
+		{"EXIF after start of scan", afterScan},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := bytes.Clone(test.content)
+			if stripJpegLocation(content) {
+				t.Fatal("stripJpegLocation() = true, want false")
+			}
+			if !bytes.Equal(content, test.content) {
+				t.Fatal("content changed")
+			}
+		})
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/google/uuid"
 )
 
-func CreateUser(userModel *models.RegisterRequest, role db.Role) error {
+// CreateUser registers a new user. actorId is the admin who registered them, or
+// uuid.Nil when the user is created while setting up the instance.
+func CreateUser(userModel *models.RegisterRequest, role db.Role, actorId uuid.UUID) error {
 	setting := db.GetOrCreateSetting()
 	toCreate := db.User{
 		Email:        strings.ToLower(userModel.Email),
@@ -24,8 +26,19 @@ func CreateUser(userModel *models.RegisterRequest, role db.Role) error {
 		return err
 	}
 
-	return db.CreateUser(&toCreate)
-
+	tx := db.DB.Begin()
+	if err := db.CreateUser(tx, &toCreate); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if actorId == uuid.Nil {
+		actorId = toCreate.ID
+	}
+	if err := recordAudit(tx, actorId, db.CREATE_ACTION, db.AUDIT_USER, toCreate.ID, nil, nil, &toCreate); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
 func GetUserById(id uuid.UUID) (*db.User, error) {
@@ -48,13 +61,33 @@ func UpdatePassword(id uuid.UUID, password string) (bool, error) {
 		return false, err
 	}
 
-	err = db.UpdateUser(user)
+	err = db.UpdateUser(db.DB, user)
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func SetDisabledStatusForUser(userId uuid.UUID, isDisabled bool) error {
-	return db.SetDisabledStatusForUser(userId, isDisabled)
+func SetDisabledStatusForUser(userId uuid.UUID, isDisabled bool, actorId uuid.UUID) error {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.IsDisabled == isDisabled {
+		return db.SetDisabledStatusForUser(db.DB, userId, isDisabled)
+	}
+	action := db.ENABLE_ACTION
+	if isDisabled {
+		action = db.DISABLE_ACTION
+	}
+	tx := db.DB.Begin()
+	if err := db.SetDisabledStatusForUser(tx, userId, isDisabled); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actorId, action, db.AUDIT_USER, userId, nil, map[string]interface{}{"isDisabled": user.IsDisabled}, map[string]interface{}{"isDisabled": isDisabled}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hammond/db"
+
+	"github.com/google/uuid"
+)
+
+// exportVehicleArchive writes the portable archive of a vehicle to the
+// archives of the user, who can import it back like any archive of theirs. It
+// returns the name of the archive.
+func exportVehicleArchive(vehicle *db.Vehicle, userId uuid.UUID) (string, error) {
+	name := "vehicle_" + cleanFileName(vehicle.Nickname) + "_" + time.Now().Format("2006.01.02_150405") + ".tar.gz"
+	archivePath := path.Join(vehicleArchiveFolder(userId), name)
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	err = WriteVehicleArchive(file, vehicle.ID, userId)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(archivePath)
+		return "", err
+	}
+	return name, nil
+}
+
+func vehicleArchiveFolder(userId uuid.UUID) string {
+	return createFolder(userId.String(), createConfigFolderIfNotExists("archives"))
+}
+
+// GetVehicleArchivePath finds an archive of the user by its name.
+func GetVehicleArchivePath(userId uuid.UUID, name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".tar.gz") {
+		return "", errors.New("invalid archive name")
+	}
+	archivePath := path.Join(vehicleArchiveFolder(userId), name)
+	if _, err := os.Stat(archivePath); err != nil {
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// addAttachmentToTarWriter copies the file of an attachment from its storage
+// into an archive. A file which went missing already cannot be archived and is
+// left out.
+func addAttachmentToTarWriter(attachment *db.Attachment, name string, tarWriter *tar.Writer) error {
+	file, err := OpenAttachment(attachment)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	header := &tar.Header{
+		Name:    name,
+		Size:    file.Size(),
+		Mode:    0644,
+		ModTime: file.ModTime(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
@@ -1,7 +1,11 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"hammond/db"
 	"hammond/models"
@@ -12,6 +16,10 @@ import (
 )
 
 func CreateVehicle(model models.CreateVehicleRequest, userId uuid.UUID) (*db.Vehicle, error) {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
 	vehicle := db.Vehicle{
 		Nickname:          model.Nickname,
 		Registration:      model.Registration,
@@ -22,20 +30,46 @@ func CreateVehicle(model models.CreateVehicleRequest, userId uuid.UUID) (*db.Veh
 		EngineSize:        model.EngineSize,
 		FuelUnit:          *model.FuelUnit,
 		FuelType:          *model.FuelType,
+		DistanceUnit:      user.DistanceUnit,
+	}
+	if model.DistanceUnit != nil {
+		vehicle.DistanceUnit = *model.DistanceUnit
+	}
+	customFields, err := prepareCustomFields(db.VEHICLE_ENTITY, model.CustomFields, true)
+	if err != nil {
+		return nil, err
 	}
 
-	tx := db.DB.Create(&vehicle)
-	if tx.Error != nil {
-		return nil, tx.Error
+	tx := db.DB.Begin()
+	if err := tx.Create(&vehicle).Error; err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 	association := db.UserVehicle{
 		UserID:    userId,
 		VehicleID: vehicle.ID,
 		IsOwner:   true,
 	}
-	tx = db.DB.Create(&association)
-	if tx.Error != nil {
-		return nil, tx.Error
+	if err := tx.Create(&association).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := customFields.save(tx, vehicle.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	fields, err := getCustomFields(tx, db.VEHICLE_ENTITY, []uuid.UUID{vehicle.ID})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	vehicle.CustomFields = fields[vehicle.ID]
+	if err := recordAudit(tx, userId, db.CREATE_ACTION, db.AUDIT_VEHICLE, vehicle.ID, &vehicle.ID, nil, &vehicle); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
 	}
 	return &vehicle, nil
 
@@ -49,6 +83,28 @@ func GetVehicleUsers(vehicleId uuid.UUID) (*[]db.UserVehicle, error) {
 	return db.GetVehicleUsers(vehicleId)
 }
 
+// canAccessVehicle tells whether the user is an admin or one of the users the
+// vehicle is shared with.
+func canAccessVehicle(userId, vehicleId uuid.UUID) (bool, error) {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return false, err
+	}
+	if user.Role == db.ADMIN {
+		return true, nil
+	}
+	vehicles, err := GetUserVehicles(userId, true)
+	if err != nil {
+		return false, err
+	}
+	for _, vehicle := range *vehicles {
+		if vehicle.ID == vehicleId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func CanDeleteVehicle(vehicleId, userId uuid.UUID) (bool, error) {
 	owner, err := db.GetVehicleOwner(vehicleId)
 	if err != nil {
@@ -57,20 +113,129 @@ func CanDeleteVehicle(vehicleId, userId uuid.UUID) (bool, error) {
 	return owner == userId, nil
 }
 
-func DeleteVehicle(vehicleId uuid.UUID) error {
-	err := db.DeleteExpenseByVehicleId(vehicleId)
+// DeleteVehicle moves a vehicle to the trash, see RestoreVehicle and
+// PurgeTrash.
+func DeleteVehicle(vehicleId, actorId uuid.UUID) error {
+	before, err := getVehicleWithCustomFields(db.DB, vehicleId)
 	if err != nil {
 		return err
 	}
-	err = db.DeleteFillupByVehicleId(vehicleId)
-	if err != nil {
+	tx := db.DB.Begin()
+	if err := db.DeleteVehicleById(tx, vehicleId); err != nil {
+		tx.Rollback()
 		return err
 	}
-	return db.DeleteVehicleById(vehicleId)
+	if err := recordAudit(tx, actorId, db.DELETE_ACTION, db.AUDIT_VEHICLE, vehicleId, &vehicleId, before, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// PurgeVehicle permanently deletes a vehicle of the user, whether it is in the
+// trash or not, optionally writing an archive of it first.
+func PurgeVehicle(userId, vehicleId uuid.UUID, archive bool) (*models.VehiclePurgeModel, error) {
+	vehicle, err := db.GetAnyVehicleById(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	canPurge, err := CanDeleteVehicle(vehicle.ID, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !canPurge {
+		return nil, errors.New("you are not allowed to delete this vehicle")
+	}
+	var before *db.Vehicle
+	if !vehicle.DeletedAt.Valid {
+		if before, err = getVehicleWithCustomFields(db.DB, vehicle.ID); err != nil {
+			return nil, err
+		}
+	}
+	var archiveOwner *uuid.UUID
+	if archive {
+		archiveOwner = &userId
+	}
+	var audit func(tx *gorm.DB) error
+	// a vehicle in the trash was audited when it was deleted
+	if before != nil {
+		audit = func(tx *gorm.DB) error {
+			return recordAudit(tx, userId, db.DELETE_ACTION, db.AUDIT_VEHICLE, vehicle.ID, &vehicle.ID, before, nil)
+		}
+	}
+	return purgeVehicle(vehicle, archiveOwner, audit)
+}
+
+// purgeVehicle permanently removes a vehicle along with everything recorded
+// against it, in a single transaction. The files nothing refers to any more
+// are removed afterwards; failing to remove one does not undo the purge but is
+// reported. When archiveOwner is set, an archive of the vehicle is written for
+// them first. audit, when set, records the purge in the same transaction.
+func purgeVehicle(vehicle *db.Vehicle, archiveOwner *uuid.UUID, audit func(tx *gorm.DB) error) (*models.VehiclePurgeModel, error) {
+	report := models.VehiclePurgeModel{
+		VehicleID:  vehicle.ID,
+		Nickname:   vehicle.Nickname,
+		FileErrors: []string{},
+	}
+	if archiveOwner != nil {
+		name, err := exportVehicleArchive(vehicle, *archiveOwner)
+		if err != nil {
+			return nil, err
+		}
+		report.Archive = name
+	}
+
+	tx := db.DB.Begin()
+	removed, err := db.PurgeVehicle(tx, vehicle.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if audit != nil {
+		if err := audit(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	report.Removed = *removed
+
+	for _, attachment := range removed.Files {
+		RemoveThumbnails(attachment.ID)
+		deleted, err := deleteAttachmentFile(&attachment)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				report.FileErrors = append(report.FileErrors, err.Error())
+			}
+			continue
+		}
+		if deleted {
+			report.FilesRemoved++
+		}
+	}
+	return &report, nil
 }
 
-func ShareVehicle(vehicleId, userId uuid.UUID) error {
-	return db.ShareVehicle(vehicleId, userId)
+func ShareVehicle(vehicleId, userId, actorId uuid.UUID) error {
+	isShared, err := isVehicleUser(vehicleId, userId)
+	if err != nil {
+		return err
+	}
+	if isShared {
+		return db.ShareVehicle(db.DB, vehicleId, userId)
+	}
+	tx := db.DB.Begin()
+	if err := db.ShareVehicle(tx, vehicleId, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actorId, db.SHARE_ACTION, db.AUDIT_VEHICLE, vehicleId, &vehicleId, nil, vehicleUserAudit(tx, userId)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
 func TransferVehicle(vehicleId, ownerId, newUserID uuid.UUID) error {
@@ -82,11 +247,74 @@ func TransferVehicle(vehicleId, ownerId, newUserID uuid.UUID) error {
 		return fmt.Errorf("only vehicle owner can transfer the vehicle")
 	}
 
-	return db.TransferVehicle(vehicleId, ownerId, newUserID)
+	tx := db.DB.Begin()
+	if err := db.TransferVehicle(tx, vehicleId, ownerId, newUserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, ownerId, db.TRANSFER_ACTION, db.AUDIT_VEHICLE, vehicleId, &vehicleId, vehicleUserAudit(tx, ownerId), vehicleUserAudit(tx, newUserID)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
-func UnshareVehicle(vehicleId, userId uuid.UUID) error {
-	return db.UnshareVehicle(vehicleId, userId)
+func UnshareVehicle(vehicleId, userId, actorId uuid.UUID) error {
+	isShared, err := isVehicleUser(vehicleId, userId)
+	if err != nil {
+		return err
+	}
+	if !isShared {
+		return db.UnshareVehicle(db.DB, vehicleId, userId)
+	}
+	tx := db.DB.Begin()
+	if err := db.UnshareVehicle(tx, vehicleId, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actorId, db.UNSHARE_ACTION, db.AUDIT_VEHICLE, vehicleId, &vehicleId, vehicleUserAudit(tx, userId), nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func isVehicleUser(vehicleId, userId uuid.UUID) (bool, error) {
+	users, err := db.GetVehicleUsers(vehicleId)
+	if err != nil {
+		return false, err
+	}
+	for _, user := range *users {
+		if user.UserID == userId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// vehicleUserAudit is how a user the vehicle is shared with shows in its audit
+// log.
+func vehicleUserAudit(tx *gorm.DB, userId uuid.UUID) map[string]interface{} {
+	audit := map[string]interface{}{"userId": userId}
+	if user, err := db.LoadUser(tx, userId); err == nil {
+		audit["userName"] = user.Name
+	}
+	return audit
+}
+
+// getVehicleWithCustomFields loads a vehicle along with its custom fields,
+// through tx when it is read in the middle of a change.
+func getVehicleWithCustomFields(tx *gorm.DB, vehicleId uuid.UUID) (*db.Vehicle, error) {
+	vehicle, err := db.LoadVehicle(tx, vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := getCustomFields(tx, db.VEHICLE_ENTITY, []uuid.UUID{vehicle.ID})
+	if err != nil {
+		return nil, err
+	}
+	vehicle.CustomFields = fields[vehicle.ID]
+	return vehicle, nil
 }
 
 func GetVehicleById(vehicleID uuid.UUID) (*db.Vehicle, error) {
@@ -94,22 +322,78 @@ func GetVehicleById(vehicleID uuid.UUID) (*db.Vehicle, error) {
 }
 
 func GetFillupsByVehicleId(vehicleId uuid.UUID) (*[]db.Fillup, error) {
-	return db.GetFillupsByVehicleId(vehicleId)
+	fillups, err := db.GetFillupsByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetFillupsCustomFields(*fillups); err != nil {
+		return nil, err
+	}
+	return fillups, SetFillupsTrueOdoReading(*fillups)
 }
 
 func GetExpensesByVehicleId(vehicleId uuid.UUID) (*[]db.Expense, error) {
-	return db.GetExpensesByVehicleId(vehicleId)
+	expenses, err := db.GetExpensesByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetExpensesCustomFields(*expenses); err != nil {
+		return nil, err
+	}
+	return expenses, SetExpensesTrueOdoReading(*expenses)
 }
 
 func GetFillupById(fillupId uuid.UUID) (*db.Fillup, error) {
-	return db.GetFillupById(fillupId)
+	return getFillup(db.DB, fillupId)
+}
+
+// getFillup loads a fillup the way GetFillupById does, through tx.
+func getFillup(tx *gorm.DB, fillupId uuid.UUID) (*db.Fillup, error) {
+	fillup, err := db.LoadFillup(tx, fillupId)
+	if err != nil {
+		return nil, err
+	}
+	odo, err := getOdometer(fillup.VehicleID)
+	if err != nil {
+		return nil, err
+	}
+	fillup.TrueOdoReading = odo.trueDistance(fillup.OdoReading, fillup.Date)
+	fields, err := getCustomFields(tx, db.FILLUP_ENTITY, []uuid.UUID{fillup.ID})
+	if err != nil {
+		return nil, err
+	}
+	fillup.CustomFields = fields[fillup.ID]
+	return fillup, nil
 }
 
 func GetExpenseById(expenseId uuid.UUID) (*db.Expense, error) {
-	return db.GetExpenseById(expenseId)
+	return getExpense(db.DB, expenseId)
+}
+
+// getExpense loads an expense the way GetExpenseById does, through tx.
+func getExpense(tx *gorm.DB, expenseId uuid.UUID) (*db.Expense, error) {
+	expense, err := db.LoadExpense(tx, expenseId)
+	if err != nil {
+		return nil, err
+	}
+	odo, err := getOdometer(expense.VehicleID)
+	if err != nil {
+		return nil, err
+	}
+	expense.TrueOdoReading = odo.trueDistance(expense.OdoReading, expense.Date)
+	fields, err := getCustomFields(tx, db.EXPENSE_ENTITY, []uuid.UUID{expense.ID})
+	if err != nil {
+		return nil, err
+	}
+	expense.CustomFields = fields[expense.ID]
+	return expense, nil
 }
 
-func UpdateVehicle(vehicleID uuid.UUID, model models.UpdateVehicleRequest) error {
+func UpdateVehicle(vehicleID uuid.UUID, model models.UpdateVehicleRequest, actorId uuid.UUID) error {
+	before, err := getVehicleWithCustomFields(db.DB, vehicleID)
+	if err != nil {
+		return err
+	}
 	toUpdate, err := GetVehicleById(vehicleID)
 	if err != nil {
 		return err
@@ -125,24 +409,208 @@ func UpdateVehicle(vehicleID uuid.UUID, model models.UpdateVehicleRequest) error
 	toUpdate.FuelUnit = *model.FuelUnit
 	toUpdate.FuelType = *model.FuelType
 	//}).Error
+	customFields, err := prepareCustomFields(db.VEHICLE_ENTITY, model.CustomFields, false)
+	if err != nil {
+		return err
+	}
 
-	return db.DB.Omit(clause.Associations).Save(toUpdate).Error
+	// changing the odometer unit converts everything recorded against the vehicle
+	tx := db.DB.Begin()
+	if model.DistanceUnit != nil && *model.DistanceUnit != toUpdate.DistanceUnit {
+		if err := db.ConvertVehicleDistanceUnit(tx, vehicleID, toUpdate.DistanceUnit, *model.DistanceUnit); err != nil {
+			tx.Rollback()
+			return err
+		}
+		toUpdate.DistanceUnit = *model.DistanceUnit
+	}
+	if err := tx.Omit(clause.Associations).Save(toUpdate).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := customFields.save(tx, vehicleID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	after, err := getVehicleWithCustomFields(tx, vehicleID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actorId, db.UPDATE_ACTION, db.AUDIT_VEHICLE, vehicleID, &vehicleID, before, after); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// toVehicleDistance converts an odometer reading entered by a user into the
+// distance unit of the vehicle, which is the unit entries are stored in.
+func toVehicleDistance(vehicleId uuid.UUID, user *db.User, odoReading int) (int, db.DistanceUnit, error) {
+	distanceUnit, err := db.GetVehicleDistanceUnit(vehicleId)
+	if err != nil {
+		return 0, 0, err
+	}
+	return db.ConvertDistance(odoReading, user.DistanceUnit, distanceUnit), distanceUnit, nil
+}
+
+// toEditedVehicleDistance is toVehicleDistance for an entry being edited. A
+// reading sent back as it was shown keeps the stored value, which would drift
+// by rounding on every edit going through another unit.
+func toEditedVehicleDistance(vehicleId uuid.UUID, user *db.User, odoReading, storedReading int, storedUnit db.DistanceUnit) (int, db.DistanceUnit, error) {
+	distanceUnit, err := db.GetVehicleDistanceUnit(vehicleId)
+	if err != nil {
+		return 0, 0, err
+	}
+	if distanceUnit == storedUnit && db.ConvertDistance(storedReading, storedUnit, user.DistanceUnit) == odoReading {
+		return storedReading, storedUnit, nil
+	}
+	return db.ConvertDistance(odoReading, user.DistanceUnit, distanceUnit), distanceUnit, nil
+}
+
+// loadActingUser loads the user entering a record along with the one it is
+// entered for, as readings are typed in the unit of the former.
+func loadActingUser(userId, actorId uuid.UUID) (*db.User, *db.User, error) {
+	user, err := db.GetUserById(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if actorId == userId {
+		return user, user, nil
+	}
+	actor, err := db.GetUserById(actorId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, actor, nil
+}
+
+// ConvertFillupDistanceUnit expresses the odometer reading of a fillup in the
+// distance unit of the user looking at it.
+func ConvertFillupDistanceUnit(fillup *db.Fillup, distanceUnit db.DistanceUnit) {
+	fillup.OdoReading = db.ConvertDistance(fillup.OdoReading, fillup.DistanceUnit, distanceUnit)
+	fillup.TrueOdoReading = db.ConvertDistance(fillup.TrueOdoReading, fillup.DistanceUnit, distanceUnit)
+	fillup.DistanceUnit = distanceUnit
+}
+
+func ConvertFillupsDistanceUnit(fillups []db.Fillup, distanceUnit db.DistanceUnit) {
+	for i := range fillups {
+		ConvertFillupDistanceUnit(&fillups[i], distanceUnit)
+	}
+}
+
+// ConvertExpenseDistanceUnit expresses the odometer reading of an expense in
+// the distance unit of the user looking at it.
+func ConvertExpenseDistanceUnit(expense *db.Expense, distanceUnit db.DistanceUnit) {
+	expense.OdoReading = db.ConvertDistance(expense.OdoReading, expense.DistanceUnit, distanceUnit)
+	expense.TrueOdoReading = db.ConvertDistance(expense.TrueOdoReading, expense.DistanceUnit, distanceUnit)
+	expense.DistanceUnit = distanceUnit
+}
+
+func ConvertExpensesDistanceUnit(expenses []db.Expense, distanceUnit db.DistanceUnit) {
+	for i := range expenses {
+		ConvertExpenseDistanceUnit(&expenses[i], distanceUnit)
+	}
 }
 
 func GetAllVehicles() (*[]db.Vehicle, error) {
 	return db.GetAllVehicles("")
 }
 
-func GetUserVehicles(id uuid.UUID) (*[]db.Vehicle, error) {
-	return db.GetUserVehicles(id)
+// GetUserVehicles returns the vehicles of the user. Vehicles that are no longer
+// active are only included when asked for.
+func GetUserVehicles(id uuid.UUID, includeInactive bool) (*[]db.Vehicle, error) {
+	vehicles, err := db.GetUserVehicles(id)
+	if err != nil || includeInactive {
+		return vehicles, err
+	}
+	active := []db.Vehicle{}
+	for _, vehicle := range *vehicles {
+		if vehicle.IsActive() {
+			active = append(active, vehicle)
+		}
+	}
+	return &active, nil
 }
 
-func CreateFillup(model models.CreateFillupRequest) (*db.Fillup, error) {
+// SetVehicleStatus moves a vehicle through its lifecycle. Only the owner can
+// do so, and a vehicle can always be made active again.
+func SetVehicleStatus(vehicleId, userId uuid.UUID, model models.VehicleStatusRequest) error {
+	if _, ok := db.VehicleStatusDetails[*model.Status]; !ok {
+		return errors.New("unknown vehicle status")
+	}
+	ownerId, err := GetVehicleOwner(vehicleId)
+	if err != nil {
+		return err
+	}
+	if ownerId != userId {
+		return fmt.Errorf("only vehicle owner can change the status of the vehicle")
+	}
+	updates := map[string]interface{}{
+		"status":          *model.Status,
+		"status_date":     nil,
+		"status_comments": "",
+	}
+	if *model.Status != db.ACTIVE_VEHICLE {
+		date := time.Now()
+		if model.Date != nil {
+			date = *model.Date
+		}
+		updates["status_date"] = date
+		updates["status_comments"] = model.Comments
+	}
+	before, err := getVehicleWithCustomFields(db.DB, vehicleId)
+	if err != nil {
+		return err
+	}
+	tx := db.DB.Begin()
+	if err := tx.Model(&db.Vehicle{}).Where("id = ?", vehicleId).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	after, err := getVehicleWithCustomFields(tx, vehicleId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, userId, db.UPDATE_ACTION, db.AUDIT_VEHICLE, vehicleId, &vehicleId, before, after); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
 
-	user, err := db.GetUserById(model.UserID)
+func CreateFillup(model models.CreateFillupRequest, actorId uuid.UUID) (*db.Fillup, error) {
+	fillup, customFields, err := prepareFillup(model, actorId)
 	if err != nil {
 		return nil, err
 	}
+	tx := db.DB.Begin()
+	if err := insertFillup(tx, fillup, model.Tags, customFields); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	created, err := auditCreatedFillup(tx, fillup.ID, actorId)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// prepareFillup builds a new fillup from a request, resolving everything it
+// refers to, before anything is written.
+func prepareFillup(model models.CreateFillupRequest, actorId uuid.UUID) (*db.Fillup, *customFieldChanges, error) {
+	user, actor, err := loadActingUser(model.UserID, actorId)
+	if err != nil {
+		return nil, nil, err
+	}
+	odoReading, distanceUnit, err := toVehicleDistance(model.VehicleID, actor, model.OdoReading)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	fillup := db.Fillup{
 		VehicleID:       model.VehicleID,
@@ -150,7 +618,7 @@ func CreateFillup(model models.CreateFillupRequest) (*db.Fillup, error) {
 		FuelQuantity:    model.FuelQuantity,
 		PerUnitPrice:    model.PerUnitPrice,
 		TotalAmount:     model.TotalAmount,
-		OdoReading:      model.OdoReading,
+		OdoReading:      odoReading,
 		IsTankFull:      model.IsTankFull,
 		HasMissedFillup: model.HasMissedFillup,
 		Comments:        model.Comments,
@@ -158,60 +626,184 @@ func CreateFillup(model models.CreateFillupRequest) (*db.Fillup, error) {
 		UserID:          model.UserID,
 		Date:            model.Date,
 		Currency:        user.Currency,
-		DistanceUnit:    user.DistanceUnit,
+		DistanceUnit:    distanceUnit,
 		FuelSubType:     model.FuelSubType,
 		Source:          "API",
+		IsHomeCharging:  model.IsHomeCharging,
+		ChargingStart:   model.ChargingStart,
+		ChargingEnd:     model.ChargingEnd,
+		Latitude:        model.Latitude,
+		Longitude:       model.Longitude,
+		Address:         model.Address,
 	}
-
-	tx := db.DB.Create(&fillup)
-	if tx.Error != nil {
-		return nil, tx.Error
+	if err := checkLocation(fillup.Latitude, fillup.Longitude); err != nil {
+		return nil, nil, err
+	}
+	station, err := resolveFillingStation(model.FillingStationID, model.FillingStation)
+	if err != nil {
+		return nil, nil, err
 	}
+	if station != nil {
+		fillup.FillingStationID = &station.ID
+		fillup.FillingStation = station.Name
+	}
+	if err := priceFillup(&fillup); err != nil {
+		return nil, nil, err
+	}
+	customFields, err := prepareCustomFields(db.FILLUP_ENTITY, model.CustomFields, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &fillup, customFields, nil
+}
 
-	return &fillup, nil
+// insertFillup writes a prepared fillup along with its tags and custom fields.
+func insertFillup(tx *gorm.DB, fillup *db.Fillup, tagNames []string, customFields *customFieldChanges) error {
+	tags, err := db.FindOrCreateTags(tx, tagNames)
+	if err != nil {
+		return err
+	}
+	fillup.Tags = tags
+	// the tags already exist, only the links to them are created
+	if err := tx.Omit("Tags.*").Create(fillup).Error; err != nil {
+		return err
+	}
+	return customFields.save(tx, fillup.ID)
+}
 
+// auditCreatedFillup records a new fillup in the transaction which created it,
+// and returns it the way it was stored.
+func auditCreatedFillup(tx *gorm.DB, fillupId, actorId uuid.UUID) (*db.Fillup, error) {
+	created, err := getFillup(tx, fillupId)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(tx, actorId, db.CREATE_ACTION, db.AUDIT_FILLUP, created.ID, &created.VehicleID, nil, created); err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
-func CreateExpense(model models.CreateExpenseRequest) (*db.Expense, error) {
-	user, err := db.GetUserById(model.UserID)
+func CreateExpense(model models.CreateExpenseRequest, actorId uuid.UUID) (*db.Expense, error) {
+	expense, customFields, err := prepareExpense(model, actorId)
 	if err != nil {
 		return nil, err
 	}
+	tx := db.DB.Begin()
+	if err := insertExpense(tx, expense, model.Tags, customFields); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	created, err := auditCreatedExpense(tx, expense.ID, actorId)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// prepareExpense builds a new expense from a request, resolving everything it
+// refers to, before anything is written.
+func prepareExpense(model models.CreateExpenseRequest, actorId uuid.UUID) (*db.Expense, *customFieldChanges, error) {
+	user, actor, err := loadActingUser(model.UserID, actorId)
+	if err != nil {
+		return nil, nil, err
+	}
+	odoReading, distanceUnit, err := toVehicleDistance(model.VehicleID, actor, model.OdoReading)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	expense := db.Expense{
 		VehicleID:    model.VehicleID,
 		Amount:       model.Amount,
-		OdoReading:   model.OdoReading,
+		OdoReading:   odoReading,
 		ExpenseType:  model.ExpenseType,
 		Comments:     model.Comments,
 		UserID:       model.UserID,
 		Date:         model.Date,
 		Currency:     user.Currency,
-		DistanceUnit: user.DistanceUnit,
+		DistanceUnit: distanceUnit,
 		Source:       "API",
+		Vendor:       model.Vendor,
+		LineItems:    toExpenseLineItems(model.LineItems),
+		Latitude:     model.Latitude,
+		Longitude:    model.Longitude,
+		Address:      model.Address,
 	}
-
-	tx := db.DB.Create(&expense)
-	if tx.Error != nil {
-		return nil, tx.Error
+	if err := checkLocation(expense.Latitude, expense.Longitude); err != nil {
+		return nil, nil, err
+	}
+	if len(expense.LineItems) > 0 {
+		expense.Amount = sumExpenseLineItems(expense.LineItems)
+	}
+	category, err := resolveExpenseCategory(model.ExpenseCategoryID, model.ExpenseType)
+	if err != nil {
+		return nil, nil, err
 	}
+	if category != nil {
+		expense.ExpenseCategoryID = &category.ID
+		expense.ExpenseType = category.Name
+	}
+	customFields, err := prepareCustomFields(db.EXPENSE_ENTITY, model.CustomFields, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &expense, customFields, nil
+}
 
-	return &expense, nil
+// insertExpense writes a prepared expense along with its line items, tags and
+// custom fields.
+func insertExpense(tx *gorm.DB, expense *db.Expense, tagNames []string, customFields *customFieldChanges) error {
+	tags, err := db.FindOrCreateTags(tx, tagNames)
+	if err != nil {
+		return err
+	}
+	expense.Tags = tags
+	if err := tx.Omit("Tags.*").Create(expense).Error; err != nil {
+		return err
+	}
+	return customFields.save(tx, expense.ID)
+}
 
+// auditCreatedExpense records a new expense, see auditCreatedFillup.
+func auditCreatedExpense(tx *gorm.DB, expenseId, actorId uuid.UUID) (*db.Expense, error) {
+	created, err := getExpense(tx, expenseId)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(tx, actorId, db.CREATE_ACTION, db.AUDIT_EXPENSE, created.ID, &created.VehicleID, nil, created); err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
-func UpdateFillup(fillupId uuid.UUID, model models.UpdateFillupRequest) error {
+func UpdateFillup(fillupId uuid.UUID, model models.UpdateFillupRequest, actor *db.User) error {
 	toUpdate, err := GetFillupById(fillupId)
 	if err != nil {
 		return err
 	}
-	return db.DB.Model(&toUpdate).Updates(db.Fillup{
+	before, err := GetFillupById(fillupId)
+	if err != nil {
+		return err
+	}
+	// the reading comes back from a form filled in the unit of whoever edits
+	// the entry, not of the user it belongs to
+	odoReading, distanceUnit, err := toEditedVehicleDistance(model.VehicleID, actor, model.OdoReading, before.OdoReading, before.DistanceUnit)
+	if err != nil {
+		return err
+	}
+	updates := db.Fillup{
 		VehicleID:       model.VehicleID,
 		FuelUnit:        *model.FuelUnit,
 		FuelQuantity:    model.FuelQuantity,
 		PerUnitPrice:    model.PerUnitPrice,
 		TotalAmount:     model.TotalAmount,
-		OdoReading:      model.OdoReading,
+		OdoReading:      odoReading,
+		DistanceUnit:    distanceUnit,
 		IsTankFull:      model.IsTankFull,
 		HasMissedFillup: model.HasMissedFillup,
 		Comments:        model.Comments,
@@ -219,31 +811,307 @@ func UpdateFillup(fillupId uuid.UUID, model models.UpdateFillupRequest) error {
 		UserID:          model.UserID,
 		FuelSubType:     model.FuelSubType,
 		Date:            model.Date,
-	}).Error
+		IsHomeCharging:  model.IsHomeCharging,
+		ChargingStart:   model.ChargingStart,
+		ChargingEnd:     model.ChargingEnd,
+		Latitude:        model.Latitude,
+		Longitude:       model.Longitude,
+		Address:         model.Address,
+	}
+	if err := checkLocation(updates.Latitude, updates.Longitude); err != nil {
+		return err
+	}
+	station, err := resolveFillingStation(model.FillingStationID, model.FillingStation)
+	if err != nil {
+		return err
+	}
+	if station != nil {
+		updates.FillingStationID = &station.ID
+		updates.FillingStation = station.Name
+	}
+	if err := priceFillup(&updates); err != nil {
+		return err
+	}
+	customFields, err := prepareCustomFields(db.FILLUP_ENTITY, model.CustomFields, false)
+	if err != nil {
+		return err
+	}
+	tx := db.DB.Begin()
+	if err := tx.Model(&toUpdate).Omit(clause.Associations).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	// a station name which matches no station unlinks the fillup, while an
+	// edit sending no station at all leaves it as it was
+	if station == nil && strings.TrimSpace(model.FillingStation) != "" {
+		if err := tx.Model(&toUpdate).Omit(clause.Associations).Update("filling_station_id", nil).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := customFields.save(tx, toUpdate.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// tags are only replaced when they are sent
+	if model.Tags != nil {
+		tags, err := db.FindOrCreateTags(tx, model.Tags)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Model(toUpdate).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	after, err := getFillup(tx, fillupId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actor.ID, db.UPDATE_ACTION, db.AUDIT_FILLUP, fillupId, &after.VehicleID, before, after); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
-func UpdateExpense(fillupId uuid.UUID, model models.UpdateExpenseRequest) error {
+// priceFillup completes the price of a fillup. When only one of the per unit
+// price and total amount is given the other is derived from the quantity. When
+// neither is given, a home charging session is priced from the user's tariffs.
+func priceFillup(fillup *db.Fillup) error {
+	if fillup.PerUnitPrice != 0 || fillup.TotalAmount != 0 {
+		if fillup.TotalAmount == 0 {
+			fillup.TotalAmount = fillup.PerUnitPrice * fillup.FuelQuantity
+		}
+		if fillup.PerUnitPrice == 0 && fillup.FuelQuantity != 0 {
+			fillup.PerUnitPrice = fillup.TotalAmount / fillup.FuelQuantity
+		}
+		return nil
+	}
+	isHomeCharging := fillup.IsHomeCharging != nil && *fillup.IsHomeCharging
+	if !isHomeCharging || fillup.ChargingStart == nil || fillup.ChargingEnd == nil {
+		return errors.New("perUnitPrice or totalAmount is required unless a home charging session with start and end times is logged")
+	}
+	if fillup.FuelQuantity <= 0 {
+		return errors.New("fuelQuantity is required to price a charging session")
+	}
+	tariff, cost, err := CalculateChargingCost(fillup.UserID, *fillup.ChargingStart, *fillup.ChargingEnd, fillup.FuelQuantity)
+	if err != nil {
+		return err
+	}
+	fillup.ElectricityTariffID = &tariff.ID
+	fillup.Currency = tariff.Currency
+	fillup.TotalAmount = cost
+	fillup.PerUnitPrice = cost / fillup.FuelQuantity
+	return nil
+}
+
+func UpdateExpense(fillupId uuid.UUID, model models.UpdateExpenseRequest, actor *db.User) error {
 	toUpdate, err := GetExpenseById(fillupId)
 	if err != nil {
 		return err
 	}
-	return db.DB.Model(&toUpdate).Updates(db.Expense{
-		VehicleID:   model.VehicleID,
-		Amount:      model.Amount,
-		OdoReading:  model.OdoReading,
-		ExpenseType: model.ExpenseType,
-		Comments:    model.Comments,
-		UserID:      model.UserID,
-		Date:        model.Date,
-	}).Error
+	before, err := GetExpenseById(fillupId)
+	if err != nil {
+		return err
+	}
+	// the reading comes back from a form filled in the unit of whoever edits
+	// the entry, not of the user it belongs to
+	odoReading, distanceUnit, err := toEditedVehicleDistance(model.VehicleID, actor, model.OdoReading, before.OdoReading, before.DistanceUnit)
+	if err != nil {
+		return err
+	}
+	updates := db.Expense{
+		VehicleID:    model.VehicleID,
+		Amount:       model.Amount,
+		OdoReading:   odoReading,
+		DistanceUnit: distanceUnit,
+		ExpenseType:  model.ExpenseType,
+		Comments:     model.Comments,
+		UserID:       model.UserID,
+		Date:         model.Date,
+		Vendor:       model.Vendor,
+		Latitude:     model.Latitude,
+		Longitude:    model.Longitude,
+		Address:      model.Address,
+	}
+	if err := checkLocation(updates.Latitude, updates.Longitude); err != nil {
+		return err
+	}
+
+	// Line items are only replaced when they are sent. The total of an itemised
+	// expense is always derived from its lines.
+	lineItems := toUpdate.LineItems
+	if model.LineItems != nil {
+		lineItems = toExpenseLineItems(model.LineItems)
+	}
+	if len(lineItems) > 0 {
+		updates.Amount = sumExpenseLineItems(lineItems)
+	}
+	category, err := resolveExpenseCategory(model.ExpenseCategoryID, model.ExpenseType)
+	if err != nil {
+		return err
+	}
+	if category != nil {
+		updates.ExpenseCategoryID = &category.ID
+		updates.ExpenseType = category.Name
+	}
+	customFields, err := prepareCustomFields(db.EXPENSE_ENTITY, model.CustomFields, false)
+	if err != nil {
+		return err
+	}
+
+	tx := db.DB.Begin()
+	if err := tx.Model(&toUpdate).Omit(clause.Associations).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	// a type which matches no category leaves the expense uncategorised
+	if category == nil && strings.TrimSpace(model.ExpenseType) != "" {
+		if err := tx.Model(&toUpdate).Omit(clause.Associations).Update("expense_category_id", nil).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := customFields.save(tx, toUpdate.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if model.Tags != nil {
+		tags, err := db.FindOrCreateTags(tx, model.Tags)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Model(toUpdate).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if model.LineItems != nil {
+		if err := tx.Where("expense_id = ?", toUpdate.ID).Unscoped().Delete(&db.ExpenseLineItem{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		for i := range lineItems {
+			lineItems[i].ExpenseID = toUpdate.ID
+		}
+		if len(lineItems) > 0 {
+			if err := tx.Create(&lineItems).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	after, err := getExpense(tx, fillupId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actor.ID, db.UPDATE_ACTION, db.AUDIT_EXPENSE, fillupId, &after.VehicleID, before, after); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
-func DeleteFillupById(fillupId uuid.UUID) error {
-	return db.DeleteFillupById(fillupId)
+func toExpenseLineItems(items []models.ExpenseLineItemModel) []db.ExpenseLineItem {
+	if items == nil {
+		return nil
+	}
+	lineItems := make([]db.ExpenseLineItem, 0, len(items))
+	for _, item := range items {
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		lineItems = append(lineItems, db.ExpenseLineItem{
+			LineType:    *item.LineType,
+			Description: item.Description,
+			PartNumber:  item.PartNumber,
+			Quantity:    quantity,
+			UnitPrice:   item.UnitPrice,
+			Amount:      quantity * item.UnitPrice,
+		})
+	}
+	return lineItems
 }
 
-func DeleteExpenseById(expenseId uuid.UUID) error {
-	return db.DeleteExpenseById(expenseId)
+func sumExpenseLineItems(lineItems []db.ExpenseLineItem) float32 {
+	var total float32
+	for _, item := range lineItems {
+		total += item.Amount
+	}
+	return total
+}
+
+// GetPartHistory lists when matching parts were fitted to a vehicle, the most
+// recent first.
+func GetPartHistory(vehicleId uuid.UUID, query models.PartSearchQuery) ([]models.PartHistoryModel, error) {
+	if query.PartNumber == "" && query.Query == "" {
+		return nil, errors.New("partNumber or query is required")
+	}
+	lineItems, err := db.FindExpenseLineItems(vehicleId, query.PartNumber, query.Query)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]models.PartHistoryModel, 0, len(*lineItems))
+	expenses := make(map[uuid.UUID]*db.Expense)
+	for _, item := range *lineItems {
+		expense, ok := expenses[item.ExpenseID]
+		if !ok {
+			expense, err = db.GetExpenseById(item.ExpenseID)
+			if err != nil {
+				return nil, err
+			}
+			expenses[item.ExpenseID] = expense
+		}
+		toReturn = append(toReturn, models.PartHistoryModel{
+			ExpenseID:  expense.ID,
+			Date:       expense.Date,
+			OdoReading: expense.OdoReading,
+			Vendor:     expense.Vendor,
+			Currency:   expense.Currency,
+			LineItem:   item,
+		})
+	}
+	return toReturn, nil
+}
+
+func DeleteFillupById(fillupId, actorId uuid.UUID) error {
+	before, err := GetFillupById(fillupId)
+	if err != nil {
+		return err
+	}
+	tx := db.DB.Begin()
+	if err := db.DeleteFillupById(tx, fillupId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actorId, db.DELETE_ACTION, db.AUDIT_FILLUP, fillupId, &before.VehicleID, before, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func DeleteExpenseById(expenseId, actorId uuid.UUID) error {
+	before, err := GetExpenseById(expenseId)
+	if err != nil {
+		return err
+	}
+	tx := db.DB.Begin()
+	if err := db.DeleteExpenseById(tx, expenseId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordAudit(tx, actorId, db.DELETE_ACTION, db.AUDIT_EXPENSE, expenseId, &before.VehicleID, before, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
 
 func CreateVehicleAttachment(vehicleId, attachmentId uuid.UUID, title string) error {
@@ -255,6 +1123,80 @@ func CreateVehicleAttachment(vehicleId, attachmentId uuid.UUID, title string) er
 	return db.DB.Create(model).Error
 }
 
+// CheckVehicleFillup makes sure that a fillup is recorded against the vehicle
+// it is looked up through.
+func CheckVehicleFillup(vehicleId, fillupId uuid.UUID) error {
+	fillup, err := db.GetFillupById(fillupId)
+	if err != nil {
+		return err
+	}
+	if fillup.VehicleID != vehicleId {
+		return errors.New("this fillup does not belong to the vehicle")
+	}
+	return nil
+}
+
+// CheckVehicleExpense makes sure that an expense is recorded against the
+// vehicle it is looked up through.
+func CheckVehicleExpense(vehicleId, expenseId uuid.UUID) error {
+	expense, err := db.GetExpenseById(expenseId)
+	if err != nil {
+		return err
+	}
+	if expense.VehicleID != vehicleId {
+		return errors.New("this expense does not belong to the vehicle")
+	}
+	return nil
+}
+
+func CreateFillupAttachments(fillupId uuid.UUID, attachments []*db.Attachment, title string) error {
+	tx := db.DB.Begin()
+	for _, attachment := range attachments {
+		model := &db.FillupAttachment{
+			AttachmentID: attachment.ID,
+			FillupID:     fillupId,
+			Title:        title,
+		}
+		if err := tx.Create(model).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func CreateExpenseAttachments(expenseId uuid.UUID, attachments []*db.Attachment, title string) error {
+	tx := db.DB.Begin()
+	for _, attachment := range attachments {
+		model := &db.ExpenseAttachment{
+			AttachmentID: attachment.ID,
+			ExpenseID:    expenseId,
+			Title:        title,
+		}
+		if err := tx.Create(model).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func GetFillupAttachments(fillupId uuid.UUID) (*[]db.Attachment, error) {
+	return db.GetFillupAttachments(fillupId)
+}
+
+func GetExpenseAttachments(expenseId uuid.UUID) (*[]db.Attachment, error) {
+	return db.GetExpenseAttachments(expenseId)
+}
+
+func DeleteFillupAttachment(fillupId, attachmentId uuid.UUID) error {
+	return db.DeleteFillupAttachment(fillupId, attachmentId)
+}
+
+func DeleteExpenseAttachment(expenseId, attachmentId uuid.UUID) error {
+	return db.DeleteExpenseAttachment(expenseId, attachmentId)
+}
+
 func GetVehicleAttachments(vehicleId uuid.UUID) (*[]db.Attachment, error) {
 
 	return db.GetVehicleAttachments(vehicleId)
@@ -266,30 +1208,63 @@ func GetDistinctFuelSubtypesForVehicle(vehicleId uuid.UUID) ([]string, error) {
 	return names, tx.Error
 }
 
+// GetLatestOdoReadingForVehicle returns the true distance of the vehicle as of
+// its latest fillup or expense.
 func GetLatestOdoReadingForVehicle(vehicleId uuid.UUID) (int, error) {
+	odo, err := getOdometer(vehicleId)
+	if err != nil {
+		return 0, err
+	}
 	odoReading := 0
-	latestFillup, err := db.GetLatestExpenseByVehicleId(vehicleId)
+	latestFillup, err := db.GetLatestFillupsByVehicleId(vehicleId)
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return 0, err
 	}
-	odoReading = latestFillup.OdoReading
+	if err == nil {
+		odoReading = odo.trueDistance(latestFillup.OdoReading, latestFillup.Date)
+	}
 
 	latestExpense, err := db.GetLatestExpenseByVehicleId(vehicleId)
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return 0, err
 	}
-	if latestExpense.OdoReading > odoReading {
-		odoReading = latestExpense.OdoReading
+	if err == nil && odo.trueDistance(latestExpense.OdoReading, latestExpense.Date) > odoReading {
+		odoReading = odo.trueDistance(latestExpense.OdoReading, latestExpense.Date)
 	}
 	return odoReading, nil
 }
 
 func GetUserStats(userId uuid.UUID, model models.UserStatsQueryModel) ([]models.VehicleStatsModel, error) {
+	fillups, expenses, err := getUserStatsEntries(userId, model)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := models.VehicleStatsModel{}
+	stats := toReturn.SetStats(fillups, expenses)
+
+	return stats, nil
+}
 
-	vehicles, err := GetUserVehicles(userId)
+func GetVehicleStats(vehicleId uuid.UUID, tags []string) ([]models.VehicleStatsModel, error) {
+	fillups, err := db.GetFillupsByVehicleId(vehicleId)
 	if err != nil {
 		return nil, err
 	}
+	expenses, err := db.GetExpensesByVehicleId(vehicleId)
+	if err != nil {
+		return nil, err
+	}
+	filteredFillups := FilterFillupsByTags(*fillups, tags)
+	filteredExpenses := FilterExpensesByTags(*expenses, tags)
+	model := models.VehicleStatsModel{}
+	return model.SetStats(&filteredFillups, &filteredExpenses), nil
+}
+
+func getUserStatsEntries(userId uuid.UUID, model models.UserStatsQueryModel) (*[]db.Fillup, *[]db.Expense, error) {
+	vehicles, err := GetUserVehicles(userId, model.IncludeInactive)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var vehicleIds []uuid.UUID
 	for _, v := range *vehicles {
@@ -298,14 +1273,13 @@ func GetUserStats(userId uuid.UUID, model models.UserStatsQueryModel) ([]models.
 
 	expenses, err := db.FindExpensesForDateRange(vehicleIds, model.Start, model.End)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	fillups, err := db.FindFillupsForDateRange(vehicleIds, model.Start, model.End)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	toReturn := models.VehicleStatsModel{}
-	stats := toReturn.SetStats(fillups, expenses)
-
-	return stats, nil
+	filteredFillups := FilterFillupsByTags(*fillups, model.Tags)
+	filteredExpenses := FilterExpensesByTags(*expenses, model.Tags)
+	return &filteredFillups, &filteredExpenses, nil
 }